@@ -252,6 +252,75 @@ func setDefaults() {
 	viper.SetDefault("logging.compress", true)
 	viper.SetDefault("logging.report_caller", true)
 
+	// STT 长音频分段默认配置
+	viper.SetDefault("stt.chunking.enabled", true)
+	viper.SetDefault("stt.chunking.concurrency", 4)
+	viper.SetDefault("stt.post_process.enabled", false)
+	viper.SetDefault("stt.post_process.timeout", 10)
+
+	// TTS 缓存默认配置
+	viper.SetDefault("tts.cache.enabled", true)
+	viper.SetDefault("tts.cache.memory_entries", 1024)
+	viper.SetDefault("tts.cache.storage", true)
+	viper.SetDefault("tts.long_text.enabled", true)
+	viper.SetDefault("tts.long_text.concurrency", 4)
+	viper.SetDefault("tts.voices.refresh_minutes", 60)
+
+	// 语音翻译默认配置
+	viper.SetDefault("translation.provider", "llm")
+	viper.SetDefault("translation.targets", []string{"en-US"})
+
+	// LLM 工具调用默认配置
+	viper.SetDefault("llm.tools.max_rounds", 5)
+
+	// 多轮对话默认配置
+	viper.SetDefault("conversation.store", "memory")
+	viper.SetDefault("conversation.path", "./data/conversations")
+	viper.SetDefault("conversation.max_history_tokens", 2000)
+	viper.SetDefault("conversation.summarize", true)
+	viper.SetDefault("conversation.default_persona", "")
+	viper.SetDefault("conversation.idle_minutes", 1440)
+	viper.SetDefault("conversation.barge_in.enabled", true)
+	viper.SetDefault("conversation.barge_in.mode", "audio_start")
+	viper.SetDefault("conversation.barge_in.threshold", 500)
+	viper.SetDefault("conversation.barge_in.min_speech_ms", 200)
+
+	// Google STT 默认配置
+	viper.SetDefault("google.stt.language_code", "en-US")
+	viper.SetDefault("google.stt.enable_automatic_punctuation", true)
+	viper.SetDefault("google.stt.long_running", true)
+
+	// Azure TTS 默认配置
+	viper.SetDefault("azure.tts.voice", "en-US-AriaNeural")
+	viper.SetDefault("azure.tts.language", "en-US")
+	viper.SetDefault("azure.tts.output_format", "riff-24khz-16bit-mono-pcm")
+
+	// Google TTS 默认配置
+	viper.SetDefault("google.tts.voice", "en-US-Wavenet-D")
+	viper.SetDefault("google.tts.language_code", "en-US")
+	viper.SetDefault("google.tts.audio_encoding", "LINEAR16")
+
+	// 火山引擎 TTS 默认配置
+	viper.SetDefault("volcengine.tts.bidirection_url", "wss://openspeech.bytedance.com/api/v3/tts/bidirection")
+	viper.SetDefault("volcengine.tts.resource_id", "volc.service_type.10029")
+	viper.SetDefault("volcengine.tts.sample_rate", 24000)
+
+	// Piper 默认配置
+	viper.SetDefault("piper.mode", "command")
+	viper.SetDefault("piper.command", "piper")
+	viper.SetDefault("piper.length_scale", 1.0)
+	viper.SetDefault("piper.timeout", 60)
+
+	// 离线 LLM 默认配置
+	viper.SetDefault("local_llm.backend", "ollama")
+	viper.SetDefault("local_llm.timeout", 120)
+
+	// OpenAI 默认配置
+	viper.SetDefault("openai.base_url", "https://api.openai.com/v1")
+	viper.SetDefault("openai.model", "gpt-4o-mini")
+	viper.SetDefault("openai.temperature", 0.7)
+	viper.SetDefault("openai.timeout", 60)
+
 	// AWS 默认配置
 	viper.SetDefault("aws.region", "us-east-2")
 
@@ -287,15 +287,39 @@ uploadAudioInput.addEventListener('change', () => {
     }
 });
 
+// 从 /v1/voices 加载可用音色，填充音色选择框
+// 合成只会使用当前的 TTS 提供商，只列出该提供商的音色
+const voiceSelect = document.getElementById('voice-select');
+
+function loadVoices() {
+    fetch('/v1/voices')
+        .then(response => response.json())
+        .then(data => {
+            (data.voices || []).filter(voice => !data.active_provider || voice.provider === data.active_provider).forEach(voice => {
+                const option = document.createElement('option');
+                option.value = voice.id;
+                option.textContent = `${voice.name} (${voice.language}${voice.gender ? ', ' + voice.gender : ''})`;
+                voiceSelect.appendChild(option);
+            });
+        })
+        .catch(error => console.error('加载音色列表失败:', error));
+}
+
+loadVoices();
+
 function sendTextMessage(text) {
     // 显示发送的消息
     appendMessage('你', text);
     
-    // 过 WebSocket 发送文字消息
-    ws.send(JSON.stringify({ 
+    // 过 WebSocket 发送文字消息，选择了音色时一并发送
+    const message = {
         text: text,
         require_tts: true
-    }));
+    };
+    if (voiceSelect.value) {
+        message.voice = voiceSelect.value;
+    }
+    ws.send(JSON.stringify(message));
     
     // 可以添加一个加载提示
     appendSystemMessage('正在生成语音...');
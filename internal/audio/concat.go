@@ -0,0 +1,120 @@
+// internal/audio/concat.go
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Joiner 将同一格式的多段音频按顺序拼接为一个连续的音频流
+// 每段音频通过 Next 转换为应追加到输出末尾的数据：
+//   - WAV：首段输出长度未知的流式文件头，之后只输出 PCM 数据，各段的采样格式必须一致
+//   - MP3：按帧拼接，去掉后续分段的 ID3v2 标签以及所有分段的 ID3v1 标签
+//   - OGG：直接拼接为链式 Ogg 流
+//   - 裸 PCM：直接拼接
+type Joiner struct {
+	container Container
+	format    Format
+	started   bool
+}
+
+// Next 返回当前分段应追加到输出的数据
+func (j *Joiner) Next(data []byte) ([]byte, error) {
+	container := DetectContainer(data)
+	if !j.started {
+		j.container = container
+	} else if container != j.container {
+		return nil, fmt.Errorf("音频分段格式不一致: %s 与 %s", j.container.Name, container.Name)
+	}
+
+	switch container {
+	case ContainerWAV:
+		format, pcm, err := ParseWAV(data)
+		if err != nil {
+			return nil, err
+		}
+		if !j.started {
+			j.format = format
+			j.started = true
+			return append(streamingWAVHeader(format), pcm...), nil
+		}
+		if format != j.format {
+			return nil, fmt.Errorf("WAV 分段的采样格式不一致: %+v 与 %+v", j.format, format)
+		}
+		return pcm, nil
+	case ContainerMP3:
+		first := !j.started
+		j.started = true
+		data = stripID3v1(data)
+		if first {
+			return data, nil
+		}
+		return stripID3v2(data), nil
+	case ContainerOGG, ContainerUnknown:
+		j.started = true
+		return data, nil
+	default:
+		return nil, fmt.Errorf("不支持拼接 %s 格式的音频", container.Name)
+	}
+}
+
+// Concat 将多段音频拼接为一个完整的文件，WAV 会重新写入正确长度的文件头
+func Concat(parts [][]byte) ([]byte, error) {
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+
+	var (
+		joiner Joiner
+		buf    bytes.Buffer
+	)
+	for i, part := range parts {
+		data, err := joiner.Next(part)
+		if err != nil {
+			return nil, fmt.Errorf("拼接第 %d 段音频失败: %v", i+1, err)
+		}
+		buf.Write(data)
+	}
+
+	if joiner.container == ContainerWAV {
+		return EncodeWAV(joiner.format, buf.Bytes()[wavHeaderSize:]), nil
+	}
+	return buf.Bytes(), nil
+}
+
+// wavHeaderSize EncodeWAV 生成的文件头长度
+const wavHeaderSize = 44
+
+// streamingWAVHeader 生成长度字段为最大值的 WAV 文件头，用于总长度未知的流式输出
+func streamingWAVHeader(format Format) []byte {
+	header := EncodeWAV(format, nil)
+	binary.LittleEndian.PutUint32(header[4:8], 0xFFFFFFFF)
+	binary.LittleEndian.PutUint32(header[40:44], 0xFFFFFFFF-36)
+	return header
+}
+
+// stripID3v2 去掉文件开头的 ID3v2 标签
+func stripID3v2(data []byte) []byte {
+	if len(data) < 10 || string(data[0:3]) != "ID3" {
+		return data
+	}
+	// 标签长度为 4 个 7 位的 synchsafe 整数，不含 10 字节的标签头
+	size := int(data[6])<<21 | int(data[7])<<14 | int(data[8])<<7 | int(data[9])
+	size += 10
+	if data[5]&0x10 != 0 {
+		size += 10 // 带有标签尾
+	}
+	if size > len(data) {
+		return data
+	}
+	return data[size:]
+}
+
+// stripID3v1 去掉文件末尾 128 字节的 ID3v1 标签
+func stripID3v1(data []byte) []byte {
+	if len(data) >= 128 && string(data[len(data)-128:len(data)-125]) == "TAG" {
+		return data[:len(data)-128]
+	}
+	return data
+}
@@ -0,0 +1,52 @@
+// internal/audio/container.go
+package audio
+
+import "bytes"
+
+// Container 描述音频文件的封装格式
+type Container struct {
+	Name      string // 格式名称，如 wav、mp3
+	Extension string // 文件扩展名，包含前导点
+	MIMEType  string
+}
+
+var (
+	ContainerWAV  = Container{Name: "wav", Extension: ".wav", MIMEType: "audio/wav"}
+	ContainerMP3  = Container{Name: "mp3", Extension: ".mp3", MIMEType: "audio/mpeg"}
+	ContainerOGG  = Container{Name: "ogg", Extension: ".ogg", MIMEType: "audio/ogg"}
+	ContainerFLAC = Container{Name: "flac", Extension: ".flac", MIMEType: "audio/flac"}
+	ContainerWebM = Container{Name: "webm", Extension: ".webm", MIMEType: "audio/webm"}
+	ContainerMP4  = Container{Name: "mp4", Extension: ".m4a", MIMEType: "audio/mp4"}
+	ContainerAAC  = Container{Name: "aac", Extension: ".aac", MIMEType: "audio/aac"}
+	ContainerAMR  = Container{Name: "amr", Extension: ".amr", MIMEType: "audio/amr"}
+	// ContainerUnknown 无法识别的数据，通常是没有文件头的裸 PCM
+	ContainerUnknown = Container{Name: "", Extension: ".bin", MIMEType: "application/octet-stream"}
+)
+
+// DetectContainer 根据文件头的魔数判断音频封装格式
+func DetectContainer(data []byte) Container {
+	switch {
+	case IsWAV(data):
+		return ContainerWAV
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return ContainerOGG
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return ContainerFLAC
+	case bytes.HasPrefix(data, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		// Matroska 与 WebM 共用 EBML 头，浏览器录音一般为 WebM
+		return ContainerWebM
+	case len(data) >= 12 && string(data[4:8]) == "ftyp":
+		return ContainerMP4
+	case bytes.HasPrefix(data, []byte("#!AMR")):
+		return ContainerAMR
+	case bytes.HasPrefix(data, []byte("ID3")):
+		return ContainerMP3
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xF6 == 0xF0:
+		// ADTS 帧同步字后 layer 字段固定为 0
+		return ContainerAAC
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		return ContainerMP3
+	default:
+		return ContainerUnknown
+	}
+}
@@ -0,0 +1,57 @@
+// internal/audio/pcm.go
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// ScalePCM16 按倍率调整 16 位 PCM 的音量，超出范围的采样会被截断
+func ScalePCM16(pcm []byte, gain float64) []byte {
+	out := make([]byte, len(pcm)-len(pcm)%2)
+	for i := 0; i+1 < len(pcm); i += 2 {
+		sample := float64(int16(binary.LittleEndian.Uint16(pcm[i:]))) * gain
+		sample = math.Max(math.MinInt16, math.Min(math.MaxInt16, sample))
+		binary.LittleEndian.PutUint16(out[i:], uint16(int16(sample)))
+	}
+	return out
+}
+
+// Resample16 使用线性插值将 16 位 PCM 重采样到目标采样率
+func Resample16(format Format, pcm []byte, sampleRate int) ([]byte, error) {
+	if format.BitsPerSample != 16 {
+		return nil, fmt.Errorf("仅支持 16 位 PCM 重采样，实际为 %d 位", format.BitsPerSample)
+	}
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("目标采样率无效: %d", sampleRate)
+	}
+	if sampleRate == format.SampleRate {
+		return pcm, nil
+	}
+
+	channels := format.Channels
+	frames := len(pcm) / format.BlockAlign()
+	outFrames := int(int64(frames) * int64(sampleRate) / int64(format.SampleRate))
+	out := make([]byte, outFrames*format.BlockAlign())
+	sample := func(frame, ch int) float64 {
+		offset := (frame*channels + ch) * 2
+		return float64(int16(binary.LittleEndian.Uint16(pcm[offset:])))
+	}
+
+	ratio := float64(format.SampleRate) / float64(sampleRate)
+	for i := 0; i < outFrames; i++ {
+		pos := float64(i) * ratio
+		left := int(pos)
+		right := left + 1
+		if right >= frames {
+			right = frames - 1
+		}
+		frac := pos - float64(left)
+		for ch := 0; ch < channels; ch++ {
+			v := sample(left, ch)*(1-frac) + sample(right, ch)*frac
+			binary.LittleEndian.PutUint16(out[(i*channels+ch)*2:], uint16(int16(math.Round(v))))
+		}
+	}
+	return out, nil
+}
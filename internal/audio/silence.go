@@ -0,0 +1,113 @@
+// internal/audio/silence.go
+package audio
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// silenceWindow 静音检测时使用的分析窗口长度
+const silenceWindow = 20 * time.Millisecond
+
+// FindQuietestPoint 在 PCM 数据的 [from, to) 字节区间内寻找能量最低的位置，
+// 返回值为对齐到采样帧的字节偏移。仅支持 16 位采样，其他位深直接返回区间中点。
+func FindQuietestPoint(format Format, pcm []byte, from, to int) int {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(pcm) {
+		to = len(pcm)
+	}
+	from = format.align(from)
+	to = format.align(to)
+	if to <= from {
+		return from
+	}
+	if format.BitsPerSample != 16 {
+		return format.align(from + (to-from)/2)
+	}
+
+	window := format.Bytes(silenceWindow)
+	if window <= 0 || window > to-from {
+		return format.align(from + (to-from)/2)
+	}
+
+	// 以半窗口步长滑动，选取平均幅度最小的窗口中心
+	step := format.align(window / 2)
+	if step <= 0 {
+		step = format.BlockAlign()
+	}
+	best := from + (to-from)/2
+	bestEnergy := int64(-1)
+	for pos := from; pos+window <= to; pos += step {
+		energy := meanAmplitude16(pcm[pos : pos+window])
+		if bestEnergy < 0 || energy < bestEnergy {
+			bestEnergy = energy
+			best = pos + window/2
+		}
+	}
+	return format.align(best)
+}
+
+// meanAmplitude16 计算 16 位小端 PCM 数据的平均绝对幅度
+func meanAmplitude16(pcm []byte) int64 {
+	samples := len(pcm) / 2
+	if samples == 0 {
+		return 0
+	}
+	var sum int64
+	for i := 0; i+1 < len(pcm); i += 2 {
+		v := int64(int16(binary.LittleEndian.Uint16(pcm[i : i+2])))
+		if v < 0 {
+			v = -v
+		}
+		sum += v
+	}
+	return sum / int64(samples)
+}
+
+// IsSilent 判断 16 位 PCM 数据的平均幅度是否低于 threshold
+func IsSilent(pcm []byte, threshold int) bool {
+	return meanAmplitude16(pcm) < int64(threshold)
+}
+
+// SpeechDetector 在持续到达的 16 位 PCM 音频中检测人声
+// 音频按 20ms 的窗口分析，连续 MinSpeech 时长的窗口都不是静音时判定为开始说话
+type SpeechDetector struct {
+	Format    Format
+	Threshold int
+	MinSpeech time.Duration
+
+	pending []byte
+	voiced  time.Duration
+}
+
+// Write 追加一段音频，检测到人声时返回 true
+func (d *SpeechDetector) Write(pcm []byte) bool {
+	window := d.Format.Bytes(silenceWindow)
+	if window <= 0 {
+		return false
+	}
+
+	d.pending = append(d.pending, pcm...)
+	detected := false
+	offset := 0
+	for ; offset+window <= len(d.pending); offset += window {
+		if IsSilent(d.pending[offset:offset+window], d.Threshold) {
+			d.voiced = 0
+			continue
+		}
+		d.voiced += silenceWindow
+		if d.voiced >= d.MinSpeech {
+			detected = true
+		}
+	}
+	d.pending = append(d.pending[:0], d.pending[offset:]...)
+	return detected
+}
+
+// Reset 清除已累计的状态
+func (d *SpeechDetector) Reset() {
+	d.pending = d.pending[:0]
+	d.voiced = 0
+}
@@ -0,0 +1,67 @@
+package audio
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testFormat = Format{SampleRate: 16000, Channels: 1, BitsPerSample: 16}
+
+// tone 生成 duration 时长、幅度交替为 ±amplitude 的 16 位 PCM
+func tone(format Format, duration time.Duration, amplitude int16) []byte {
+	pcm := make([]byte, format.Bytes(duration))
+	for i := 0; i+1 < len(pcm); i += 2 {
+		v := amplitude
+		if i/2%2 == 1 {
+			v = -amplitude
+		}
+		binary.LittleEndian.PutUint16(pcm[i:], uint16(v))
+	}
+	return pcm
+}
+
+func TestIsSilent(t *testing.T) {
+	assert.True(t, IsSilent(tone(testFormat, 20*time.Millisecond, 100), 500))
+	assert.False(t, IsSilent(tone(testFormat, 20*time.Millisecond, 1000), 500))
+	// 负半周按绝对值计算
+	assert.False(t, IsSilent(tone(testFormat, 20*time.Millisecond, -1000), 500))
+	assert.True(t, IsSilent(nil, 1))
+}
+
+func TestSpeechDetector(t *testing.T) {
+	d := SpeechDetector{Format: testFormat, Threshold: 500, MinSpeech: 60 * time.Millisecond}
+
+	assert.False(t, d.Write(tone(testFormat, 200*time.Millisecond, 100)))
+	// 人声不足 MinSpeech 时不触发，中间的静音会清零累计
+	assert.False(t, d.Write(tone(testFormat, 40*time.Millisecond, 2000)))
+	assert.False(t, d.Write(tone(testFormat, 20*time.Millisecond, 0)))
+	assert.False(t, d.Write(tone(testFormat, 40*time.Millisecond, 2000)))
+	assert.True(t, d.Write(tone(testFormat, 20*time.Millisecond, 2000)))
+}
+
+func TestSpeechDetectorSplitWrites(t *testing.T) {
+	d := SpeechDetector{Format: testFormat, Threshold: 500, MinSpeech: 40 * time.Millisecond}
+	speech := tone(testFormat, 40*time.Millisecond, 2000)
+
+	// 不足一个窗口的数据留到下次写入时一起分析
+	detected := false
+	for i := 0; i < len(speech); i += 100 {
+		end := i + 100
+		if end > len(speech) {
+			end = len(speech)
+		}
+		detected = d.Write(speech[i:end]) || detected
+	}
+	assert.True(t, detected)
+
+	d.Reset()
+	assert.False(t, d.Write(speech[:len(speech)/2]))
+}
+
+func TestSpeechDetectorWithoutFormat(t *testing.T) {
+	d := SpeechDetector{Threshold: 500}
+	assert.False(t, d.Write(tone(testFormat, 100*time.Millisecond, 2000)))
+}
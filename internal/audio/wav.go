@@ -0,0 +1,139 @@
+// internal/audio/wav.go
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Format 描述 PCM 音频的基本参数
+type Format struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+}
+
+// BlockAlign 返回单个采样帧（所有声道）占用的字节数
+func (f Format) BlockAlign() int {
+	return f.Channels * f.BitsPerSample / 8
+}
+
+// BytesPerSecond 返回每秒音频占用的字节数
+func (f Format) BytesPerSecond() int {
+	return f.SampleRate * f.BlockAlign()
+}
+
+// Duration 计算给定字节数的 PCM 数据对应的时长
+func (f Format) Duration(n int) time.Duration {
+	bps := f.BytesPerSecond()
+	if bps == 0 {
+		return 0
+	}
+	return time.Duration(int64(n) * int64(time.Second) / int64(bps))
+}
+
+// Bytes 计算给定时长对应的 PCM 字节数，结果按采样帧对齐
+func (f Format) Bytes(d time.Duration) int {
+	n := int(int64(d) * int64(f.BytesPerSecond()) / int64(time.Second))
+	return f.align(n)
+}
+
+func (f Format) align(n int) int {
+	block := f.BlockAlign()
+	if block <= 0 {
+		return n
+	}
+	return n - n%block
+}
+
+// IsWAV 判断数据是否以 RIFF/WAVE 头开始
+func IsWAV(data []byte) bool {
+	return len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE"
+}
+
+// ParseWAV 解析 WAV 文件，返回音频格式和 PCM 数据
+// 仅支持未压缩的 PCM 编码
+func ParseWAV(data []byte) (Format, []byte, error) {
+	var format Format
+	if !IsWAV(data) {
+		return format, nil, fmt.Errorf("不是有效的 WAV 数据")
+	}
+
+	var (
+		pcm      []byte
+		foundFmt bool
+	)
+	offset := 12
+	for offset+8 <= len(data) {
+		chunkID := string(data[offset : offset+4])
+		chunkSize := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		body := offset + 8
+		end := body + chunkSize
+		// 流式录制的 WAV 可能在 data 块中写入了错误的长度，按实际长度截断
+		if end > len(data) || end < body {
+			end = len(data)
+		}
+
+		switch chunkID {
+		case "fmt ":
+			if end-body < 16 {
+				return format, nil, fmt.Errorf("WAV fmt 块长度不足")
+			}
+			audioFormat := binary.LittleEndian.Uint16(data[body : body+2])
+			// 1 为 PCM，0xFFFE 为 WAVE_FORMAT_EXTENSIBLE（通常同样承载 PCM）
+			if audioFormat != 1 && audioFormat != 0xFFFE {
+				return format, nil, fmt.Errorf("不支持的 WAV 编码格式: %d", audioFormat)
+			}
+			format.Channels = int(binary.LittleEndian.Uint16(data[body+2 : body+4]))
+			format.SampleRate = int(binary.LittleEndian.Uint32(data[body+4 : body+8]))
+			format.BitsPerSample = int(binary.LittleEndian.Uint16(data[body+14 : body+16]))
+			foundFmt = true
+		case "data":
+			pcm = data[body:end]
+		}
+
+		// RIFF 块按偶数字节对齐
+		offset = end + chunkSize%2
+		if pcm != nil && foundFmt {
+			break
+		}
+	}
+
+	if !foundFmt {
+		return format, nil, fmt.Errorf("WAV 缺少 fmt 块")
+	}
+	if pcm == nil {
+		return format, nil, fmt.Errorf("WAV 缺少 data 块")
+	}
+	if format.BlockAlign() == 0 || format.SampleRate == 0 {
+		return format, nil, fmt.Errorf("WAV 格式参数无效: %+v", format)
+	}
+	return format, pcm[:format.align(len(pcm))], nil
+}
+
+// EncodeWAV 将 PCM 数据封装为标准的 44 字节头 WAV 文件
+func EncodeWAV(format Format, pcm []byte) []byte {
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm))
+
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVE")
+
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint16(format.Channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(format.SampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(format.BytesPerSecond()))
+	binary.Write(&buf, binary.LittleEndian, uint16(format.BlockAlign()))
+	binary.Write(&buf, binary.LittleEndian, uint16(format.BitsPerSample))
+
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+
+	return buf.Bytes()
+}
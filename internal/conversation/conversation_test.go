@@ -0,0 +1,295 @@
+package conversation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+type fakeLLM struct {
+	prompts []string
+}
+
+func (f *fakeLLM) GetResponse(prompt string) (string, error) {
+	f.prompts = append(f.prompts, prompt)
+	return "摘要", nil
+}
+
+func TestHistoryWithinBudget(t *testing.T) {
+	m := New(NewMemoryStore(), nil, Options{MaxHistoryTokens: 10})
+	session, _, resumed, err := m.Open("", "user")
+	assert.NoError(t, err)
+	assert.False(t, resumed)
+
+	for _, text := range []string{"第一句话很长很长", "第二句", "第三句"} {
+		_, err := m.Append(session.ID, models.RoleUser, text, "")
+		assert.NoError(t, err)
+	}
+
+	history, err := m.History(context.Background(), session.ID, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ChatMessage{
+		{Role: models.RoleUser, Content: "第二句"},
+		{Role: models.RoleUser, Content: "第三句"},
+	}, history)
+}
+
+func TestHistoryKeepsLatestMessage(t *testing.T) {
+	m := New(NewMemoryStore(), nil, Options{MaxHistoryTokens: 1})
+	session, _, _, _ := m.Open("", "")
+	m.Append(session.ID, models.RoleUser, "这句话超过了预算", "")
+
+	history, err := m.History(context.Background(), session.ID, "")
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+}
+
+func TestHistorySummarizesOlderTurns(t *testing.T) {
+	llm := &fakeLLM{}
+	m := New(NewMemoryStore(), llm, Options{MaxHistoryTokens: 10, Summarize: true})
+	session, _, _, _ := m.Open("s1", "")
+	m.Append(session.ID, models.RoleUser, "我叫小明", "")
+	m.Append(session.ID, models.RoleAssistant, "你好小明", "")
+	m.Append(session.ID, models.RoleUser, "天气如何", "")
+
+	history, err := m.History(context.Background(), session.ID, "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.RoleSystem, history[0].Role)
+	assert.True(t, strings.HasSuffix(history[0].Content, "摘要"))
+	assert.Equal(t, "天气如何", history[len(history)-1].Content)
+	assert.Len(t, llm.prompts, 1)
+	assert.Contains(t, llm.prompts[0], "用户：我叫小明")
+
+	// 摘要已覆盖的消息不会再次总结
+	_, err = m.History(context.Background(), session.ID, "")
+	assert.NoError(t, err)
+	assert.Len(t, llm.prompts, 1)
+}
+
+func TestFileStoreResume(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	assert.NoError(t, err)
+	m := New(store, nil, Options{})
+	session, _, _, err := m.Open("abc", "user")
+	assert.NoError(t, err)
+	msg, err := m.Append(session.ID, models.RoleUser, "你好", "")
+	assert.NoError(t, err)
+	assert.NoError(t, m.SetAudioURL(session.ID, msg.ID, "url://audio"))
+
+	// 模拟服务重启后客户端使用相同的会话 ID 重连
+	reopened, err := NewFileStore(dir)
+	assert.NoError(t, err)
+	_, history, resumed, err := New(reopened, nil, Options{}).Open("abc", "user")
+	assert.NoError(t, err)
+	assert.True(t, resumed)
+	assert.Len(t, history, 1)
+	assert.Equal(t, "url://audio", history[0].AudioURL)
+}
+
+func TestOpenRejectsInvalidSessionID(t *testing.T) {
+	m := New(NewMemoryStore(), nil, Options{})
+	_, _, _, err := m.Open("../etc/passwd", "")
+	assert.Error(t, err)
+}
+
+func TestHistoryWithSystemPrompt(t *testing.T) {
+	m := New(NewMemoryStore(), nil, Options{MaxHistoryTokens: 8})
+	session, _, _, _ := m.Open("", "")
+	m.Append(session.ID, models.RoleUser, "第一句", "")
+	m.Append(session.ID, models.RoleUser, "第二句", "")
+
+	// 系统提示词计入预算，挤掉较早的消息
+	history, err := m.History(context.Background(), session.ID, "你是助手")
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ChatMessage{
+		{Role: models.RoleSystem, Content: "你是助手"},
+		{Role: models.RoleUser, Content: "第二句"},
+	}, history)
+
+	configured, err := m.Configure(session.ID, "tutor", map[string]string{"user_name": "小明"})
+	assert.NoError(t, err)
+	assert.Equal(t, "tutor", configured.Persona)
+	_, _, resumed, err := m.Open(session.ID, "")
+	assert.NoError(t, err)
+	assert.True(t, resumed)
+	reopened, err := m.Configure(session.ID, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "小明", reopened.Variables["user_name"])
+}
+
+func TestLocksReleasedAfterUse(t *testing.T) {
+	m := New(NewMemoryStore(), nil, Options{})
+	session, _, _, _ := m.Open("", "")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.Append(session.ID, models.RoleUser, "你好", "")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	messages, _ := m.store.Messages(session.ID)
+	assert.Len(t, messages, 20)
+	// 没有调用持有或等待时不保留会话的锁
+	assert.Empty(t, m.locks)
+}
+
+func TestMemoryStoreEvict(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	store.SaveSession(&models.Session{ID: "idle", UpdatedAt: now.Add(-2 * time.Hour)})
+	store.SaveSession(&models.Session{ID: "active", UpdatedAt: now})
+
+	assert.Equal(t, 1, store.Evict(now.Add(-time.Hour)))
+	_, found, _ := store.GetSession("idle")
+	assert.False(t, found)
+	_, found, _ = store.GetSession("active")
+	assert.True(t, found)
+}
+
+func TestStartEviction(t *testing.T) {
+	store := NewMemoryStore()
+	m := New(store, nil, Options{})
+	store.SaveSession(&models.Session{ID: "idle", UpdatedAt: time.Now().Add(-time.Hour)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m.StartEviction(ctx, 40*time.Millisecond)
+
+	assert.Eventually(t, func() bool {
+		_, found, _ := store.GetSession("idle")
+		return !found
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestFileStoreEvictAndReload(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	assert.NoError(t, err)
+	m := New(store, nil, Options{})
+	session, _, _, _ := m.Open("abc", "")
+	first, _ := m.Append(session.ID, models.RoleUser, "第一句", "")
+
+	// 移出内存后再次访问时从文件恢复，追加的消息不会覆盖已有的消息
+	assert.Equal(t, 1, store.Evict(time.Now().Add(time.Minute)))
+	_, err = m.Append(session.ID, models.RoleAssistant, "第二句", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, store.Evict(time.Now().Add(time.Minute)))
+	assert.NoError(t, m.SetAudioURL(session.ID, first.ID, "url://audio"))
+	assert.Equal(t, 1, store.Evict(time.Now().Add(time.Minute)))
+
+	messages, err := store.Messages(session.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, "url://audio", messages[0].AudioURL)
+		assert.Equal(t, "第二句", messages[1].Content)
+	}
+}
+
+func TestFileStoreAppendsMessages(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir)
+	m := New(store, nil, Options{})
+	session, _, _, _ := m.Open("abc", "")
+	msg, _ := m.Append(session.ID, models.RoleUser, "你好", "")
+	m.SetAudioURL(session.ID, msg.ID, "url://audio")
+
+	// 每次写入只追加一行，会话文件中不包含消息
+	data, err := os.ReadFile(filepath.Join(dir, "abc.jsonl"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"))
+	data, err = os.ReadFile(filepath.Join(dir, "abc.json"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "你好")
+
+	// 写入一半的最后一行被跳过
+	f, _ := os.OpenFile(filepath.Join(dir, "abc.jsonl"), os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"id":"broken`)
+	f.Close()
+	reopened, _ := NewFileStore(dir)
+	messages, err := reopened.Messages("abc")
+	assert.NoError(t, err)
+	if assert.Len(t, messages, 1) {
+		assert.Equal(t, "url://audio", messages[0].AudioURL)
+	}
+}
+
+// blockingLLM 在 release 关闭前不返回摘要
+type blockingLLM struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingLLM) GetResponse(prompt string) (string, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return "摘要", nil
+}
+
+func TestHistorySummarizeDoesNotBlockWrites(t *testing.T) {
+	llm := &blockingLLM{started: make(chan struct{}, 1), release: make(chan struct{})}
+	m := New(NewMemoryStore(), llm, Options{MaxHistoryTokens: 10, Summarize: true})
+	session, _, _, _ := m.Open("s1", "")
+	m.Append(session.ID, models.RoleUser, "我叫小明", "")
+	m.Append(session.ID, models.RoleAssistant, "你好小明", "")
+	last, _ := m.Append(session.ID, models.RoleUser, "天气如何", "")
+
+	done := make(chan []models.ChatMessage, 1)
+	go func() {
+		history, err := m.History(context.Background(), session.ID, "")
+		assert.NoError(t, err)
+		done <- history
+	}()
+	<-llm.started
+
+	// 生成摘要期间同一会话仍可写入
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		assert.NoError(t, m.SetAudioURL(session.ID, last.ID, "url://audio"))
+		_, err := m.Append(session.ID, models.RoleAssistant, "晴天", "")
+		assert.NoError(t, err)
+	}()
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatal("生成摘要时写入被阻塞")
+	}
+
+	close(llm.release)
+	history := <-done
+	assert.Equal(t, "天气如何", history[len(history)-1].Content)
+	saved, _, _ := m.store.GetSession(session.ID)
+	assert.Equal(t, "摘要", saved.Summary)
+	assert.Equal(t, 1, saved.SummarizedCount)
+}
+
+func TestSaveSummarySkipsStaleResult(t *testing.T) {
+	m := New(NewMemoryStore(), nil, Options{})
+	session, _, _, _ := m.Open("s1", "")
+	session.Summary, session.SummarizedCount = "较新的摘要", 4
+	assert.NoError(t, m.store.SaveSession(session))
+
+	// 摘要期间已被其他调用更新时保留较新的摘要
+	assert.NoError(t, m.saveSummary(session.ID, 0, "过时的摘要", 2))
+	saved, _, _ := m.store.GetSession(session.ID)
+	assert.Equal(t, "较新的摘要", saved.Summary)
+	assert.Equal(t, 4, saved.SummarizedCount)
+
+	assert.NoError(t, m.saveSummary(session.ID, 4, "新的摘要", 6))
+	saved, _, _ = m.store.GetSession(session.ID)
+	assert.Equal(t, "新的摘要", saved.Summary)
+	assert.Equal(t, 6, saved.SummarizedCount)
+}
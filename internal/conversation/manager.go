@@ -0,0 +1,353 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/telepace/voiceflow/internal/llm"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/pkg/logger"
+)
+
+const (
+	// summaryPrefix 摘要以系统消息的形式放在历史消息的最前面
+	summaryPrefix = "以下是之前对话的摘要：\n"
+	summaryPrompt = "请将下面的对话总结为简洁的摘要，保留用户的关键信息、偏好和尚未完成的事项，不超过 200 字，只输出摘要内容。"
+)
+
+// Options 控制历史消息的组装方式
+type Options struct {
+	// MaxHistoryTokens 发送给 LLM 的历史消息的 token 预算，0 表示不限制
+	MaxHistoryTokens int
+	// Summarize 为 true 时超出预算的较早对话由 LLM 总结为摘要，否则直接丢弃
+	Summarize bool
+}
+
+// Manager 记录对话的每一轮，并为 LLM 组装历史消息
+type Manager struct {
+	store Store
+	llm   llm.Service
+	opts  Options
+
+	mu    sync.Mutex
+	locks map[string]*sessionLock
+}
+
+// sessionLock 为会话级的锁，refs 为持有或等待该锁的调用数
+type sessionLock struct {
+	sync.Mutex
+	refs int
+}
+
+// New 创建对话管理器，llmService 仅用于生成摘要，可以为 nil
+func New(store Store, llmService llm.Service, opts Options) *Manager {
+	return &Manager{
+		store: store,
+		llm:   llmService,
+		opts:  opts,
+		locks: make(map[string]*sessionLock),
+	}
+}
+
+// ValidSessionID 判断客户端提供的会话 ID 是否合法
+func ValidSessionID(id string) bool {
+	return sessionIDPattern.MatchString(id)
+}
+
+// lock 获取会话级的锁并返回释放函数，同一会话的写入和摘要串行执行
+// 没有调用持有或等待时删除该锁，locks 只包含正在访问的会话
+func (m *Manager) lock(sessionID string) (unlock func()) {
+	m.mu.Lock()
+	l, ok := m.locks[sessionID]
+	if !ok {
+		l = &sessionLock{}
+		m.locks[sessionID] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		m.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(m.locks, sessionID)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// StartEviction 定期从存储中移除空闲超过 idle 的会话，直到 ctx 取消，idle 不大于 0 时不移除
+func (m *Manager) StartEviction(ctx context.Context, idle time.Duration) {
+	if idle <= 0 {
+		return
+	}
+	interval := idle / 4
+	if interval > 10*time.Minute {
+		interval = 10 * time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if n := m.store.Evict(time.Now().Add(-idle)); n > 0 {
+					logger.Infof("移除了 %d 个空闲会话", n)
+				}
+			}
+		}
+	}()
+}
+
+// Open 打开会话，sessionID 为空时创建新会话，已存在时返回全部历史消息以便客户端恢复对话
+func (m *Manager) Open(sessionID, userID string) (session *models.Session, history []models.Message, resumed bool, err error) {
+	if sessionID == "" {
+		sessionID = uuid.New().String()
+	} else if !ValidSessionID(sessionID) {
+		return nil, nil, false, fmt.Errorf("会话 ID 只能包含字母、数字、下划线和连字符，长度不超过 128")
+	}
+
+	defer m.lock(sessionID)()
+
+	session, found, err := m.store.GetSession(sessionID)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if found {
+		history, err = m.store.Messages(sessionID)
+		return session, history, true, err
+	}
+
+	now := time.Now()
+	session = &models.Session{ID: sessionID, UserID: userID, CreatedAt: now, UpdatedAt: now}
+	if err := m.store.SaveSession(session); err != nil {
+		return nil, nil, false, err
+	}
+	return session, nil, false, nil
+}
+
+// Configure 设置会话使用的角色和系统提示词变量，persona 为空且 variables 为 nil 时保持不变
+func (m *Manager) Configure(sessionID, persona string, variables map[string]string) (*models.Session, error) {
+	defer m.lock(sessionID)()
+
+	session, found, err := m.store.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("会话不存在: %s", sessionID)
+	}
+	if persona == "" && variables == nil {
+		return session, nil
+	}
+	if persona != "" {
+		session.Persona = persona
+	}
+	if variables != nil {
+		session.Variables = variables
+	}
+	session.UpdatedAt = time.Now()
+	if err := m.store.SaveSession(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Append 记录一轮对话，sender 为 user 或 assistant
+func (m *Manager) Append(sessionID, sender, content, audioURL string) (models.Message, error) {
+	defer m.lock(sessionID)()
+
+	msg := models.Message{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		Sender:    sender,
+		Content:   content,
+		AudioURL:  audioURL,
+		CreatedAt: time.Now(),
+	}
+	if err := m.store.AppendMessage(msg); err != nil {
+		return models.Message{}, err
+	}
+	m.touch(sessionID)
+	return msg, nil
+}
+
+// SetAudioURL 为已记录的消息补充音频地址，音频通常在文本之后才存储完成
+func (m *Manager) SetAudioURL(sessionID, messageID, audioURL string) error {
+	defer m.lock(sessionID)()
+
+	messages, err := m.store.Messages(sessionID)
+	if err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		if msg.ID == messageID {
+			msg.AudioURL = audioURL
+			return m.store.UpdateMessage(msg)
+		}
+	}
+	return fmt.Errorf("消息不存在: %s", messageID)
+}
+
+// touch 更新会话的最后活动时间
+func (m *Manager) touch(sessionID string) {
+	session, found, err := m.store.GetSession(sessionID)
+	if err != nil || !found {
+		return
+	}
+	session.UpdatedAt = time.Now()
+	if err := m.store.SaveSession(session); err != nil {
+		logger.Warnf("更新会话 %s 失败: %v", sessionID, err)
+	}
+}
+
+// History 从最近的消息开始向前选取，直到用完 token 预算
+// 超出预算的较早消息在开启摘要时合并进会话摘要，摘要作为系统消息放在历史消息之前
+// systemPrompt 非空时作为第一条系统消息，同样计入预算
+// 生成摘要需要调用 LLM，期间不持有会话锁，不阻塞同一会话的写入
+func (m *Manager) History(ctx context.Context, sessionID, systemPrompt string) ([]models.ChatMessage, error) {
+	session, messages, err := m.snapshot(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	start := m.window(messages, EstimateTokens(systemPrompt)+EstimateTokens(session.Summary))
+	// 已被摘要覆盖的消息不再重复发送
+	if session.Summary != "" && start < session.SummarizedCount {
+		start = session.SummarizedCount
+	}
+
+	if m.opts.Summarize && m.llm != nil && start > session.SummarizedCount {
+		summary, err := m.summarize(ctx, session.Summary, messages[session.SummarizedCount:start])
+		if err != nil {
+			// 摘要失败时仅丢弃超出预算的消息，不影响本轮对话
+			logger.Warnf("生成对话摘要失败: %v", err)
+		} else {
+			if err := m.saveSummary(sessionID, session.SummarizedCount, summary, start); err != nil {
+				logger.Warnf("保存对话摘要失败: %v", err)
+			}
+			session.Summary = summary
+			session.SummarizedCount = start
+		}
+	}
+
+	history := make([]models.ChatMessage, 0, len(messages)-start+2)
+	if systemPrompt != "" {
+		history = append(history, models.ChatMessage{Role: models.RoleSystem, Content: systemPrompt})
+	}
+	if session.Summary != "" {
+		history = append(history, models.ChatMessage{Role: models.RoleSystem, Content: summaryPrefix + session.Summary})
+	}
+	for _, msg := range messages[start:] {
+		history = append(history, models.ChatMessage{Role: msg.Sender, Content: msg.Content})
+	}
+	return history, nil
+}
+
+// snapshot 在会话锁内读取会话和全部消息
+func (m *Manager) snapshot(sessionID string) (*models.Session, []models.Message, error) {
+	defer m.lock(sessionID)()
+
+	session, found, err := m.store.GetSession(sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, nil, fmt.Errorf("会话不存在: %s", sessionID)
+	}
+	messages, err := m.store.Messages(sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, messages, nil
+}
+
+// saveSummary 保存覆盖了前 count 条消息的摘要，摘要期间已被其他调用更新时放弃本次结果
+func (m *Manager) saveSummary(sessionID string, previousCount int, summary string, count int) error {
+	defer m.lock(sessionID)()
+
+	session, found, err := m.store.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("会话不存在: %s", sessionID)
+	}
+	if session.SummarizedCount != previousCount {
+		logger.Debugf("会话 %s 的摘要已被更新，放弃本次摘要", sessionID)
+		return nil
+	}
+	session.Summary = summary
+	session.SummarizedCount = count
+	return m.store.SaveSession(session)
+}
+
+// window 返回预算内最早一条消息的下标，最近的一条消息总会被保留
+func (m *Manager) window(messages []models.Message, reserved int) int {
+	start := len(messages)
+	used := reserved
+	for start > 0 {
+		cost := EstimateTokens(messages[start-1].Content)
+		if m.opts.MaxHistoryTokens > 0 && used+cost > m.opts.MaxHistoryTokens && start < len(messages) {
+			break
+		}
+		used += cost
+		start--
+	}
+	return start
+}
+
+// summarize 将之前的摘要和新移出窗口的消息合并为新的摘要
+func (m *Manager) summarize(ctx context.Context, previous string, messages []models.Message) (string, error) {
+	var b strings.Builder
+	if previous != "" {
+		b.WriteString("之前的摘要：")
+		b.WriteString(previous)
+		b.WriteString("\n\n")
+	}
+	for _, msg := range messages {
+		if msg.Sender == models.RoleAssistant {
+			b.WriteString("助手：")
+		} else {
+			b.WriteString("用户：")
+		}
+		b.WriteString(msg.Content)
+		b.WriteString("\n")
+	}
+
+	reply, err := llm.Chat(ctx, m.llm, []models.ChatMessage{
+		{Role: models.RoleSystem, Content: summaryPrompt},
+		{Role: models.RoleUser, Content: b.String()},
+	}, models.ChatOptions{})
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(reply.Content)
+	if summary == "" {
+		return "", fmt.Errorf("LLM 返回了空摘要")
+	}
+	return summary, nil
+}
+
+// EstimateTokens 粗略估算文本的 token 数：汉字等表意文字按每字 1 个，其他字符按每 4 个 1 个
+func EstimateTokens(text string) int {
+	ideographs, others := 0, 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			ideographs++
+		} else {
+			others++
+		}
+	}
+	return ideographs + (others+3)/4
+}
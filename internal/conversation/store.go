@@ -0,0 +1,306 @@
+// Package conversation 记录多轮对话，并在 token 预算内组装发送给 LLM 的历史消息
+package conversation
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+// Store 保存会话及其消息
+type Store interface {
+	// GetSession 返回会话，不存在时 found 为 false
+	GetSession(id string) (session *models.Session, found bool, err error)
+	SaveSession(session *models.Session) error
+	AppendMessage(msg models.Message) error
+	// UpdateMessage 按 ID 替换已保存的消息
+	UpdateMessage(msg models.Message) error
+	Messages(sessionID string) ([]models.Message, error)
+	// Evict 从内存中移除最后活动时间早于 before 的会话，返回移除的数量
+	Evict(before time.Time) int
+}
+
+type record struct {
+	Session  models.Session   `json:"session"`
+	Messages []models.Message `json:"-"` // FileStore 将消息单独写入消息文件
+}
+
+// MemoryStore 将对话保存在进程内存中，客户端断线重连后仍可继续，服务重启或会话被移除后丢失
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records map[string]*record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]*record)}
+}
+
+func (s *MemoryStore) GetSession(id string) (*models.Session, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.records[id]
+	if !ok {
+		return nil, false, nil
+	}
+	session := r.Session
+	return &session, true, nil
+}
+
+func (s *MemoryStore) SaveSession(session *models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.records[session.ID]; ok {
+		r.Session = *session
+	} else {
+		s.records[session.ID] = &record{Session: *session}
+	}
+	return nil
+}
+
+func (s *MemoryStore) AppendMessage(msg models.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[msg.SessionID]
+	if !ok {
+		return fmt.Errorf("会话不存在: %s", msg.SessionID)
+	}
+	r.Messages = append(r.Messages, msg)
+	return nil
+}
+
+func (s *MemoryStore) UpdateMessage(msg models.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[msg.SessionID]
+	if !ok {
+		return fmt.Errorf("会话不存在: %s", msg.SessionID)
+	}
+	for i := range r.Messages {
+		if r.Messages[i].ID == msg.ID {
+			r.Messages[i] = msg
+			return nil
+		}
+	}
+	return fmt.Errorf("消息不存在: %s", msg.ID)
+}
+
+func (s *MemoryStore) Messages(sessionID string) ([]models.Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.records[sessionID]
+	if !ok {
+		return nil, nil
+	}
+	return append([]models.Message(nil), r.Messages...), nil
+}
+
+func (s *MemoryStore) Evict(before time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	evicted := 0
+	for id, r := range s.records {
+		if r.Session.UpdatedAt.Before(before) {
+			delete(s.records, id)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// sessionIDPattern 限制会话 ID 的字符，避免拼接文件路径时越出存储目录
+var sessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// FileStore 在内存之外将会话写入文件，服务重启或会话被移出内存后仍可恢复
+// 会话信息写入 path/<session_id>.json，消息逐条追加到 path/<session_id>.jsonl，
+// 更新消息时追加一条相同 ID 的新记录，读取时以最后一条为准
+type FileStore struct {
+	*MemoryStore
+	path string
+	mu   sync.Mutex // 串行化文件写入
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("创建对话存储目录失败: %v", err)
+	}
+	return &FileStore{MemoryStore: NewMemoryStore(), path: path}, nil
+}
+
+func (s *FileStore) GetSession(id string) (*models.Session, bool, error) {
+	if session, ok, _ := s.MemoryStore.GetSession(id); ok {
+		return session, true, nil
+	}
+	if err := s.load(id); err != nil {
+		return nil, false, err
+	}
+	return s.MemoryStore.GetSession(id)
+}
+
+func (s *FileStore) SaveSession(session *models.Session) error {
+	if !sessionIDPattern.MatchString(session.ID) {
+		return fmt.Errorf("会话 ID 只能包含字母、数字、下划线和连字符: %q", session.ID)
+	}
+	// 先读入磁盘上已有的消息，避免被移出内存的会话只剩会话信息
+	if _, _, err := s.GetSession(session.ID); err != nil {
+		return err
+	}
+	if err := s.MemoryStore.SaveSession(session); err != nil {
+		return err
+	}
+	return s.writeSession(session)
+}
+
+func (s *FileStore) AppendMessage(msg models.Message) error {
+	if _, _, err := s.GetSession(msg.SessionID); err != nil {
+		return err
+	}
+	if err := s.MemoryStore.AppendMessage(msg); err != nil {
+		return err
+	}
+	return s.appendMessages(msg.SessionID, msg)
+}
+
+func (s *FileStore) UpdateMessage(msg models.Message) error {
+	if _, _, err := s.GetSession(msg.SessionID); err != nil {
+		return err
+	}
+	if err := s.MemoryStore.UpdateMessage(msg); err != nil {
+		return err
+	}
+	return s.appendMessages(msg.SessionID, msg)
+}
+
+func (s *FileStore) Messages(sessionID string) ([]models.Message, error) {
+	if _, _, err := s.GetSession(sessionID); err != nil {
+		return nil, err
+	}
+	return s.MemoryStore.Messages(sessionID)
+}
+
+// Evict 只释放内存，会话仍保留在文件中，下次访问时重新读取
+func (s *FileStore) Evict(before time.Time) int {
+	return s.MemoryStore.Evict(before)
+}
+
+func (s *FileStore) file(id string) string {
+	return filepath.Join(s.path, id+".json")
+}
+
+func (s *FileStore) messageFile(id string) string {
+	return filepath.Join(s.path, id+".jsonl")
+}
+
+// load 从磁盘读取会话和消息，文件不存在时不报错
+func (s *FileStore) load(id string) error {
+	if !sessionIDPattern.MatchString(id) {
+		return nil
+	}
+	data, err := os.ReadFile(s.file(id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取会话 %s 失败: %v", id, err)
+	}
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return fmt.Errorf("解析会话 %s 失败: %v", id, err)
+	}
+
+	if r.Messages, err = s.readMessages(id); err != nil {
+		return err
+	}
+
+	s.MemoryStore.mu.Lock()
+	defer s.MemoryStore.mu.Unlock()
+	if _, ok := s.records[id]; !ok {
+		s.records[id] = &r
+	}
+	return nil
+}
+
+// readMessages 读取消息文件，同一 ID 的多条记录以最后一条为准，保持首次出现的顺序
+func (s *FileStore) readMessages(id string) ([]models.Message, error) {
+	f, err := os.Open(s.messageFile(id))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取会话 %s 的消息失败: %v", id, err)
+	}
+	defer f.Close()
+
+	var messages []models.Message
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		var msg models.Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			// 进程在写入一半时退出只会损坏最后一行，跳过即可
+			continue
+		}
+		if i, ok := index[msg.ID]; ok {
+			messages[i] = msg
+			continue
+		}
+		index[msg.ID] = len(messages)
+		messages = append(messages, msg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取会话 %s 的消息失败: %v", id, err)
+	}
+	return messages, nil
+}
+
+// appendMessages 将消息追加到消息文件的末尾，每条消息占一行
+func (s *FileStore) appendMessages(id string, messages ...models.Message) error {
+	var data []byte
+	for _, msg := range messages {
+		line, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.messageFile(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("写入会话 %s 的消息失败: %v", id, err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("写入会话 %s 的消息失败: %v", id, err)
+	}
+	return nil
+}
+
+// writeSession 写入会话信息，先写临时文件再重命名，避免写了一半时进程退出导致文件损坏
+func (s *FileStore) writeSession(session *models.Session) error {
+	data, err := json.MarshalIndent(record{Session: *session}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	filePath := s.file(session.ID)
+	tempPath := filePath + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("写入会话 %s 失败: %v", session.ID, err)
+	}
+	if err := os.Rename(tempPath, filePath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("写入会话 %s 失败: %v", session.ID, err)
+	}
+	return nil
+}
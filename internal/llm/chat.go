@@ -0,0 +1,69 @@
+// internal/llm/chat.go
+
+package llm
+
+import (
+	"context"
+	"strings"
+
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+// ChatService 由支持多轮对话的提供商实现
+type ChatService interface {
+	Chat(ctx context.Context, messages []models.ChatMessage, opts models.ChatOptions) (models.ChatMessage, error)
+}
+
+// StreamingService 由能够边生成边返回文本的提供商实现
+// 返回的通道在生成结束或 ctx 取消后关闭
+type StreamingService interface {
+	ChatStream(ctx context.Context, messages []models.ChatMessage, opts models.ChatOptions) (<-chan models.ChatChunk, error)
+}
+
+// Chat 发送多轮对话并返回助手的回复
+// 提供商不支持多轮对话时，将对话内容拼接为单个提示词
+func Chat(ctx context.Context, svc Service, messages []models.ChatMessage, opts models.ChatOptions) (models.ChatMessage, error) {
+	if chat, ok := svc.(ChatService); ok {
+		return chat.Chat(ctx, messages, opts)
+	}
+	content, err := svc.GetResponse(flatten(messages))
+	if err != nil {
+		return models.ChatMessage{}, err
+	}
+	return models.ChatMessage{Role: models.RoleAssistant, Content: content}, nil
+}
+
+// ChatStream 以流的方式返回助手的回复
+// 提供商不支持流式输出时，完整回复作为单个文本块返回
+func ChatStream(ctx context.Context, svc Service, messages []models.ChatMessage, opts models.ChatOptions) (<-chan models.ChatChunk, error) {
+	if streaming, ok := svc.(StreamingService); ok {
+		return streaming.ChatStream(ctx, messages, opts)
+	}
+
+	chunks := make(chan models.ChatChunk, 1)
+	go func() {
+		defer close(chunks)
+		reply, err := Chat(ctx, svc, messages, opts)
+		if err != nil {
+			chunks <- models.ChatChunk{Err: err}
+			return
+		}
+		chunks <- models.ChatChunk{Content: reply.Content, ToolCalls: reply.ToolCalls}
+	}()
+	return chunks, nil
+}
+
+// flatten 将对话拼接为纯文本，只有一条用户消息时直接返回其内容
+func flatten(messages []models.ChatMessage) string {
+	if len(messages) == 1 {
+		return messages[0].Content
+	}
+	var b strings.Builder
+	for _, m := range messages {
+		b.WriteString(m.Role)
+		b.WriteString(": ")
+		b.WriteString(m.Content)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
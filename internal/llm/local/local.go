@@ -1,21 +1,355 @@
 package local
 
-// LocalLLM 结构体用于存储本地模型交互的必要信息
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/telepace/voiceflow/internal/llm/openai"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/pkg/config"
+	"github.com/telepace/voiceflow/pkg/logger"
+)
+
+const (
+	backendOllama   = "ollama"
+	backendLlamaCpp = "llamacpp"
+
+	defaultOllamaEndpoint   = "http://localhost:11434"
+	defaultLlamaCppEndpoint = "http://localhost:8080"
+
+	// streamBufferSize 流式输出时缓冲的文本块数量
+	streamBufferSize = 64
+)
+
+// LocalLLM 对接本机或内网部署的离线模型服务
+// ollama 使用原生的 /api/chat 接口，可以按请求设置上下文长度；
+// llamacpp 使用 llama.cpp server 的 OpenAI 兼容接口，上下文长度由服务端的启动参数 -c 决定
 type LocalLLM struct {
-	// 可添加需要的字段，如本地模型的路径等
+	endpoint  string
+	model     string
+	options   ollamaOptions
+	keepAlive string
+
+	client *http.Client
+	// streamClient 不设置整体超时，流式输出的时长由 ctx 控制
+	streamClient *http.Client
+
+	// compat 非空时所有请求交给 llama.cpp server 的 OpenAI 兼容接口
+	compat *openai.OpenAILLM
+}
+
+// ollamaOptions 对应 /api/chat 请求中的 options，零值的参数使用模型的默认值
+type ollamaOptions struct {
+	NumCtx        int     `json:"num_ctx,omitempty"`
+	Temperature   float64 `json:"temperature,omitempty"`
+	TopP          float64 `json:"top_p,omitempty"`
+	TopK          int     `json:"top_k,omitempty"`
+	RepeatPenalty float64 `json:"repeat_penalty,omitempty"`
+	NumPredict    int     `json:"num_predict,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// ollamaToolCall 与 OpenAI 的区别在于 arguments 是 JSON 对象，并且没有调用 ID
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaTool struct {
+	Type     string      `json:"type"`
+	Function models.Tool `json:"function"`
+}
+
+type chatRequest struct {
+	Model     string          `json:"model"`
+	Messages  []ollamaMessage `json:"messages"`
+	Stream    bool            `json:"stream"`
+	Options   ollamaOptions   `json:"options"`
+	KeepAlive string          `json:"keep_alive,omitempty"`
+	Tools     []ollamaTool    `json:"tools,omitempty"`
+}
+
+// chatResponse 为非流式响应，流式输出时每行一个同样结构的 JSON，done 为 true 表示结束
+type chatResponse struct {
+	Message ollamaMessage `json:"message"`
+	Done    bool          `json:"done"`
+	Error   string        `json:"error"`
 }
 
 // NewLocalLLM 创建一个新的 LocalLLM 实例
 func NewLocalLLM() *LocalLLM {
-	return &LocalLLM{
-		// 初始化字段
+	cfg, err := config.GetConfig()
+	if err != nil {
+		logger.Fatalf("配置初始化失败: %v", err)
+	}
+
+	c := cfg.LocalLLM
+	if c.Backend == "" {
+		c.Backend = backendOllama
+	}
+	timeout := time.Duration(c.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 120 * time.Second
+	}
+
+	switch c.Backend {
+	case backendOllama:
+		if c.Endpoint == "" {
+			c.Endpoint = defaultOllamaEndpoint
+		}
+		if c.Model == "" {
+			logger.Fatalf("本地 LLM 使用 ollama 时必须配置 local_llm.model")
+		}
+		return &LocalLLM{
+			endpoint: strings.TrimRight(c.Endpoint, "/"),
+			model:    c.Model,
+			options: ollamaOptions{
+				NumCtx:        c.ContextSize,
+				Temperature:   c.Temperature,
+				TopP:          c.TopP,
+				TopK:          c.TopK,
+				RepeatPenalty: c.RepeatPenalty,
+				NumPredict:    c.MaxTokens,
+			},
+			keepAlive:    c.KeepAlive,
+			client:       &http.Client{Timeout: timeout},
+			streamClient: &http.Client{},
+		}
+	case backendLlamaCpp:
+		if c.Endpoint == "" {
+			c.Endpoint = defaultLlamaCppEndpoint
+		}
+		if c.ContextSize > 0 {
+			logger.Infof("llama.cpp 的上下文长度由服务端启动参数 -c 决定，忽略 local_llm.context_size")
+		}
+		// llama.cpp server 只加载一个模型，model 仅用于日志，为空时填入占位名称
+		model := c.Model
+		if model == "" {
+			model = "local"
+		}
+		return &LocalLLM{compat: openai.New(openai.Options{
+			BaseURL:       strings.TrimRight(c.Endpoint, "/") + "/v1",
+			Model:         model,
+			Temperature:   c.Temperature,
+			TopP:          c.TopP,
+			TopK:          c.TopK,
+			RepeatPenalty: c.RepeatPenalty,
+			MaxTokens:     c.MaxTokens,
+			Timeout:       timeout,
+		})}
+	default:
+		logger.Fatalf("未知的本地 LLM 后端: %s，可选值为 ollama、llamacpp", c.Backend)
+		return nil
 	}
 }
 
 // GetResponse 使用本地语言模型生成回复
 func (l *LocalLLM) GetResponse(prompt string) (string, error) {
-	// 使用本地模型生成回复的逻辑
-	// 这是一个简单的示例，实际可以使用 GPT-Neo、GPT-J 等模型
-	response := "This is a local LLM response for the prompt: " + prompt
-	return response, nil
+	reply, err := l.Chat(context.Background(), []models.ChatMessage{
+		{Role: models.RoleUser, Content: prompt},
+	}, models.ChatOptions{})
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+// Chat 发送多轮对话并返回模型的完整回复
+func (l *LocalLLM) Chat(ctx context.Context, messages []models.ChatMessage, opts models.ChatOptions) (models.ChatMessage, error) {
+	if l.compat != nil {
+		return l.compat.Chat(ctx, messages, opts)
+	}
+
+	resp, err := l.post(ctx, l.client, l.buildRequest(messages, opts, false))
+	if err != nil {
+		return models.ChatMessage{}, err
+	}
+	defer resp.Body.Close()
+
+	var result chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return models.ChatMessage{}, fmt.Errorf("解析响应失败: %v", err)
+	}
+	if result.Error != "" {
+		return models.ChatMessage{}, fmt.Errorf("ollama 错误: %s", result.Error)
+	}
+	return models.ChatMessage{
+		Role:      models.RoleAssistant,
+		Content:   result.Message.Content,
+		ToolCalls: fromOllamaToolCalls(result.Message.ToolCalls, 0),
+	}, nil
+}
+
+// ChatStream 流式调用 /api/chat，响应体每行一个 JSON，每收到一段文本立即通过通道返回
+func (l *LocalLLM) ChatStream(ctx context.Context, messages []models.ChatMessage, opts models.ChatOptions) (<-chan models.ChatChunk, error) {
+	if l.compat != nil {
+		return l.compat.ChatStream(ctx, messages, opts)
+	}
+
+	resp, err := l.post(ctx, l.streamClient, l.buildRequest(messages, opts, true))
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan models.ChatChunk, streamBufferSize)
+	go func() {
+		defer close(chunks)
+		defer resp.Body.Close()
+
+		send := func(chunk models.ChatChunk) error {
+			select {
+			case chunks <- chunk:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		toolCalls, err := readStream(resp.Body, func(content string) error {
+			return send(models.ChatChunk{Content: content})
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				chunks <- models.ChatChunk{Err: err}
+			}
+			return
+		}
+		if len(toolCalls) > 0 {
+			send(models.ChatChunk{ToolCalls: toolCalls})
+		}
+	}()
+	return chunks, nil
+}
+
+func (l *LocalLLM) buildRequest(messages []models.ChatMessage, opts models.ChatOptions, stream bool) chatRequest {
+	req := chatRequest{
+		Model:     l.model,
+		Stream:    stream,
+		Options:   l.options,
+		KeepAlive: l.keepAlive,
+	}
+	if opts.Model != "" {
+		req.Model = opts.Model
+	}
+	if opts.Temperature != 0 {
+		req.Options.Temperature = opts.Temperature
+	}
+	if opts.MaxTokens != 0 {
+		req.Options.NumPredict = opts.MaxTokens
+	}
+	for _, tool := range opts.Tools {
+		req.Tools = append(req.Tools, ollamaTool{Type: "function", Function: tool})
+	}
+	for _, msg := range messages {
+		req.Messages = append(req.Messages, toOllamaMessage(msg))
+	}
+	return req
+}
+
+// post 发送请求并检查状态码，调用方负责关闭响应体
+func (l *LocalLLM) post(ctx context.Context, client *http.Client, body chatRequest) (*http.Response, error) {
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("JSON序列化失败: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", l.endpoint+"/api/chat", bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求本地 LLM 服务失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		var result chatResponse
+		if json.Unmarshal(data, &result) == nil && result.Error != "" {
+			return nil, fmt.Errorf("ollama 错误(status %d): %s", resp.StatusCode, result.Error)
+		}
+		return nil, fmt.Errorf("ollama 错误(status %d): %s", resp.StatusCode, string(data))
+	}
+	return resp, nil
+}
+
+// readStream 逐行解析流式响应，返回输出过程中出现的全部工具调用
+func readStream(body io.Reader, onContent func(string) error) ([]models.ToolCall, error) {
+	var toolCalls []models.ToolCall
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var event chatResponse
+			if jsonErr := json.Unmarshal(line, &event); jsonErr != nil {
+				return nil, fmt.Errorf("解析流式响应失败: %v, 内容: %s", jsonErr, string(line))
+			}
+			if event.Error != "" {
+				return nil, fmt.Errorf("ollama 错误: %s", event.Error)
+			}
+			toolCalls = append(toolCalls, fromOllamaToolCalls(event.Message.ToolCalls, len(toolCalls))...)
+			if event.Message.Content != "" {
+				if err := onContent(event.Message.Content); err != nil {
+					return nil, err
+				}
+			}
+			if event.Done {
+				return toolCalls, nil
+			}
+		}
+		if err == io.EOF {
+			return toolCalls, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取流式响应失败: %v", err)
+		}
+	}
+}
+
+// toOllamaMessage 将工具调用的参数从 JSON 字符串转换为 ollama 需要的 JSON 对象
+func toOllamaMessage(msg models.ChatMessage) ollamaMessage {
+	out := ollamaMessage{Role: msg.Role, Content: msg.Content, ToolCallID: msg.ToolCallID}
+	for _, call := range msg.ToolCalls {
+		var tc ollamaToolCall
+		tc.Function.Name = call.Function.Name
+		tc.Function.Arguments = json.RawMessage(call.Function.Arguments)
+		if !json.Valid(tc.Function.Arguments) {
+			tc.Function.Arguments = json.RawMessage("{}")
+		}
+		out.ToolCalls = append(out.ToolCalls, tc)
+	}
+	return out
+}
+
+// fromOllamaToolCalls 转换为 OpenAI 格式的工具调用，ollama 不返回调用 ID，按顺序生成
+func fromOllamaToolCalls(calls []ollamaToolCall, offset int) []models.ToolCall {
+	var out []models.ToolCall
+	for i, call := range calls {
+		arguments := string(call.Function.Arguments)
+		if arguments == "" || arguments == "null" {
+			arguments = "{}"
+		}
+		out = append(out, models.ToolCall{
+			ID:       fmt.Sprintf("call_%d", offset+i),
+			Type:     "function",
+			Function: models.FunctionCall{Name: call.Function.Name, Arguments: arguments},
+		})
+	}
+	return out
 }
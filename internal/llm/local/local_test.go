@@ -0,0 +1,121 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/llm/openai"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+func newTestLLM(url string) *LocalLLM {
+	return &LocalLLM{
+		endpoint:     url,
+		model:        "qwen2.5:7b",
+		options:      ollamaOptions{NumCtx: 8192, Temperature: 0.7, TopK: 40},
+		client:       http.DefaultClient,
+		streamClient: http.DefaultClient,
+	}
+}
+
+func TestChat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+		var req chatRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "qwen2.5:7b", req.Model)
+		assert.False(t, req.Stream)
+		assert.Equal(t, 8192, req.Options.NumCtx)
+		assert.Equal(t, 40, req.Options.TopK)
+		assert.Equal(t, 0.2, req.Options.Temperature)
+		assert.Len(t, req.Messages, 3)
+		assert.JSONEq(t, `{"id":"42"}`, string(req.Messages[1].ToolCalls[0].Function.Arguments))
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"订单已发货"},"done":true}`)
+	}))
+	defer server.Close()
+
+	reply, err := newTestLLM(server.URL).Chat(context.Background(), []models.ChatMessage{
+		{Role: models.RoleUser, Content: "订单 42 到哪了"},
+		{Role: models.RoleAssistant, ToolCalls: []models.ToolCall{{
+			ID: "call_0", Type: "function",
+			Function: models.FunctionCall{Name: "check_order", Arguments: `{"id":"42"}`},
+		}}},
+		{Role: models.RoleTool, Content: "已发货", ToolCallID: "call_0"},
+	}, models.ChatOptions{Temperature: 0.2})
+	assert.NoError(t, err)
+	assert.Equal(t, models.RoleAssistant, reply.Role)
+	assert.Equal(t, "订单已发货", reply.Content)
+}
+
+func TestChatStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.True(t, req.Stream)
+		assert.Len(t, req.Tools, 1)
+		for _, line := range []string{
+			`{"message":{"role":"assistant","content":"稍"},"done":false}`,
+			`{"message":{"role":"assistant","content":"等"},"done":false}`,
+			`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"check_order","arguments":{"id":"42"}}}]},"done":false}`,
+			`{"message":{"role":"assistant","content":""},"done":true}`,
+		} {
+			fmt.Fprintln(w, line)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	chunks, err := newTestLLM(server.URL).ChatStream(context.Background(), []models.ChatMessage{
+		{Role: models.RoleUser, Content: "订单 42 到哪了"},
+	}, models.ChatOptions{Tools: []models.Tool{{Name: "check_order"}}})
+	assert.NoError(t, err)
+
+	var (
+		tokens    []string
+		toolCalls []models.ToolCall
+	)
+	for chunk := range chunks {
+		assert.NoError(t, chunk.Err)
+		if chunk.Content != "" {
+			tokens = append(tokens, chunk.Content)
+		}
+		toolCalls = append(toolCalls, chunk.ToolCalls...)
+	}
+	assert.Equal(t, []string{"稍", "等"}, tokens)
+	assert.Len(t, toolCalls, 1)
+	assert.Equal(t, "call_0", toolCalls[0].ID)
+	assert.JSONEq(t, `{"id":"42"}`, toolCalls[0].Function.Arguments)
+}
+
+func TestChatError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model \"qwen2.5:7b\" not found, try pulling it first"}`)
+	}))
+	defer server.Close()
+
+	_, err := newTestLLM(server.URL).GetResponse("你好")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not found")
+}
+
+func TestLlamaCppBackend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		var req map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, float64(40), req["top_k"])
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"你好"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	l := &LocalLLM{compat: openai.New(openai.Options{BaseURL: server.URL + "/v1", Model: "local", TopK: 40})}
+	reply, err := l.GetResponse("你好")
+	assert.NoError(t, err)
+	assert.Equal(t, "你好", reply)
+}
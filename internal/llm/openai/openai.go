@@ -1,21 +1,109 @@
 package openai
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/telepace/voiceflow/internal/models"
 	"github.com/telepace/voiceflow/pkg/config"
 	"github.com/telepace/voiceflow/pkg/logger"
 )
 
-// OpenAILLM 结构体存储 OpenAI 交互所需的信息
+const (
+	defaultBaseURL = "https://api.openai.com/v1"
+	defaultModel   = "gpt-4o-mini"
+	// streamBufferSize 流式输出时缓冲的文本块数量
+	streamBufferSize = 64
+)
+
+// OpenAILLM 对接 OpenAI 以及任意兼容 /chat/completions 的服务
 type OpenAILLM struct {
-	apiKey   string
-	endpoint string
+	apiKey        string
+	baseURL       string
+	model         string
+	temperature   float64
+	topP          float64
+	topK          int
+	repeatPenalty float64
+	maxTokens     int
+	client        *http.Client
+	// streamClient 不设置整体超时，流式输出的时长由 ctx 控制
+	streamClient *http.Client
+}
+
+// Options 为 OpenAI 兼容服务的连接和采样参数，零值表示使用服务端的默认值
+type Options struct {
+	APIKey      string
+	BaseURL     string
+	Model       string
+	Temperature float64
+	TopP        float64
+	// TopK、RepeatPenalty 不是 OpenAI 的标准参数，llama.cpp server 等本地服务支持
+	TopK          int
+	RepeatPenalty float64
+	MaxTokens     int
+	Timeout       time.Duration // 非流式请求的超时
+}
+
+// chatRequest 中未设置的采样参数不发送，由服务端使用模型的默认值
+type chatRequest struct {
+	Model         string               `json:"model"`
+	Messages      []models.ChatMessage `json:"messages"`
+	Temperature   float64              `json:"temperature,omitempty"`
+	TopP          float64              `json:"top_p,omitempty"`
+	TopK          int                  `json:"top_k,omitempty"`
+	RepeatPenalty float64              `json:"repeat_penalty,omitempty"`
+	MaxTokens     int                  `json:"max_tokens,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	Tools         []toolDefinition     `json:"tools,omitempty"`
+}
+
+type toolDefinition struct {
+	Type     string      `json:"type"`
+	Function models.Tool `json:"function"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message      models.ChatMessage `json:"message"`
+		FinishReason string             `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// streamResponse 对应 SSE 中每个 data 事件的内容
+type streamResponse struct {
+	Choices []struct {
+		Delta struct {
+			Content   string          `json:"content"`
+			ToolCalls []toolCallDelta `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// toolCallDelta 为流式输出中工具调用的增量，同一调用的参数分多次返回，通过 Index 拼接
+type toolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type errorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
 }
 
 // NewOpenAILLM 创建一个新的 OpenAILLM 实例
@@ -24,71 +112,231 @@ func NewOpenAILLM() *OpenAILLM {
 	if err != nil {
 		logger.Fatalf("配置初始化失败: %v", err)
 	}
-	return &OpenAILLM{
-		apiKey:   cfg.OpenAI.APIKey,
-		endpoint: "https://api.openai.com/v1/completions", // 具体API路径
+
+	c := cfg.OpenAI
+	return New(Options{
+		APIKey:      c.APIKey,
+		BaseURL:     c.BaseURL,
+		Model:       c.Model,
+		Temperature: c.Temperature,
+		MaxTokens:   c.MaxTokens,
+		Timeout:     time.Duration(c.Timeout) * time.Second,
+	})
+}
+
+// New 使用指定的参数创建 OpenAILLM，BaseURL、Model 为空时使用 OpenAI 的默认值
+func New(opts Options) *OpenAILLM {
+	o := &OpenAILLM{
+		apiKey:        opts.APIKey,
+		baseURL:       strings.TrimRight(opts.BaseURL, "/"),
+		model:         opts.Model,
+		temperature:   opts.Temperature,
+		topP:          opts.TopP,
+		topK:          opts.TopK,
+		repeatPenalty: opts.RepeatPenalty,
+		maxTokens:     opts.MaxTokens,
+		streamClient:  &http.Client{},
+	}
+	if o.baseURL == "" {
+		o.baseURL = defaultBaseURL
+	}
+	if o.model == "" {
+		o.model = defaultModel
 	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	o.client = &http.Client{Timeout: timeout}
+	return o
 }
 
-// GetResponse 调用 OpenAI API，获取对话模型的回复
+// GetResponse 以单轮对话的方式获取模型的回复
 func (o *OpenAILLM) GetResponse(prompt string) (string, error) {
-	if o.apiKey == "" {
-		return "", fmt.Errorf("OpenAI API key not configured")
+	reply, err := o.Chat(context.Background(), []models.ChatMessage{
+		{Role: models.RoleUser, Content: prompt},
+	}, models.ChatOptions{})
+	if err != nil {
+		return "", err
 	}
+	return reply.Content, nil
+}
 
-	url := o.endpoint
+// Chat 调用 /chat/completions 获取助手的完整回复
+func (o *OpenAILLM) Chat(ctx context.Context, messages []models.ChatMessage, opts models.ChatOptions) (models.ChatMessage, error) {
+	resp, err := o.post(ctx, o.client, o.buildRequest(messages, opts, false))
+	if err != nil {
+		return models.ChatMessage{}, err
+	}
+	defer resp.Body.Close()
 
-	requestBody := map[string]interface{}{
-		"model": "gpt-3.5-turbo",
-		"messages": []map[string]string{
-			{
-				"role":    "user",
-				"content": prompt,
-			},
-		},
-		"temperature": 0.7,
+	var result chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return models.ChatMessage{}, fmt.Errorf("解析响应失败: %v", err)
 	}
+	if len(result.Choices) == 0 {
+		return models.ChatMessage{}, fmt.Errorf("OpenAI 未返回任何回复")
+	}
+	reply := result.Choices[0].Message
+	if reply.Role == "" {
+		reply.Role = models.RoleAssistant
+	}
+	return reply, nil
+}
 
-	jsonData, err := json.Marshal(requestBody)
+// ChatStream 以 SSE 流式调用 /chat/completions，每收到一段增量文本立即通过通道返回
+// 模型请求调用工具时，拼接完整的调用后在最后一个文本块中返回
+func (o *OpenAILLM) ChatStream(ctx context.Context, messages []models.ChatMessage, opts models.ChatOptions) (<-chan models.ChatChunk, error) {
+	resp, err := o.post(ctx, o.streamClient, o.buildRequest(messages, opts, true))
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, err
+	}
+
+	chunks := make(chan models.ChatChunk, streamBufferSize)
+	go func() {
+		defer close(chunks)
+		defer resp.Body.Close()
+
+		send := func(chunk models.ChatChunk) error {
+			select {
+			case chunks <- chunk:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		toolCalls, err := readStream(resp.Body, func(content string) error {
+			return send(models.ChatChunk{Content: content})
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				chunks <- models.ChatChunk{Err: err}
+			}
+			return
+		}
+		if len(toolCalls) > 0 {
+			send(models.ChatChunk{ToolCalls: toolCalls})
+		}
+	}()
+	return chunks, nil
+}
+
+func (o *OpenAILLM) buildRequest(messages []models.ChatMessage, opts models.ChatOptions, stream bool) chatRequest {
+	req := chatRequest{
+		Model:         o.model,
+		Messages:      messages,
+		Temperature:   o.temperature,
+		TopP:          o.topP,
+		TopK:          o.topK,
+		RepeatPenalty: o.repeatPenalty,
+		MaxTokens:     o.maxTokens,
+		Stream:        stream,
+	}
+	if opts.Model != "" {
+		req.Model = opts.Model
+	}
+	if opts.Temperature != 0 {
+		req.Temperature = opts.Temperature
 	}
+	if opts.MaxTokens != 0 {
+		req.MaxTokens = opts.MaxTokens
+	}
+	for _, tool := range opts.Tools {
+		req.Tools = append(req.Tools, toolDefinition{Type: "function", Function: tool})
+	}
+	return req
+}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+// post 发送请求并检查状态码，调用方负责关闭响应体
+func (o *OpenAILLM) post(ctx context.Context, client *http.Client, body chatRequest) (*http.Response, error) {
+	requestBody, err := json.Marshal(body)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("JSON序列化失败: %v", err)
 	}
 
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/chat/completions", bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	if body.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	// 自建的兼容服务通常不需要鉴权
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("发送请求失败: %v", err)
 	}
-	defer resp.Body.Close()
-
 	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return "", fmt.Errorf("OpenAI API error (status %d): %s", resp.StatusCode, string(body))
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		var apiErr errorResponse
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+			return nil, fmt.Errorf("OpenAI API 错误(status %d): %s", resp.StatusCode, apiErr.Error.Message)
+		}
+		return nil, fmt.Errorf("OpenAI API 错误(status %d): %s", resp.StatusCode, string(data))
 	}
+	return resp, nil
+}
 
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
+// readStream 解析 SSE 响应，data: [DONE] 表示输出结束，返回拼接完整的工具调用
+func readStream(body io.Reader, onContent func(string) error) ([]models.ToolCall, error) {
+	var toolCalls []models.ToolCall
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadString('\n')
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "data:") {
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "[DONE]" {
+				return toolCalls, nil
+			}
+			var event streamResponse
+			if jsonErr := json.Unmarshal([]byte(data), &event); jsonErr != nil {
+				var apiErr errorResponse
+				if json.Unmarshal([]byte(data), &apiErr) == nil && apiErr.Error.Message != "" {
+					return nil, fmt.Errorf("OpenAI API 错误: %s", apiErr.Error.Message)
+				}
+				return nil, fmt.Errorf("解析流式响应失败: %v, 内容: %s", jsonErr, data)
+			}
+			for _, choice := range event.Choices {
+				for _, delta := range choice.Delta.ToolCalls {
+					toolCalls = mergeToolCall(toolCalls, delta)
+				}
+				if choice.Delta.Content == "" {
+					continue
+				}
+				if err := onContent(choice.Delta.Content); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err == io.EOF {
+			// 部分兼容服务不发送 [DONE]，直接关闭连接
+			return toolCalls, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取流式响应失败: %v", err)
+		}
 	}
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+// mergeToolCall 将一段工具调用增量合并到对应 Index 的调用中
+func mergeToolCall(calls []models.ToolCall, delta toolCallDelta) []models.ToolCall {
+	for len(calls) <= delta.Index {
+		calls = append(calls, models.ToolCall{Type: "function"})
 	}
-
-	if len(result.Choices) == 0 {
-		return "", fmt.Errorf("no response from OpenAI")
+	call := &calls[delta.Index]
+	if delta.ID != "" {
+		call.ID = delta.ID
 	}
-
-	return result.Choices[0].Message.Content, nil
+	if delta.Type != "" {
+		call.Type = delta.Type
+	}
+	call.Function.Name += delta.Function.Name
+	call.Function.Arguments += delta.Function.Arguments
+	return calls
 }
@@ -0,0 +1,163 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+func newTestLLM(url string) *OpenAILLM {
+	return &OpenAILLM{
+		baseURL:      url,
+		model:        "test-model",
+		temperature:  0.7,
+		client:       http.DefaultClient,
+		streamClient: http.DefaultClient,
+	}
+}
+
+func TestChat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+		var req chatRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "override-model", req.Model)
+		assert.Equal(t, 0.7, req.Temperature)
+		assert.Len(t, req.Messages, 2)
+		assert.False(t, req.Stream)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"你好！"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	reply, err := newTestLLM(server.URL).Chat(context.Background(), []models.ChatMessage{
+		{Role: models.RoleSystem, Content: "你是一个助手"},
+		{Role: models.RoleUser, Content: "你好"},
+	}, models.ChatOptions{Model: "override-model"})
+	assert.NoError(t, err)
+	assert.Equal(t, models.RoleAssistant, reply.Role)
+	assert.Equal(t, "你好！", reply.Content)
+}
+
+func TestChatOmitsUnsetSamplingParams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		// 未配置时交给服务端的默认值，llama.cpp 等本地服务收到 0 会退化为贪心解码
+		assert.NotContains(t, body, "temperature")
+		assert.NotContains(t, body, "top_p")
+		assert.NotContains(t, body, "max_tokens")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"好的"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	o := New(Options{BaseURL: server.URL})
+	_, err := o.Chat(context.Background(), []models.ChatMessage{{Role: models.RoleUser, Content: "你好"}}, models.ChatOptions{})
+	assert.NoError(t, err)
+}
+
+func TestChatStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.True(t, req.Stream)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, token := range []string{"你", "好", "！"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", token)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	chunks, err := newTestLLM(server.URL).ChatStream(context.Background(), []models.ChatMessage{
+		{Role: models.RoleUser, Content: "你好"},
+	}, models.ChatOptions{})
+	assert.NoError(t, err)
+
+	var tokens []string
+	for chunk := range chunks {
+		assert.NoError(t, chunk.Err)
+		tokens = append(tokens, chunk.Content)
+	}
+	assert.Equal(t, []string{"你", "好", "！"}, tokens)
+}
+
+func TestChatError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error"}}`)
+	}))
+	defer server.Close()
+
+	_, err := newTestLLM(server.URL).GetResponse("你好")
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "Incorrect API key provided"))
+}
+
+func TestChatToolCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Len(t, req.Tools, 1)
+		assert.Equal(t, "function", req.Tools[0].Type)
+		assert.Equal(t, "check_order", req.Tools[0].Function.Name)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":null,"tool_calls":[`+
+			`{"id":"call_1","type":"function","function":{"name":"check_order","arguments":"{\"id\":\"42\"}"}}]},`+
+			`"finish_reason":"tool_calls"}]}`)
+	}))
+	defer server.Close()
+
+	reply, err := newTestLLM(server.URL).Chat(context.Background(), []models.ChatMessage{
+		{Role: models.RoleUser, Content: "我的订单 42 到哪了"},
+	}, models.ChatOptions{Tools: []models.Tool{{Name: "check_order", Parameters: json.RawMessage(`{"type":"object"}`)}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "", reply.Content)
+	assert.Len(t, reply.ToolCalls, 1)
+	assert.Equal(t, "call_1", reply.ToolCalls[0].ID)
+	assert.Equal(t, `{"id":"42"}`, reply.ToolCalls[0].Function.Arguments)
+}
+
+func TestChatStreamToolCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"choices":[{"delta":{"content":"稍等。"}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"check_order","arguments":""}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"id\":"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"42\"}"}}]}}]}`,
+		}
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	chunks, err := newTestLLM(server.URL).ChatStream(context.Background(), []models.ChatMessage{
+		{Role: models.RoleUser, Content: "我的订单 42 到哪了"},
+	}, models.ChatOptions{})
+	assert.NoError(t, err)
+
+	var (
+		content   string
+		toolCalls []models.ToolCall
+	)
+	for chunk := range chunks {
+		assert.NoError(t, chunk.Err)
+		content += chunk.Content
+		toolCalls = append(toolCalls, chunk.ToolCalls...)
+	}
+	assert.Equal(t, "稍等。", content)
+	assert.Equal(t, []models.ToolCall{{
+		ID:       "call_1",
+		Type:     "function",
+		Function: models.FunctionCall{Name: "check_order", Arguments: `{"id":"42"}`},
+	}}, toolCalls)
+}
@@ -0,0 +1,148 @@
+// internal/llm/tools.go
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+// DefaultMaxToolRounds 为单轮对话中默认最多连续调用工具的次数
+const DefaultMaxToolRounds = 5
+
+// ToolHandler 执行一次工具调用，arguments 为模型生成的 JSON 参数，返回值作为工具结果发送给模型
+type ToolHandler func(ctx context.Context, arguments json.RawMessage) (string, error)
+
+// ToolEvent 描述对话中的一次工具调用，开始执行时 Done 为 false，执行结束后 Done 为 true 并带有结果
+// Err 非空表示调用失败
+type ToolEvent struct {
+	Call   models.ToolCall
+	Done   bool
+	Result string
+	Err    error
+}
+
+// ToolRegistry 保存可供模型调用的工具，可以并发使用
+type ToolRegistry struct {
+	mu       sync.RWMutex
+	order    []string
+	tools    map[string]models.Tool
+	handlers map[string]ToolHandler
+}
+
+// NewToolRegistry 创建一个空的工具注册表
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools:    make(map[string]models.Tool),
+		handlers: make(map[string]ToolHandler),
+	}
+}
+
+// Register 注册一个工具，同名工具已存在时返回错误
+func (r *ToolRegistry) Register(tool models.Tool, handler ToolHandler) error {
+	if tool.Name == "" {
+		return fmt.Errorf("工具名称不能为空")
+	}
+	if handler == nil {
+		return fmt.Errorf("工具 %s 缺少处理函数", tool.Name)
+	}
+	if len(tool.Parameters) > 0 && !json.Valid(tool.Parameters) {
+		return fmt.Errorf("工具 %s 的参数定义不是合法的 JSON", tool.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tools[tool.Name]; ok {
+		return fmt.Errorf("工具 %s 已注册", tool.Name)
+	}
+	r.order = append(r.order, tool.Name)
+	r.tools[tool.Name] = tool
+	r.handlers[tool.Name] = handler
+	return nil
+}
+
+// Tools 按注册顺序返回全部工具的定义
+func (r *ToolRegistry) Tools() []models.Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tools := make([]models.Tool, 0, len(r.order))
+	for _, name := range r.order {
+		tools = append(tools, r.tools[name])
+	}
+	return tools
+}
+
+// Call 执行模型发起的工具调用
+func (r *ToolRegistry) Call(ctx context.Context, call models.ToolCall) (string, error) {
+	r.mu.RLock()
+	handler, ok := r.handlers[call.Function.Name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("未知的工具: %s", call.Function.Name)
+	}
+
+	arguments := json.RawMessage(call.Function.Arguments)
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	if !json.Valid(arguments) {
+		return "", fmt.Errorf("工具 %s 的调用参数不是合法的 JSON", call.Function.Name)
+	}
+	return handler(ctx, arguments)
+}
+
+// RunToolCalls 依次执行助手请求的工具调用，返回需要追加到对话中的工具结果消息
+// 调用失败时把错误作为结果告诉模型，由模型决定如何回复用户
+func RunToolCalls(ctx context.Context, registry *ToolRegistry, calls []models.ToolCall, onEvent func(ToolEvent)) []models.ChatMessage {
+	results := make([]models.ChatMessage, 0, len(calls))
+	for _, call := range calls {
+		if onEvent != nil {
+			onEvent(ToolEvent{Call: call})
+		}
+		result, err := registry.Call(ctx, call)
+		if onEvent != nil {
+			onEvent(ToolEvent{Call: call, Done: true, Result: result, Err: err})
+		}
+		if err != nil {
+			result = fmt.Sprintf("工具调用失败: %v", err)
+		}
+		results = append(results, models.ChatMessage{
+			Role:       models.RoleTool,
+			Content:    result,
+			ToolCallID: call.ID,
+		})
+	}
+	return results
+}
+
+// ChatWithTools 发送多轮对话，模型请求调用工具时执行工具并把结果发回模型，直到得到最终回复
+// maxRounds 限制连续调用工具的次数，达到上限后不再提供工具，要求模型直接回复
+func ChatWithTools(ctx context.Context, svc Service, registry *ToolRegistry, messages []models.ChatMessage, opts models.ChatOptions, maxRounds int, onEvent func(ToolEvent)) (models.ChatMessage, error) {
+	if registry != nil {
+		opts.Tools = registry.Tools()
+	}
+	if maxRounds <= 0 {
+		maxRounds = DefaultMaxToolRounds
+	}
+	// 工具调用过程只用于本次请求，不修改调用方的历史消息
+	messages = append([]models.ChatMessage(nil), messages...)
+
+	for round := 0; ; round++ {
+		if round == maxRounds {
+			opts.Tools = nil
+		}
+		reply, err := Chat(ctx, svc, messages, opts)
+		if err != nil {
+			return models.ChatMessage{}, err
+		}
+		if len(reply.ToolCalls) == 0 || len(opts.Tools) == 0 {
+			return reply, nil
+		}
+		messages = append(messages, reply)
+		messages = append(messages, RunToolCalls(ctx, registry, reply.ToolCalls, onEvent)...)
+	}
+}
@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/pkg/config"
+)
+
+// scriptedLLM 第一次请求调用工具，收到工具结果后根据结果回复
+type scriptedLLM struct {
+	requests [][]models.ChatMessage
+}
+
+func (s *scriptedLLM) GetResponse(prompt string) (string, error) {
+	return "", fmt.Errorf("不应调用 GetResponse")
+}
+
+func (s *scriptedLLM) Chat(ctx context.Context, messages []models.ChatMessage, opts models.ChatOptions) (models.ChatMessage, error) {
+	s.requests = append(s.requests, messages)
+	last := messages[len(messages)-1]
+	if last.Role == models.RoleTool {
+		return models.ChatMessage{Role: models.RoleAssistant, Content: "订单状态：" + last.Content}, nil
+	}
+	return models.ChatMessage{Role: models.RoleAssistant, ToolCalls: []models.ToolCall{{
+		ID:       "call_1",
+		Type:     "function",
+		Function: models.FunctionCall{Name: "check_order", Arguments: `{"id":"42"}`},
+	}}}, nil
+}
+
+func TestToolRegistry(t *testing.T) {
+	registry := NewToolRegistry()
+	echo := func(ctx context.Context, arguments json.RawMessage) (string, error) {
+		return string(arguments), nil
+	}
+	assert.NoError(t, registry.Register(models.Tool{Name: "echo"}, echo))
+	assert.Error(t, registry.Register(models.Tool{Name: "echo"}, echo))
+	assert.Error(t, registry.Register(models.Tool{Name: "bad", Parameters: json.RawMessage("{")}, echo))
+	assert.Len(t, registry.Tools(), 1)
+
+	result, err := registry.Call(context.Background(), models.ToolCall{Function: models.FunctionCall{Name: "echo"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "{}", result)
+
+	_, err = registry.Call(context.Background(), models.ToolCall{Function: models.FunctionCall{Name: "missing"}})
+	assert.Error(t, err)
+}
+
+func TestChatWithWebhookTool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-Token"))
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"id":"42"}`, string(body))
+		fmt.Fprint(w, "已发货")
+	}))
+	defer server.Close()
+
+	registry := NewToolRegistry()
+	assert.NoError(t, RegisterWebhooks(registry, []config.WebhookToolConfig{{
+		Name:       "check_order",
+		Parameters: `{"type":"object","properties":{"id":{"type":"string"}}}`,
+		URL:        server.URL,
+		Headers:    map[string]string{"X-Token": "secret"},
+	}}))
+
+	svc := &scriptedLLM{}
+	history := []models.ChatMessage{{Role: models.RoleUser, Content: "订单 42 到哪了"}}
+	var events []ToolEvent
+	reply, err := ChatWithTools(context.Background(), svc, registry, history, models.ChatOptions{}, 0, func(event ToolEvent) {
+		events = append(events, event)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "订单状态：已发货", reply.Content)
+	assert.Len(t, history, 1, "不应修改调用方的历史消息")
+
+	assert.Len(t, svc.requests, 2)
+	assert.Len(t, svc.requests[1], 3)
+	assert.Equal(t, "call_1", svc.requests[1][2].ToolCallID)
+
+	assert.Len(t, events, 2)
+	assert.False(t, events[0].Done)
+	assert.True(t, events[1].Done)
+	assert.Equal(t, "已发货", events[1].Result)
+}
+
+func TestChatWithToolError(t *testing.T) {
+	registry := NewToolRegistry()
+	assert.NoError(t, registry.Register(models.Tool{Name: "check_order"}, func(ctx context.Context, arguments json.RawMessage) (string, error) {
+		return "", fmt.Errorf("服务不可用")
+	}))
+
+	// 工具失败时错误作为结果发回模型
+	svc := &scriptedLLM{}
+	reply, err := ChatWithTools(context.Background(), svc, registry, []models.ChatMessage{
+		{Role: models.RoleUser, Content: "订单 42 到哪了"},
+	}, models.ChatOptions{}, 1, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "订单状态：工具调用失败: 服务不可用", reply.Content)
+}
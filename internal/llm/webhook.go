@@ -0,0 +1,76 @@
+// internal/llm/webhook.go
+
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/pkg/config"
+)
+
+const (
+	// defaultWebhookTimeout 为 webhook 工具默认的请求超时
+	defaultWebhookTimeout = 10 * time.Second
+	// maxWebhookResponse 限制工具结果的长度，避免占满模型的上下文
+	maxWebhookResponse = 16 << 10
+)
+
+// WebhookHandler 返回通过 HTTP 接口实现的工具处理函数
+// 调用参数作为 JSON 请求体 POST 到 url，响应体原样作为工具结果
+func WebhookHandler(url string, headers map[string]string, timeout time.Duration) ToolHandler {
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+
+	return func(ctx context.Context, arguments json.RawMessage) (string, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(arguments))
+		if err != nil {
+			return "", fmt.Errorf("创建请求失败: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("请求 webhook 失败: %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponse))
+		if err != nil {
+			return "", fmt.Errorf("读取 webhook 响应失败: %v", err)
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return "", fmt.Errorf("webhook 返回错误，状态码: %d，响应: %s", resp.StatusCode, string(body))
+		}
+		return string(body), nil
+	}
+}
+
+// RegisterWebhooks 注册配置中声明的 webhook 工具
+func RegisterWebhooks(registry *ToolRegistry, webhooks []config.WebhookToolConfig) error {
+	for _, hook := range webhooks {
+		if hook.URL == "" {
+			return fmt.Errorf("工具 %s 未配置 url", hook.Name)
+		}
+		tool := models.Tool{Name: hook.Name, Description: hook.Description}
+		if hook.Parameters != "" {
+			tool.Parameters = json.RawMessage(hook.Parameters)
+		}
+		handler := WebhookHandler(hook.URL, hook.Headers, time.Duration(hook.Timeout)*time.Second)
+		if err := registry.Register(tool, handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// chat.go
+package models
+
+import "encoding/json"
+
+// 对话消息的角色
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// ChatMessage 为发送给 LLM 的一条对话消息
+// 助手请求调用工具时 ToolCalls 非空，工具的执行结果以 RoleTool 消息返回，并通过 ToolCallID 对应到调用
+type ChatMessage struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// Tool 描述一个可供模型调用的工具，Parameters 为参数的 JSON Schema
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolCall 为模型发起的一次工具调用，字段与 OpenAI 的 tool_calls 保持一致
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"` // 目前固定为 function
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall 为工具名称和 JSON 编码的调用参数
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ChatOptions 为单次对话请求的参数，零值表示使用提供商的默认配置
+type ChatOptions struct {
+	Model       string  `json:"model,omitempty"`
+	Temperature float64 `json:"temperature,omitempty"`
+	MaxTokens   int     `json:"max_tokens,omitempty"`
+	// Tools 为本次请求可用的工具，不支持工具调用的提供商会忽略
+	Tools []Tool `json:"tools,omitempty"`
+}
+
+// ChatChunk 表示流式对话返回的一段文本
+// 模型请求调用工具时，完整的 ToolCalls 在最后一个文本块中返回
+// Err 非空表示生成中途失败，发送该错误后通道会被关闭
+type ChatChunk struct {
+	Content   string
+	ToolCalls []ToolCall
+	Err       error
+}
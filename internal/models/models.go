@@ -10,6 +10,12 @@ type Session struct {
 	UserID    string    `json:"user_id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// Summary 为较早对话的摘要，覆盖前 SummarizedCount 条消息
+	Summary         string `json:"summary,omitempty"`
+	SummarizedCount int    `json:"summarized_count,omitempty"`
+	// Persona、Variables 为会话使用的角色和渲染系统提示词的变量，恢复会话时沿用
+	Persona   string            `json:"persona,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
 }
 
 type Message struct {
@@ -0,0 +1,115 @@
+// speech.go
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AudioChunk 表示流式语音合成返回的一段音频
+// Err 非空表示合成中途失败，发送该错误后通道会被关闭
+type AudioChunk struct {
+	Data []byte
+	Err  error
+	// SentenceEnd 为 true 时表示增量合成中提供商的一句已合成完毕，之前的音频都属于已完成的句子，Data 为空
+	SentenceEnd bool
+}
+
+// SynthesisStream 表示一次边写入文本边合成的增量合成，适合逐词输出的 LLM 回复
+type SynthesisStream interface {
+	// Write 追加一段文本，可以是 LLM 输出的单个 token
+	Write(text string) error
+	// Audio 返回合成的音频，全部文本合成完毕、出错或取消后关闭
+	Audio() <-chan AudioChunk
+	// Finish 通知文本已结束，剩余的文本合成完毕后音频通道关闭
+	Finish() error
+	// Abort 放弃本次合成并释放资源
+	Abort()
+}
+
+// 语音合成的输出格式
+const (
+	AudioFormatMP3     = "mp3"
+	AudioFormatWAV     = "wav"
+	AudioFormatPCM     = "pcm"
+	AudioFormatOggOpus = "ogg_opus"
+)
+
+// 语音合成的输入文本类型
+const (
+	TextTypePlain = "plain"
+	TextTypeSSML  = "ssml"
+)
+
+// ErrUnsupportedOption 表示提供商无法满足请求的合成参数
+var ErrUnsupportedOption = errors.New("不支持的合成参数")
+
+// SynthesisOptions 为单次语音合成的参数，零值表示使用提供商的默认配置
+// Speed、Pitch、Volume 均为相对倍率，1 表示正常
+type SynthesisOptions struct {
+	Voice    string  `json:"voice,omitempty"`
+	Language string  `json:"language,omitempty"`
+	Speed    float64 `json:"speed,omitempty"`
+	Pitch    float64 `json:"pitch,omitempty"`
+	Volume   float64 `json:"volume,omitempty"`
+	Format   string  `json:"format,omitempty"` // mp3、wav、pcm 或 ogg_opus
+	// TextType 为 ssml 时文本按 SSML 文档处理，为空时视为 plain
+	TextType string `json:"text_type,omitempty"`
+	// Style、StyleDegree、Role 为神经网络音色的说话风格、风格强度和角色扮演，仅部分提供商支持
+	Style       string  `json:"style,omitempty"`
+	StyleDegree float64 `json:"style_degree,omitempty"`
+	Role        string  `json:"role,omitempty"`
+}
+
+// HasStyle 判断是否指定了说话风格或角色
+func (o SynthesisOptions) HasStyle() bool {
+	return o.Style != "" || o.StyleDegree != 0 || o.Role != ""
+}
+
+// IsSSML 判断输入文本是否为 SSML
+func (o SynthesisOptions) IsSSML() bool {
+	return o.TextType == TextTypeSSML
+}
+
+// Validate 检查与提供商无关的参数取值，各提供商还会校验自身的取值范围
+func (o SynthesisOptions) Validate() error {
+	if o.Speed < 0 || o.Pitch < 0 || o.Volume < 0 || o.StyleDegree < 0 {
+		return UnsupportedOption("speed、pitch、volume、style_degree 不能为负数")
+	}
+	switch o.TextType {
+	case "", TextTypePlain, TextTypeSSML:
+	default:
+		return UnsupportedOption("未知的文本类型 %s，可选值为 plain 或 ssml", o.TextType)
+	}
+	switch o.Format {
+	case "", AudioFormatMP3, AudioFormatWAV, AudioFormatPCM, AudioFormatOggOpus:
+		return nil
+	default:
+		return UnsupportedOption("未知的输出格式 %s", o.Format)
+	}
+}
+
+// UnsupportedOption 生成带有 ErrUnsupportedOption 的错误，便于调用方区分参数错误和合成失败
+func UnsupportedOption(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrUnsupportedOption, fmt.Sprintf(format, args...))
+}
+
+// 音色性别
+const (
+	GenderFemale  = "female"
+	GenderMale    = "male"
+	GenderNeutral = "neutral"
+)
+
+// Voice 描述提供商的一个可用音色，ID 可直接作为 SynthesisOptions.Voice 使用
+type Voice struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Provider   string   `json:"provider"`
+	Language   string   `json:"language"`
+	Languages  []string `json:"languages,omitempty"` // 音色支持的其他语种
+	Gender     string   `json:"gender,omitempty"`
+	Styles     []string `json:"styles,omitempty"`
+	Roles      []string `json:"roles,omitempty"`
+	SampleRate int      `json:"sample_rate,omitempty"`
+}
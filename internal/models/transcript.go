@@ -0,0 +1,49 @@
+// transcript.go
+package models
+
+// Transcript 表示一次语音识别的完整结果
+type Transcript struct {
+	Text     string              `json:"text"`
+	Language string              `json:"language,omitempty"`
+	Duration float64             `json:"duration,omitempty"` // 音频时长（秒）
+	Segments []TranscriptSegment `json:"segments,omitempty"`
+	// Alternatives 为提供商返回的候选结果，按置信度从高到低排列
+	Alternatives []TranscriptAlternative `json:"alternatives,omitempty"`
+}
+
+// TranscriptSegment 表示带有时间戳的识别片段，时间单位为秒
+type TranscriptSegment struct {
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Text       string  `json:"text"`
+	AvgLogprob float64 `json:"avg_logprob,omitempty"` // 片段的平均对数概率，可用于置信度评估
+}
+
+// TranscriptAlternative 表示识别结果的一个候选项
+type TranscriptAlternative struct {
+	Text       string     `json:"text"`
+	Confidence float64    `json:"confidence"`
+	Words      []WordInfo `json:"words,omitempty"`
+}
+
+// WordInfo 表示单个词的时间信息，时间单位为秒
+type WordInfo struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// RecognitionOptions 单次识别的可选参数，零值表示使用提供商的配置
+type RecognitionOptions struct {
+	Language string `json:"language,omitempty"`
+}
+
+// RecognitionStream 表示一次边接收边上传的流式识别
+type RecognitionStream interface {
+	// Write 追加一段音频数据
+	Write(chunk []byte) error
+	// Finish 通知音频已结束，并等待最终识别结果
+	Finish() (*Transcript, error)
+	// Abort 放弃本次识别并释放资源
+	Abort()
+}
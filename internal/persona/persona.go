@@ -0,0 +1,131 @@
+// Package persona 管理助手角色：每个角色有自己的系统提示词、LLM 模型以及合成语音的音色和语言
+package persona
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/pkg/config"
+)
+
+// Persona 为一个助手角色
+type Persona struct {
+	Name        string
+	Model       string
+	Temperature float64
+	Voice       string
+	Language    string
+	prompt      *template.Template
+}
+
+// New 根据配置创建角色，系统提示词使用 text/template 语法
+func New(name string, cfg config.PersonaConfig) (*Persona, error) {
+	prompt, err := template.New(name).Option("missingkey=zero").Parse(cfg.SystemPrompt)
+	if err != nil {
+		return nil, fmt.Errorf("角色 %s 的系统提示词模板有误: %v", name, err)
+	}
+	return &Persona{
+		Name:        name,
+		Model:       cfg.Model,
+		Temperature: cfg.Temperature,
+		Voice:       cfg.Voice,
+		Language:    cfg.Language,
+		prompt:      prompt,
+	}, nil
+}
+
+// Registry 保存配置中的全部角色
+type Registry struct {
+	personas map[string]*Persona
+	fallback string
+}
+
+// NewRegistry 加载配置中的角色，defaultName 为客户端未指定角色时使用的角色，可以为空
+func NewRegistry(cfgs map[string]config.PersonaConfig, defaultName string) (*Registry, error) {
+	r := &Registry{personas: make(map[string]*Persona), fallback: strings.ToLower(defaultName)}
+	for name, cfg := range cfgs {
+		// viper 会将配置中的键转为小写，角色名称统一按小写匹配
+		name = strings.ToLower(name)
+		p, err := New(name, cfg)
+		if err != nil {
+			return nil, err
+		}
+		r.personas[name] = p
+	}
+	if r.fallback != "" {
+		if _, ok := r.personas[r.fallback]; !ok {
+			return nil, fmt.Errorf("默认角色 %s 未配置", defaultName)
+		}
+	}
+	return r, nil
+}
+
+// Get 返回指定名称的角色，name 为空时返回默认角色，未配置默认角色时返回 nil
+func (r *Registry) Get(name string) (*Persona, error) {
+	if name == "" {
+		name = r.fallback
+	}
+	if name == "" {
+		return nil, nil
+	}
+	p, ok := r.personas[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("未知的角色: %s，可选值为 %s", name, strings.Join(r.Names(), "、"))
+	}
+	return p, nil
+}
+
+// Names 按字母顺序返回全部角色名称
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.personas))
+	for name := range r.personas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SystemPrompt 渲染系统提示词
+// 可用的变量有 date、time、weekday、language 以及客户端提供的变量，例如 user_name，客户端的变量优先
+func (p *Persona) SystemPrompt(vars map[string]string, now time.Time) (string, error) {
+	data := map[string]string{
+		"date":     now.Format("2006-01-02"),
+		"time":     now.Format("15:04"),
+		"weekday":  now.Weekday().String(),
+		"language": p.Language,
+	}
+	for key, value := range vars {
+		data[key] = value
+	}
+
+	var b strings.Builder
+	if err := p.prompt.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("渲染角色 %s 的系统提示词失败: %v", p.Name, err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// ChatOptions 返回角色使用的模型参数
+func (p *Persona) ChatOptions() models.ChatOptions {
+	return models.ChatOptions{Model: p.Model, Temperature: p.Temperature}
+}
+
+// ApplySynthesis 为客户端未指定的合成参数填入角色的音色和语言
+// 音色已经决定了语言，只有客户端和角色都没有指定音色时才填入语言，
+// 火山引擎等由音色决定语种的提供商会拒绝同时带有 language 的请求
+func (p *Persona) ApplySynthesis(opts *models.SynthesisOptions) {
+	if opts.Voice != "" {
+		return
+	}
+	if p.Voice != "" {
+		opts.Voice = p.Voice
+		return
+	}
+	if opts.Language == "" {
+		opts.Language = p.Language
+	}
+}
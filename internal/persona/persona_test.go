@@ -0,0 +1,84 @@
+package persona
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/pkg/config"
+)
+
+func TestSystemPrompt(t *testing.T) {
+	registry, err := NewRegistry(map[string]config.PersonaConfig{
+		"receptionist": {
+			SystemPrompt: "你是前台，今天是 {{.date}}。{{if .user_name}}用户叫{{.user_name}}。{{end}}",
+			Voice:        "zh-CN-XiaoxiaoNeural",
+			Language:     "zh-CN",
+		},
+	}, "receptionist")
+	assert.NoError(t, err)
+
+	p, err := registry.Get("")
+	assert.NoError(t, err)
+	assert.Equal(t, "receptionist", p.Name)
+
+	now := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	prompt, err := p.SystemPrompt(map[string]string{"user_name": "小明"}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "你是前台，今天是 2024-05-01。用户叫小明。", prompt)
+
+	// 缺少的变量渲染为空
+	prompt, err = p.SystemPrompt(nil, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "你是前台，今天是 2024-05-01。", prompt)
+
+	opts := models.SynthesisOptions{Language: "en-US"}
+	p.ApplySynthesis(&opts)
+	assert.Equal(t, "zh-CN-XiaoxiaoNeural", opts.Voice)
+	assert.Equal(t, "en-US", opts.Language)
+}
+
+func TestApplySynthesis(t *testing.T) {
+	cases := []struct {
+		name         string
+		persona      Persona
+		opts         models.SynthesisOptions
+		wantVoice    string
+		wantLanguage string
+	}{
+		{"角色的音色不附带语言", Persona{Voice: "BV700_streaming", Language: "zh-CN"},
+			models.SynthesisOptions{}, "BV700_streaming", ""},
+		{"客户端的音色优先且不附带语言", Persona{Voice: "BV700_streaming", Language: "zh-CN"},
+			models.SynthesisOptions{Voice: "BV001_streaming"}, "BV001_streaming", ""},
+		{"没有音色时使用角色的语言", Persona{Language: "en-US"},
+			models.SynthesisOptions{}, "", "en-US"},
+		{"客户端的语言优先", Persona{Language: "en-US"},
+			models.SynthesisOptions{Language: "ja-JP"}, "", "ja-JP"},
+	}
+	for _, tc := range cases {
+		opts := tc.opts
+		tc.persona.ApplySynthesis(&opts)
+		assert.Equal(t, tc.wantVoice, opts.Voice, tc.name)
+		assert.Equal(t, tc.wantLanguage, opts.Language, tc.name)
+	}
+}
+
+func TestRegistryErrors(t *testing.T) {
+	_, err := NewRegistry(map[string]config.PersonaConfig{"tutor": {SystemPrompt: "{{.name"}}, "")
+	assert.Error(t, err)
+
+	_, err = NewRegistry(map[string]config.PersonaConfig{"tutor": {}}, "receptionist")
+	assert.Error(t, err)
+
+	registry, err := NewRegistry(map[string]config.PersonaConfig{"tutor": {}}, "")
+	assert.NoError(t, err)
+	p, err := registry.Get("")
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+	_, err = registry.Get("receptionist")
+	assert.Error(t, err)
+	p, err = registry.Get("Tutor")
+	assert.NoError(t, err)
+	assert.Equal(t, "tutor", p.Name)
+}
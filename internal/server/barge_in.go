@@ -0,0 +1,159 @@
+// barge_in.go - 打断：用户在助手回复期间开口时取消该回复
+package server
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/pkg/config"
+	"github.com/telepace/voiceflow/pkg/logger"
+)
+
+const (
+	bargeInAudioStart = "audio_start"
+	bargeInVAD        = "vad"
+)
+
+// bargeIn 为打断的配置，在 InitServices 中设置
+var bargeIn config.BargeInConfig
+
+// activeReply 为正在生成或下发的回复，reason 非空表示已被打断
+type activeReply struct {
+	cancel context.CancelFunc
+	reason string
+}
+
+// beginReply 登记一次回复，返回的 ctx 在回复被打断时取消
+func (c *chatSession) beginReply(parent context.Context) (context.Context, *activeReply) {
+	ctx, cancel := context.WithCancel(parent)
+	active := &activeReply{cancel: cancel}
+	c.replyMu.Lock()
+	c.active = active
+	c.replyMu.Unlock()
+	return ctx, active
+}
+
+// endReply 在回复结束后清除登记
+func (c *chatSession) endReply(active *activeReply) {
+	c.replyMu.Lock()
+	if c.active == active {
+		c.active = nil
+	}
+	c.replyMu.Unlock()
+	active.cancel()
+}
+
+// interrupt 取消正在进行的回复，没有回复或已被打断时返回 false
+func (c *chatSession) interrupt(reason string) bool {
+	c.replyMu.Lock()
+	defer c.replyMu.Unlock()
+	if c.active == nil || c.active.reason != "" {
+		return false
+	}
+	c.active.reason = reason
+	c.active.cancel()
+	logger.Infof("会话 %s 的回复被打断: %s", c.id, reason)
+	return true
+}
+
+// replying 返回是否有正在进行且未被打断的回复
+func (c *chatSession) replying() bool {
+	c.replyMu.Lock()
+	defer c.replyMu.Unlock()
+	return c.active != nil && c.active.reason == ""
+}
+
+// interruptReason 返回回复被打断的原因，未被打断时返回空字符串
+func (c *chatSession) interruptReason(active *activeReply) string {
+	c.replyMu.Lock()
+	defer c.replyMu.Unlock()
+	return active.reason
+}
+
+// sendInterrupted 通知客户端回复被打断，text 为已经下发的部分，会话历史中只保留这部分
+// 客户端应停止播放尚未播放完的音频
+func sendInterrupted(conn *safeConn, sessionID, streamID, messageID, reason, text string, delivered, total int) {
+	response := map[string]interface{}{
+		"type":                "reply_interrupted",
+		"session_id":          sessionID,
+		"reason":              reason,
+		"delivered_text":      text,
+		"delivered_sentences": delivered,
+		"total_sentences":     total,
+	}
+	if streamID != "" {
+		response["stream_id"] = streamID
+	}
+	if messageID != "" {
+		response["message_id"] = messageID
+	}
+	if err := conn.WriteJSON(response); err != nil {
+		logger.Error("发送响应失败", "error", err)
+	}
+}
+
+// deliveredPrefix 返回 text 中到最后一个已下发句子为止的部分，保留句子之间原有的空白
+func deliveredPrefix(text string, delivered []string) string {
+	end := 0
+	for _, sentence := range delivered {
+		i := strings.Index(text[end:], sentence)
+		if i < 0 {
+			break
+		}
+		end += i + len(sentence)
+	}
+	return strings.TrimSpace(text[:end])
+}
+
+// bargeInDetector 在 vad 模式下检测连接上传的录音中是否有人声
+// 仅支持裸 PCM 和 WAV，录音为压缩格式时不做检测
+type bargeInDetector struct {
+	detector audio.SpeechDetector
+	started  bool
+	disabled bool
+}
+
+func newBargeInDetector() *bargeInDetector {
+	d := &bargeInDetector{
+		detector: audio.SpeechDetector{
+			Threshold: bargeIn.Threshold,
+			MinSpeech: time.Duration(bargeIn.MinSpeechMs) * time.Millisecond,
+		},
+	}
+	d.restart()
+	return d
+}
+
+// restart 在新的录音开始时清除状态，没有文件头的录音按 16kHz、单声道、16 位 PCM 处理
+func (d *bargeInDetector) restart() {
+	d.detector.Reset()
+	d.detector.Format = audio.Format{SampleRate: 16000, Channels: 1, BitsPerSample: 16}
+	d.started = false
+	d.disabled = false
+}
+
+// speech 追加一段录音，检测到人声时返回 true
+func (d *bargeInDetector) speech(data []byte) bool {
+	if !d.started {
+		d.started = true
+		switch audio.DetectContainer(data) {
+		case audio.ContainerUnknown:
+		case audio.ContainerWAV:
+			format, pcm, err := audio.ParseWAV(data)
+			if err != nil || format.BitsPerSample != 16 {
+				d.disabled = true
+				break
+			}
+			d.detector.Format = format
+			data = pcm
+		default:
+			d.disabled = true
+		}
+	}
+	if d.disabled {
+		return false
+	}
+	return d.detector.Write(data)
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/internal/conversation"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/tts/cache"
+	"github.com/telepace/voiceflow/pkg/config"
+)
+
+func TestInterruptReply(t *testing.T) {
+	c := &chatSession{id: "s1"}
+	assert.False(t, c.replying())
+	assert.False(t, c.interrupt(bargeInAudioStart))
+
+	ctx, active := c.beginReply(context.Background())
+	assert.True(t, c.replying())
+	assert.Equal(t, "", c.interruptReason(active))
+
+	assert.True(t, c.interrupt(bargeInVAD))
+	assert.Error(t, ctx.Err())
+	assert.Equal(t, bargeInVAD, c.interruptReason(active))
+	assert.False(t, c.replying())
+	// 同一回复只会被打断一次，原因保持第一次的
+	assert.False(t, c.interrupt(bargeInAudioStart))
+	assert.Equal(t, bargeInVAD, c.interruptReason(active))
+
+	c.endReply(active)
+	assert.Nil(t, c.active)
+}
+
+func TestEndReplyKeepsNewerReply(t *testing.T) {
+	c := &chatSession{id: "s1"}
+	_, first := c.beginReply(context.Background())
+	secondCtx, second := c.beginReply(context.Background())
+
+	// 较早的回复结束时不影响之后登记的回复
+	c.endReply(first)
+	assert.Equal(t, second, c.active)
+	assert.NoError(t, secondCtx.Err())
+	assert.True(t, c.interrupt(bargeInAudioStart))
+	c.endReply(second)
+	assert.Nil(t, c.active)
+}
+
+func TestDeliveredPrefix(t *testing.T) {
+	cases := []struct {
+		name      string
+		text      string
+		delivered []string
+		want      string
+	}{
+		{"没有下发", "你好。今天天气不错。", nil, ""},
+		{"下发了第一句", "你好。今天天气不错。", []string{"你好。"}, "你好。"},
+		{"保留句子之间的空白", "Hello. How are you? Fine.", []string{"Hello.", "How are you?"}, "Hello. How are you?"},
+		{"全部下发", "你好。再见。", []string{"你好。", "再见。"}, "你好。再见。"},
+		{"重复的句子按顺序匹配", "好的。好的。明白了。", []string{"好的。", "好的。"}, "好的。好的。"},
+		{"找不到的句子之后不再匹配", "你好。再见。", []string{"你好。", "不存在", "再见。"}, "你好。"},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, deliveredPrefix(tc.text, tc.delivered), tc.name)
+	}
+}
+
+// fixedLLM 总是返回同一段回复
+type fixedLLM struct {
+	reply string
+}
+
+func (f *fixedLLM) GetResponse(prompt string) (string, error) {
+	return f.reply, nil
+}
+
+func TestReplyInterruptedDuringSynthesis(t *testing.T) {
+	svc := &blockingTTS{started: make(chan string, 1)}
+	store := &fakeStorage{objects: make(map[string][]byte)}
+	prevTTS, prevCache, prevStorage := ttsService, ttsCache, storageService
+	prevLLM, prevConversations := llmService, conversations
+	ttsService, storageService = svc, store
+	ttsCache = cache.New("volcengine", svc, store, cache.Options{Enabled: true})
+	llmService = &fixedLLM{reply: "今天天气不错。"}
+	conversations = conversation.New(conversation.NewMemoryStore(), nil, conversation.Options{})
+	defer func() {
+		ttsService, ttsCache, storageService = prevTTS, prevCache, prevStorage
+		llmService, conversations = prevLLM, prevConversations
+	}()
+	_, _, _, err := conversations.Open("s1", "")
+	assert.NoError(t, err)
+
+	c := &chatSession{id: "s1", config: sessionStartMessage{RequireTTS: true}}
+	done := make(chan struct{})
+	client := dialTestConn(t, func(conn *safeConn) {
+		c.reply(context.Background(), conn, "你好", nil)
+		close(done)
+	})
+	assert.Equal(t, "今天天气不错。", <-svc.started)
+
+	// 非流式回复在合成期间被打断时中止合成，不下发回复
+	assert.True(t, c.interrupt(bargeInAudioStart))
+	messages := readUntil(t, client, "reply_interrupted")
+	for _, msg := range messages {
+		assert.NotEqual(t, "chat_reply", msg["type"])
+		assert.NotEqual(t, "tts_error", msg["type"])
+	}
+	assert.Equal(t, bargeInAudioStart, messages[len(messages)-1]["reason"])
+	<-done
+	assert.Equal(t, int32(1), svc.canceled.Load())
+
+	// 历史中只有用户的消息
+	history, err := conversations.History(context.Background(), "s1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ChatMessage{{Role: models.RoleUser, Content: "你好"}}, history)
+}
+
+// useBargeIn 设置 vad 打断的配置，测试结束后恢复
+func useBargeIn(t *testing.T) {
+	prev := bargeIn
+	bargeIn = config.BargeInConfig{Enabled: true, Mode: bargeInVAD, Threshold: 500, MinSpeechMs: 40}
+	t.Cleanup(func() { bargeIn = prev })
+}
+
+// loudPCM 生成幅度为 ±amplitude 的 16 位 PCM
+func loudPCM(format audio.Format, duration time.Duration, amplitude int16) []byte {
+	pcm := make([]byte, format.Bytes(duration))
+	for i := 0; i+1 < len(pcm); i += 2 {
+		v := amplitude
+		if i/2%2 == 1 {
+			v = -amplitude
+		}
+		binary.LittleEndian.PutUint16(pcm[i:], uint16(v))
+	}
+	return pcm
+}
+
+func TestBargeInDetectorRawPCM(t *testing.T) {
+	useBargeIn(t)
+	format := audio.Format{SampleRate: 16000, Channels: 1, BitsPerSample: 16}
+	d := newBargeInDetector()
+
+	assert.False(t, d.speech(loudPCM(format, 100*time.Millisecond, 0)))
+	assert.False(t, d.speech(loudPCM(format, 20*time.Millisecond, 3000)))
+	assert.True(t, d.speech(loudPCM(format, 20*time.Millisecond, 3000)))
+
+	// 新的录音开始时清除累计的人声
+	d.restart()
+	assert.False(t, d.speech(loudPCM(format, 20*time.Millisecond, 3000)))
+}
+
+func TestBargeInDetectorWAV(t *testing.T) {
+	useBargeIn(t)
+	// 按 WAV 文件头中的采样率计算时长，8kHz 下 40ms 为 640 字节
+	format := audio.Format{SampleRate: 8000, Channels: 1, BitsPerSample: 16}
+	d := newBargeInDetector()
+	header := audio.EncodeWAV(format, loudPCM(format, 20*time.Millisecond, 3000))
+	assert.False(t, d.speech(header))
+	assert.True(t, d.speech(loudPCM(format, 20*time.Millisecond, 3000)))
+}
+
+func TestBargeInDetectorUnsupportedInput(t *testing.T) {
+	useBargeIn(t)
+	format := audio.Format{SampleRate: 16000, Channels: 1, BitsPerSample: 16}
+
+	// 压缩格式的录音不做检测
+	d := newBargeInDetector()
+	assert.False(t, d.speech(append([]byte("OggS"), loudPCM(format, 20*time.Millisecond, 3000)...)))
+	assert.False(t, d.speech(loudPCM(format, 200*time.Millisecond, 3000)))
+
+	// 8 位 WAV 同样不做检测
+	d.restart()
+	wav8 := audio.EncodeWAV(audio.Format{SampleRate: 16000, Channels: 1, BitsPerSample: 8}, make([]byte, 640))
+	assert.False(t, d.speech(wav8))
+	assert.False(t, d.speech(loudPCM(format, 200*time.Millisecond, 3000)))
+
+	// restart 后恢复检测
+	d.restart()
+	assert.True(t, d.speech(loudPCM(format, 60*time.Millisecond, 3000)))
+}
@@ -0,0 +1,31 @@
+// conn.go - WebSocket 连接封装
+package server
+
+import (
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// safeConn 串行化对 WebSocket 连接的写操作
+// gorilla/websocket 不支持并发写，而识别结果、TTS 音频块等会从不同的协程下发
+type safeConn struct {
+	*websocket.Conn
+	mu sync.Mutex
+}
+
+func newSafeConn(conn *websocket.Conn) *safeConn {
+	return &safeConn{Conn: conn}
+}
+
+func (c *safeConn) WriteJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.WriteJSON(v)
+}
+
+func (c *safeConn) WriteMessage(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.WriteMessage(messageType, data)
+}
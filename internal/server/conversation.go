@@ -0,0 +1,343 @@
+// conversation.go - 多轮语音对话
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/telepace/voiceflow/internal/llm"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/persona"
+	"github.com/telepace/voiceflow/pkg/logger"
+)
+
+// sessionStartMessage 为客户端开启或恢复对话的消息
+// session_id 为空时创建新会话，与已有会话相同时恢复该会话的历史
+type sessionStartMessage struct {
+	SessionID  string `json:"session_id"`
+	UserID     string `json:"user_id"`
+	RequireTTS bool   `json:"require_tts"` // 为 true 时助手的回复会合成语音
+	Stream     bool   `json:"stream"`      // 为 true 时回复逐句下发，音频以二进制帧实时下发
+	// Persona 为助手的角色，为空时使用默认角色，恢复会话时沿用之前的角色
+	Persona  string `json:"persona"`
+	UserName string `json:"user_name"`
+	// Variables 为渲染系统提示词的变量，user_name 会作为其中的一个变量
+	Variables map[string]string `json:"variables"`
+	// 助手回复的合成参数，未指定的音色和语言使用角色的配置
+	models.SynthesisOptions
+}
+
+// chatSession 为一个连接上的对话，同一会话的回复串行生成，保证历史消息的顺序
+type chatSession struct {
+	id      string
+	userID  string
+	config  sessionStartMessage
+	persona *persona.Persona
+	vars    map[string]string
+	mu      sync.Mutex
+
+	// active 为正在进行的回复，用户开口时通过它打断回复
+	replyMu sync.Mutex
+	active  *activeReply
+}
+
+// startChatSession 打开或恢复对话并发送 session_started，失败时通知客户端并返回 nil
+func startChatSession(conn *safeConn, data []byte) *chatSession {
+	var msg sessionStartMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		sendChatError(conn, "", err)
+		return nil
+	}
+	if err := msg.SynthesisOptions.Validate(); err != nil {
+		sendChatError(conn, msg.SessionID, err)
+		return nil
+	}
+
+	// 先校验客户端指定的角色，避免创建出无法使用的会话
+	if msg.Persona != "" {
+		if _, err := personas.Get(msg.Persona); err != nil {
+			sendChatError(conn, msg.SessionID, err)
+			return nil
+		}
+	}
+
+	session, history, resumed, err := conversations.Open(msg.SessionID, msg.UserID)
+	if err != nil {
+		sendChatError(conn, msg.SessionID, err)
+		return nil
+	}
+	if history == nil {
+		history = []models.Message{}
+	}
+
+	name := msg.Persona
+	if name == "" {
+		name = session.Persona
+	}
+	p, err := personas.Get(name)
+	if err != nil {
+		sendChatError(conn, session.ID, err)
+		return nil
+	}
+	vars := msg.Variables
+	if msg.UserName != "" {
+		if vars == nil {
+			vars = make(map[string]string)
+		}
+		vars["user_name"] = msg.UserName
+	}
+	personaName := ""
+	if p != nil {
+		personaName = p.Name
+		p.ApplySynthesis(&msg.SynthesisOptions)
+	}
+	resolveVoice(&msg.SynthesisOptions)
+	if session, err = conversations.Configure(session.ID, personaName, vars); err != nil {
+		sendChatError(conn, session.ID, err)
+		return nil
+	}
+
+	if err := conn.WriteJSON(map[string]interface{}{
+		"type":       "session_started",
+		"session_id": session.ID,
+		"resumed":    resumed,
+		"persona":    personaName,
+		"history":    history,
+	}); err != nil {
+		logger.Error("发送响应失败", "error", err)
+	}
+	return &chatSession{
+		id:      session.ID,
+		userID:  session.UserID,
+		config:  msg,
+		persona: p,
+		vars:    session.Variables,
+	}
+}
+
+// systemPrompt 渲染角色的系统提示词，未使用角色时返回空字符串
+func (c *chatSession) systemPrompt() (string, error) {
+	if c.persona == nil {
+		return "", nil
+	}
+	vars := map[string]string{"user_id": c.userID}
+	for key, value := range c.vars {
+		vars[key] = value
+	}
+	return c.persona.SystemPrompt(vars, time.Now())
+}
+
+// chatOptions 返回角色指定的模型参数
+func (c *chatSession) chatOptions() models.ChatOptions {
+	if c.persona == nil {
+		return models.ChatOptions{}
+	}
+	return c.persona.ChatOptions()
+}
+
+// reply 记录用户的一轮对话，携带历史消息请求 LLM，并记录助手的回复
+// audioURL 非空时，用户录音存储完成后补充到该轮对话
+func (c *chatSession) reply(ctx context.Context, conn *safeConn, text string, audioURL <-chan string) {
+	received := time.Now()
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ctx, active := c.beginReply(ctx)
+	defer c.endReply(active)
+
+	userMsg, err := conversations.Append(c.id, models.RoleUser, text, "")
+	if err != nil {
+		sendChatError(conn, c.id, err)
+		return
+	}
+	if audioURL != nil {
+		go func() {
+			if url, ok := <-audioURL; ok {
+				if err := conversations.SetAudioURL(c.id, userMsg.ID, url); err != nil {
+					logger.Warnf("记录用户录音地址失败: %v", err)
+				}
+			}
+		}()
+	}
+
+	prompt, err := c.systemPrompt()
+	if err != nil {
+		sendChatError(conn, c.id, err)
+		return
+	}
+	history, err := conversations.History(ctx, c.id, prompt)
+	if err != nil {
+		sendChatError(conn, c.id, err)
+		return
+	}
+	if c.config.Stream {
+		c.streamReply(ctx, conn, history, received, active)
+		return
+	}
+
+	// 非流式回复在整体完成前没有下发任何内容，被打断时不记录助手的回复
+	reply, err := llm.ChatWithTools(ctx, llmService, toolRegistry, history, c.chatOptions(), maxToolRounds, func(event llm.ToolEvent) {
+		sendToolEvent(conn, c.id, event)
+	})
+	if reason := c.interruptReason(active); reason != "" {
+		sendInterrupted(conn, c.id, "", "", reason, "", 0, 0)
+		return
+	}
+	if err != nil {
+		sendChatError(conn, c.id, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"type":       "chat_reply",
+		"session_id": c.id,
+		"text":       reply.Content,
+	}
+	replyAudioURL := ""
+	if c.config.RequireTTS {
+		// 合成使用本轮回复的 ctx，被打断时立即中止
+		result, err := ttsCache.SynthesizeContext(ctx, reply.Content, c.config.SynthesisOptions)
+		if reason := c.interruptReason(active); reason != "" {
+			sendInterrupted(conn, c.id, "", "", reason, "", 0, 0)
+			return
+		}
+		if err != nil {
+			sendTTSError(conn, "", err)
+		} else {
+			replyAudioURL = result.AudioURL
+			response["audio_url"] = replyAudioURL
+		}
+	}
+	assistantMsg, err := conversations.Append(c.id, models.RoleAssistant, reply.Content, replyAudioURL)
+	if err != nil {
+		sendChatError(conn, c.id, err)
+		return
+	}
+	response["message_id"] = assistantMsg.ID
+	if err := conn.WriteJSON(response); err != nil {
+		logger.Error("发送响应失败", "error", err)
+	}
+}
+
+// streamReply 边生成边下发回复：LLM 每输出完整的一句就发送 chat_sentence，需要语音时立即合成该句
+// 事件顺序：chat_reply_start → chat_sentence 与二进制音频帧交替 → 带 metrics 的 chat_reply，
+// 完整音频在后台存储后再发送 tts_stored
+// 被打断时历史中只记录已经下发给客户端的句子
+func (c *chatSession) streamReply(ctx context.Context, conn *safeConn, history []models.ChatMessage, received time.Time, active *activeReply) {
+	streamID := uuid.New().String()
+	if err := conn.WriteJSON(map[string]interface{}{
+		"type":       "chat_reply_start",
+		"session_id": c.id,
+		"stream_id":  streamID,
+	}); err != nil {
+		logger.Error("发送响应失败", "error", err)
+		return
+	}
+
+	r := &replyStream{
+		conn:      conn,
+		sessionID: c.id,
+		streamID:  streamID,
+		speak:     c.config.RequireTTS,
+		chatOpts:  c.chatOptions(),
+		opts:      c.config.SynthesisOptions,
+		start:     received,
+	}
+	reply, err := r.run(ctx, history)
+	if err != nil {
+		if reason := c.interruptReason(active); reason != "" {
+			c.truncateReply(conn, streamID, reason, reply)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, models.ErrUnsupportedOption) {
+			sendTTSError(conn, streamID, err)
+		} else {
+			sendChatError(conn, c.id, err)
+		}
+		return
+	}
+
+	assistantMsg, err := conversations.Append(c.id, models.RoleAssistant, reply.text, "")
+	if err != nil {
+		sendChatError(conn, c.id, err)
+		return
+	}
+	if err := conn.WriteJSON(map[string]interface{}{
+		"type":       "chat_reply",
+		"session_id": c.id,
+		"message_id": assistantMsg.ID,
+		"stream_id":  streamID,
+		"text":       reply.text,
+		"metrics":    reply.metrics,
+	}); err != nil {
+		logger.Error("发送响应失败", "error", err)
+		return
+	}
+	logger.Infof("流式回复完成，首字 %dms，首段音频 %dms，总耗时 %dms，%d 句",
+		reply.metrics.FirstTokenMs, reply.metrics.FirstAudioMs, reply.metrics.TotalMs, reply.metrics.Sentences)
+
+	if len(reply.audio) == 0 {
+		return
+	}
+	// 客户端已经拿到全部音频，存储不再阻塞对话
+	go func() {
+		audioURL, err := ttsCache.Store(reply.text, c.config.SynthesisOptions, reply.audio)
+		if err != nil {
+			logger.Error("存储音频失败", "error", err)
+			conn.WriteJSON(map[string]interface{}{
+				"type":      "storage_error",
+				"stream_id": streamID,
+				"error":     err.Error(),
+			})
+			return
+		}
+		conn.WriteJSON(map[string]interface{}{
+			"type":      "tts_stored",
+			"stream_id": streamID,
+			"audio_url": audioURL,
+		})
+		if err := conversations.SetAudioURL(c.id, assistantMsg.ID, audioURL); err != nil {
+			logger.Warnf("记录回复音频地址失败: %v", err)
+		}
+	}()
+}
+
+// truncateReply 记录被打断的流式回复中已经下发的部分并通知客户端，不存储不完整的音频
+func (c *chatSession) truncateReply(conn *safeConn, streamID, reason string, reply *spokenReply) {
+	text := deliveredPrefix(reply.text, reply.delivered)
+	messageID := ""
+	if text != "" {
+		assistantMsg, err := conversations.Append(c.id, models.RoleAssistant, text, "")
+		if err != nil {
+			sendChatError(conn, c.id, err)
+			return
+		}
+		messageID = assistantMsg.ID
+	}
+	sendInterrupted(conn, c.id, streamID, messageID, reason, text, len(reply.delivered), reply.metrics.Sentences)
+}
+
+// sendChatError 通知客户端对话处理失败
+func sendChatError(conn *safeConn, sessionID string, err error) {
+	logger.Error("对话处理失败", "error", err)
+	response := map[string]interface{}{
+		"type":  "chat_error",
+		"error": err.Error(),
+	}
+	if sessionID != "" {
+		response["session_id"] = sessionID
+	}
+	conn.WriteJSON(response)
+}
@@ -2,26 +2,54 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/telepace/voiceflow/pkg/config"
 	"github.com/telepace/voiceflow/pkg/logger"
 
 	"github.com/gorilla/websocket"
+	"github.com/telepace/voiceflow/internal/conversation"
+	"github.com/telepace/voiceflow/internal/llm"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/persona"
 	"github.com/telepace/voiceflow/internal/storage"
 	"github.com/telepace/voiceflow/internal/stt"
+	"github.com/telepace/voiceflow/internal/stt/cleanup"
+	"github.com/telepace/voiceflow/internal/translate"
 	"github.com/telepace/voiceflow/internal/tts"
+	"github.com/telepace/voiceflow/internal/tts/cache"
+	"github.com/telepace/voiceflow/internal/tts/voices"
 )
 
 var (
 	// 服务实例和锁
-	serviceLock sync.RWMutex
-	sttService  stt.Service
-	ttsService  tts.Service
-	// llmService     llm.Service
+	serviceLock    sync.RWMutex
+	sttService     stt.Service
+	ttsService     tts.Service
+	ttsCache       *cache.Cache
+	voiceCatalog   *voices.Catalog
+	llmService     llm.Service
+	conversations  *conversation.Manager
+	personas       *persona.Registry
 	storageService storage.Service
+
+	// 语音翻译模式的翻译服务、默认目标语言和目标语言对应的音色
+	translator         translate.Translator
+	translationTargets []string
+	translationVoices  map[string]string
+	ttsProvider        string
+
+	// transcriptCleaner 使用 LLM 修正识别结果，postProcessDefault 为会话未指定时是否启用
+	transcriptCleaner  *cleanup.Cleaner
+	postProcessDefault bool
+
+	// toolRegistry 保存对话中可供模型调用的工具
+	toolRegistry  = llm.NewToolRegistry()
+	maxToolRounds = llm.DefaultMaxToolRounds
 )
 
 // 初始化服务实例
@@ -35,26 +63,119 @@ func InitServices() {
 		logger.Fatalf("STT 服务初始化失败: %v", err)
 	}
 	ttsService = tts.NewService(cfg.TTS.Provider)
-	// llmService = llm.NewService(cfg.LLM.Provider)
+	llmService = llm.NewService(cfg.LLM.Provider)
+	if err := llm.RegisterWebhooks(toolRegistry, cfg.LLM.Tools.Webhooks); err != nil {
+		logger.Fatalf("LLM 工具初始化失败: %v", err)
+	}
+	if cfg.LLM.Tools.MaxRounds > 0 {
+		maxToolRounds = cfg.LLM.Tools.MaxRounds
+	}
+	bargeIn = cfg.Conversation.BargeIn
+	if bargeIn.Mode == "" {
+		bargeIn.Mode = bargeInAudioStart
+	}
+	if bargeIn.Mode != bargeInAudioStart && bargeIn.Mode != bargeInVAD {
+		logger.Fatalf("不支持的打断模式: %s，可选值为 audio_start 或 vad", bargeIn.Mode)
+	}
+	postProcess := cfg.STT.PostProcess
+	transcriptCleaner = cleanup.New(llmService, cleanup.Options{
+		Prompt:   postProcess.Prompt,
+		Glossary: postProcess.Glossary,
+		Model:    postProcess.Model,
+		Timeout:  time.Duration(postProcess.Timeout) * time.Second,
+	})
+	postProcessDefault = postProcess.Enabled
+
+	if translator, err = translate.NewTranslator(cfg.Translation.Provider, llmService, cfg.Translation.Model); err != nil {
+		logger.Fatalf("翻译服务初始化失败: %v", err)
+	}
+	translationTargets = cfg.Translation.Targets
+	translationVoices = cfg.Translation.Voices
+	ttsProvider = cfg.TTS.Provider
 	storageService = storage.NewService()
+	ttsCache = cache.New(cfg.TTS.Provider, ttsService, storageService, cache.Options{
+		Enabled:       cfg.TTS.Cache.Enabled,
+		MemoryEntries: cfg.TTS.Cache.MemoryEntries,
+		Storage:       cfg.TTS.Cache.Storage,
+	})
+
+	voiceProviders := cfg.TTS.Voices.Providers
+	if len(voiceProviders) == 0 {
+		voiceProviders = []string{cfg.TTS.Provider}
+	}
+	voiceCatalog = voices.NewCatalog(voiceProviders, time.Duration(cfg.TTS.Voices.RefreshMinutes)*time.Minute)
+	voiceCatalog.Start(context.Background())
+
+	var store conversation.Store = conversation.NewMemoryStore()
+	if cfg.Conversation.Store == "file" {
+		if store, err = conversation.NewFileStore(cfg.Conversation.Path); err != nil {
+			logger.Fatalf("对话存储初始化失败: %v", err)
+		}
+	}
+	conversations = conversation.New(store, llmService, conversation.Options{
+		MaxHistoryTokens: cfg.Conversation.MaxHistoryTokens,
+		Summarize:        cfg.Conversation.Summarize,
+	})
+	conversations.StartEviction(context.Background(), time.Duration(cfg.Conversation.IdleMinutes)*time.Minute)
+	if personas, err = persona.NewRegistry(cfg.Personas, cfg.Conversation.DefaultPersona); err != nil {
+		logger.Fatalf("角色初始化失败: %v", err)
+	}
 }
 
 // 修改消息结构
 type TextMessage struct {
 	Text       string `json:"text"`
 	RequireTTS bool   `json:"require_tts"`
+	Stream     bool   `json:"stream"` // 为 true 时音频块以二进制帧实时下发
+	// voice、language、speed、pitch、volume、format 等合成参数，未指定时使用提供商默认值
+	models.SynthesisOptions
 }
 
 func (s *Server) handleConnections(w http.ResponseWriter, r *http.Request) {
-	ws, err := s.upgrader.Upgrade(w, r, nil)
+	rawConn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		logger.Error("WebSocket upgrade error: %v", err)
 		return
 	}
-	defer ws.Close()
+	defer rawConn.Close()
+	ws := newSafeConn(rawConn)
+
+	// 连接断开时取消仍在进行的流式合成
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
 
 	// 创建会话管理器
 	sessionManager := NewSessionManager()
+	// detector 在 vad 模式下检测助手回复期间上传的录音中是否有人声
+	detector := newBargeInDetector()
+	// chat 在客户端发送 session_start 或 chat 后非空，之后的识别结果会作为用户的一轮对话
+	// translation 在客户端发送 translation_start 后非空，之后的识别结果会被翻译，以最后开启的模式为准
+	var (
+		chat        *chatSession
+		translation *translationSession
+	)
+	openChat := func(data []byte) bool {
+		started := startChatSession(ws, data)
+		if started == nil {
+			return false
+		}
+		chat = started
+		sessionManager.SetTranscriptHandler(func(transcript *models.Transcript, audioURL <-chan string) {
+			started.reply(ctx, ws, transcript.Text, audioURL)
+		})
+		return true
+	}
+	startTranslationMode := func(data []byte) bool {
+		started := startTranslation(ws, data)
+		if started == nil {
+			return false
+		}
+		translation = started
+		sessionManager.SetTranscriptHandler(func(transcript *models.Transcript, audioURL <-chan string) {
+			started.translate(ctx, ws, transcript.Text, transcript.Language)
+		})
+		return true
+	}
 
 	for {
 		mt, data, err := ws.ReadMessage()
@@ -80,30 +201,60 @@ func (s *Server) handleConnections(w http.ResponseWriter, r *http.Request) {
 				switch msgType {
 				case "audio_start":
 					sessionID, _ := msg["session_id"].(string)
-					sessionManager.StartSession(sessionID)
+					// language 可选，用于覆盖配置中的识别语言
+					language, _ := msg["language"].(string)
+					// post_process 可选，用于按会话开关识别结果的后处理
+					postProcess, ok := msg["post_process"].(bool)
+					if !ok {
+						postProcess = postProcessDefault
+					}
+					// 用户开始新的录音时打断正在进行的回复
+					if chat != nil && bargeIn.Enabled && bargeIn.Mode == bargeInAudioStart {
+						chat.interrupt(bargeInAudioStart)
+					}
+					detector.restart()
+					sessionManager.StartSession(sessionID, models.RecognitionOptions{Language: language}, postProcess)
 				case "audio_end":
 					sessionID, _ := msg["session_id"].(string)
 					if err := sessionManager.EndSession(sessionID, ws); err != nil {
 						logger.Error("处理会话结束失败", "error", err)
 					}
+				case "session_start":
+					openChat(data)
+				case "chat":
+					// 未发送 session_start 时使用默认参数开启新对话
+					if chat == nil && !openChat([]byte(`{}`)) {
+						continue
+					}
+					text, _ := msg["text"].(string)
+					go chat.reply(ctx, ws, text, nil)
+				case "translation_start":
+					startTranslationMode(data)
+				case "translate":
+					// 未发送 translation_start 时使用配置中的目标语言
+					if translation == nil && !startTranslationMode([]byte(`{}`)) {
+						continue
+					}
+					text, _ := msg["text"].(string)
+					language, _ := msg["language"].(string)
+					go translation.translate(ctx, ws, text, language)
 				}
 			} else {
 				// 处理普通文本消息
-				text, _ := msg["text"].(string)
-				requireTTS, _ := msg["require_tts"].(bool)
-
-				if requireTTS {
-					// 调用 TTS 服务
-					audio, err := ttsService.Synthesize(text)
-					if err != nil {
-						logger.Error("语音合成失败", "error", err)
-						continue
-					}
+				var textMsg TextMessage
+				if err := json.Unmarshal(data, &textMsg); err != nil {
+					logger.Error("解析文本消息失败", "error", err)
+					continue
+				}
+				text := textMsg.Text
 
-					// 存储音频文件
-					audioURL, err := storageService.StoreAudio(audio)
+				if textMsg.RequireTTS && textMsg.Stream {
+					go streamSpeech(ctx, ws, text, textMsg.SynthesisOptions)
+				} else if textMsg.RequireTTS {
+					// 调用 TTS 服务，命中缓存时直接复用已存储的音频
+					result, err := ttsCache.Synthesize(text, textMsg.SynthesisOptions)
 					if err != nil {
-						logger.Error("存储音频失败", "error", err)
+						sendTTSError(ws, "", err)
 						continue
 					}
 
@@ -111,7 +262,8 @@ func (s *Server) handleConnections(w http.ResponseWriter, r *http.Request) {
 					response := map[string]interface{}{
 						"type":      "tts_complete",
 						"text":      text,
-						"audio_url": audioURL,
+						"audio_url": result.AudioURL,
+						"cached":    result.Cached(),
 					}
 
 					if err := ws.WriteJSON(response); err != nil {
@@ -126,6 +278,9 @@ func (s *Server) handleConnections(w http.ResponseWriter, r *http.Request) {
 				logger.Error("收到二进制数据但没有活动会话")
 				continue
 			}
+			if chat != nil && bargeIn.Enabled && bargeIn.Mode == bargeInVAD && detector.speech(data) && chat.replying() {
+				chat.interrupt(bargeInVAD)
+			}
 
 			if err := sessionManager.AppendAudioData(currentSession, data); err != nil {
 				logger.Error("追加音频数据失败", "error", err)
@@ -168,3 +323,61 @@ func (s *Server) HandleConfig(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Configuration updated"))
 }
+
+// HandleTTSCacheStats 返回 TTS 缓存的命中统计
+func (s *Server) HandleTTSCacheStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ttsCache.Stats())
+}
+
+// catalogVoice 从音色目录中选择当前 TTS 提供商第一个支持 language 的音色，没有时返回空字符串
+func catalogVoice(language string) string {
+	if voiceCatalog == nil {
+		return ""
+	}
+	list, _ := voiceCatalog.Voices(voices.Filter{Provider: ttsProvider, Language: language})
+	if len(list) > 0 {
+		return list[0].ID
+	}
+	return ""
+}
+
+// resolveVoice 在只指定了语言时按语言选择音色，选到后不再传递语言，
+// 避免火山引擎等由音色决定语种的提供商拒绝请求；目录中没有匹配的音色时保持不变
+func resolveVoice(opts *models.SynthesisOptions) {
+	if opts.Voice != "" || opts.Language == "" {
+		return
+	}
+	if voice := catalogVoice(opts.Language); voice != "" {
+		opts.Voice = voice
+		opts.Language = ""
+	}
+}
+
+// HandleVoices 返回可用音色列表，支持按 provider、language、gender 过滤
+func (s *Server) HandleVoices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	list, updatedAt := voiceCatalog.Voices(voices.Filter{
+		Provider: query.Get("provider"),
+		Language: query.Get("language"),
+		Gender:   query.Get("gender"),
+	})
+
+	// active_provider 为实际用于合成的提供商，其他提供商的音色不能直接用于合成
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"voices":          list,
+		"updated_at":      updatedAt,
+		"active_provider": ttsProvider,
+	})
+}
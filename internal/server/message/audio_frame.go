@@ -0,0 +1,36 @@
+package message
+
+import (
+	"fmt"
+)
+
+// 流式 TTS 下发的二进制帧格式：
+//
+//	| 1 字节 stream ID 长度 n | n 字节 stream ID | 音频数据 |
+//
+// 客户端按 stream ID 将音频块归属到对应的 tts_stream_start 事件
+const maxStreamIDLength = 255
+
+// EncodeAudioFrame 为音频块加上 stream ID 前缀
+func EncodeAudioFrame(streamID string, audio []byte) ([]byte, error) {
+	if len(streamID) == 0 || len(streamID) > maxStreamIDLength {
+		return nil, fmt.Errorf("stream ID 长度必须在 1 到 %d 之间: %q", maxStreamIDLength, streamID)
+	}
+	frame := make([]byte, 0, 1+len(streamID)+len(audio))
+	frame = append(frame, byte(len(streamID)))
+	frame = append(frame, streamID...)
+	frame = append(frame, audio...)
+	return frame, nil
+}
+
+// DecodeAudioFrame 从二进制帧中拆分出 stream ID 和音频数据
+func DecodeAudioFrame(frame []byte) (string, []byte, error) {
+	if len(frame) == 0 {
+		return "", nil, fmt.Errorf("音频帧为空")
+	}
+	n := int(frame[0])
+	if n == 0 || len(frame) < 1+n {
+		return "", nil, fmt.Errorf("音频帧长度不足")
+	}
+	return string(frame[1 : 1+n]), frame[1+n:], nil
+}
@@ -22,7 +22,7 @@ func NewTextMessageHandler(tts tts.Service, storage storage.Service) *TextMessag
 func (h *TextMessageHandler) Handle(conn *websocket.Conn, msg *TextMessage) error {
 	// 如果需要TTS,直接合成语音
 	if msg.RequireTTS {
-		audio, err := h.tts.Synthesize(msg.Text)
+		audio, err := h.tts.Synthesize(msg.Text, msg.SynthesisOptions)
 		if err != nil {
 			return fmt.Errorf("failed to synthesize speech: %w", err)
 		}
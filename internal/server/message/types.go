@@ -1,5 +1,7 @@
 package message
 
+import "github.com/telepace/voiceflow/internal/models"
+
 // MessageType 定义消息类型
 type MessageType int
 
@@ -19,6 +21,7 @@ type SignalMessage struct {
 type TextMessage struct {
     Text       string `json:"text"`
     RequireTTS bool   `json:"require_tts"`
+    models.SynthesisOptions
 }
 
 // BinaryMessage 添加会话信息
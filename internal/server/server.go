@@ -40,4 +40,12 @@ func (s *Server) SetupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
 		s.HandleConfig(w, r)
 	})
+
+	mux.HandleFunc("/v1/voices", func(w http.ResponseWriter, r *http.Request) {
+		s.HandleVoices(w, r)
+	})
+
+	mux.HandleFunc("/v1/tts/cache/stats", func(w http.ResponseWriter, r *http.Request) {
+		s.HandleTTSCacheStats(w, r)
+	})
 }
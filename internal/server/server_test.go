@@ -0,0 +1,141 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/tts/cache"
+)
+
+// fakeTTS 记录每次合成的参数，与火山引擎一样拒绝带有 language 的请求
+type fakeTTS struct {
+	mu    sync.Mutex
+	calls []models.SynthesisOptions
+}
+
+func (f *fakeTTS) Synthesize(text string, opts models.SynthesisOptions) ([]byte, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, opts)
+	f.mu.Unlock()
+	if opts.Language != "" {
+		return nil, models.UnsupportedOption("语种由音色决定")
+	}
+	return []byte("audio:" + opts.Voice + ":" + text), nil
+}
+
+func (f *fakeTTS) options() []models.SynthesisOptions {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]models.SynthesisOptions(nil), f.calls...)
+}
+
+type fakeStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeStorage) StoreAudio(audioData []byte) (string, error) {
+	f.mu.Lock()
+	name := fmt.Sprintf("audio-%d", len(f.objects))
+	f.mu.Unlock()
+	return f.StoreAudioAs(name, audioData)
+}
+
+func (f *fakeStorage) StoreAudioAs(name string, audioData []byte) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[name] = audioData
+	return "url://" + name, nil
+}
+
+func (f *fakeStorage) LookupAudio(name string) (string, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.objects[name]; ok {
+		return "url://" + name, true, nil
+	}
+	return "", false, nil
+}
+
+func (f *fakeStorage) LoadAudio(name string) ([]byte, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[name]
+	return data, ok, nil
+}
+
+// useFakeTTS 将合成和存储替换为 fakeTTS、fakeStorage，测试结束后恢复
+func useFakeTTS(t *testing.T) *fakeTTS {
+	svc := &fakeTTS{}
+	store := &fakeStorage{objects: make(map[string][]byte)}
+	prevTTS, prevCache, prevStorage, prevProvider := ttsService, ttsCache, storageService, ttsProvider
+	ttsService, storageService, ttsProvider = svc, store, "volcengine"
+	ttsCache = cache.New(ttsProvider, svc, store, cache.Options{})
+	t.Cleanup(func() {
+		ttsService, ttsCache, storageService, ttsProvider = prevTTS, prevCache, prevStorage, prevProvider
+	})
+	return svc
+}
+
+// dialTestConn 启动一个 WebSocket 服务端，连接建立后在服务端调用 handle，返回客户端的连接
+func dialTestConn(t *testing.T, handle func(conn *safeConn)) *websocket.Conn {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		handle(newSafeConn(conn))
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("连接测试服务端失败: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// readUntil 读取服务端发送的 JSON 消息，直到收到指定类型的消息
+func readUntil(t *testing.T, client *websocket.Conn, messageType string) []map[string]interface{} {
+	var messages []map[string]interface{}
+	for {
+		var msg map[string]interface{}
+		if err := client.ReadJSON(&msg); err != nil {
+			t.Fatalf("读取消息失败: %v", err)
+		}
+		messages = append(messages, msg)
+		if msg["type"] == messageType {
+			return messages
+		}
+	}
+}
+
+// newServerConn 返回服务端一侧的连接，客户端收到的消息被丢弃
+func newServerConn(t *testing.T) *safeConn {
+	conns := make(chan *safeConn, 1)
+	done := make(chan struct{})
+	client := dialTestConn(t, func(conn *safeConn) {
+		conns <- conn
+		<-done
+	})
+	// 先于关闭测试服务端执行，否则服务端会等待处理函数返回
+	t.Cleanup(func() { close(done) })
+	go func() {
+		for {
+			if _, _, err := client.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	return <-conns
+}
@@ -2,30 +2,84 @@ package server
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"sync"
 
-	"github.com/gorilla/websocket"
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/stt"
+	"github.com/telepace/voiceflow/pkg/logger"
 )
 
+// audioSession 保存单次录音的音频数据和识别参数
+type audioSession struct {
+	buffer *bytes.Buffer
+	opts   models.RecognitionOptions
+	// stream 在提供商支持流式识别时非空，音频边接收边上传
+	stream models.RecognitionStream
+	// postProcess 为 true 时识别结果交给 LLM 修正
+	postProcess bool
+	// streamed 表示录音开始时开启了流式识别，流式识别的音频为 16kHz、单声道、16 位 PCM
+	streamed bool
+}
+
+var streamPCMFormat = audio.Format{SampleRate: 16000, Channels: 1, BitsPerSample: 16}
+
+// TranscriptHandler 在一段录音识别完成后调用
+// audioURL 在录音存储成功后收到地址，存储失败时直接关闭
+type TranscriptHandler func(transcript *models.Transcript, audioURL <-chan string)
+
 type SessionManager struct {
-	sessions       map[string]*bytes.Buffer
+	sessions       map[string]*audioSession
 	currentSession string
+	onTranscript   TranscriptHandler
 	mu             sync.RWMutex
 }
 
 func NewSessionManager() *SessionManager {
 	return &SessionManager{
-		sessions: make(map[string]*bytes.Buffer),
+		sessions: make(map[string]*audioSession),
 	}
 }
 
-func (sm *SessionManager) StartSession(sessionID string) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+func (sm *SessionManager) StartSession(sessionID string, opts models.RecognitionOptions, postProcess bool) {
+	session := &audioSession{
+		buffer:      &bytes.Buffer{},
+		opts:        opts,
+		postProcess: postProcess,
+	}
+
+	stream, err := stt.StartStream(sttService, opts)
+	switch {
+	case err == nil:
+		session.stream = stream
+		session.streamed = true
+	case errors.Is(err, stt.ErrStreamingUnsupported):
+	default:
+		logger.Warnf("开启流式识别失败，录音结束后再识别: %v", err)
+	}
 
-	sm.sessions[sessionID] = &bytes.Buffer{}
+	sm.mu.Lock()
+	old := sm.sessions[sessionID]
+	sm.sessions[sessionID] = session
 	sm.currentSession = sessionID
+	sm.mu.Unlock()
+
+	// Abort 会等待识别请求结束，不能阻塞连接的读循环
+	if old != nil {
+		if stream := old.takeStream(sm); stream != nil {
+			go stream.Abort()
+		}
+	}
+}
+
+// SetTranscriptHandler 设置识别完成后的回调，对话模式下用于触发助手回复
+func (sm *SessionManager) SetTranscriptHandler(handler TranscriptHandler) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.onTranscript = handler
 }
 
 func (sm *SessionManager) GetCurrentSession() string {
@@ -36,30 +90,47 @@ func (sm *SessionManager) GetCurrentSession() string {
 
 func (sm *SessionManager) AppendAudioData(sessionID string, data []byte) error {
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
-
-	buffer, exists := sm.sessions[sessionID]
+	session, exists := sm.sessions[sessionID]
 	if !exists {
+		sm.mu.Unlock()
 		return fmt.Errorf("session not found: %s", sessionID)
 	}
+	_, err := session.buffer.Write(data)
+	stream := session.stream
+	sm.mu.Unlock()
 
-	_, err := buffer.Write(data)
+	// 写入识别流在锁外进行，避免上传变慢时阻塞同一连接上的其他消息
+	if stream != nil {
+		if err := stream.Write(data); err != nil {
+			// 流式识别失败后退回到录音结束时整体识别
+			logger.Warnf("写入流式识别失败，录音结束后再识别: %v", err)
+			if session.takeStream(sm) == stream {
+				go stream.Abort()
+			}
+		}
+	}
 	return err
 }
 
-func (sm *SessionManager) EndSession(sessionID string, ws *websocket.Conn) error {
+func (sm *SessionManager) EndSession(sessionID string, ws *safeConn) error {
 	sm.mu.Lock()
-	buffer, exists := sm.sessions[sessionID]
-	audioData := buffer.Bytes()
+	session, exists := sm.sessions[sessionID]
+	onTranscript := sm.onTranscript
 	sm.mu.Unlock()
 
 	if !exists {
 		return fmt.Errorf("session not found: %s", sessionID)
 	}
+	sm.mu.Lock()
+	audioData := session.buffer.Bytes()
+	stream := session.stream
+	sm.mu.Unlock()
 
 	go func() {
 		audioURLChan := make(chan string, 1)
 		errChan := make(chan error, 1)
+		// stored 将录音地址转交给 onTranscript，无论存储成功与否都会关闭
+		stored := make(chan string, 1)
 
 		go func() {
 			audioURL, err := storageService.StoreAudio(audioData)
@@ -71,7 +142,7 @@ func (sm *SessionManager) EndSession(sessionID string, ws *websocket.Conn) error
 		}()
 
 		go func() {
-			text, err := sttService.Recognize(audioData, "")
+			transcript, err := session.recognize(stream, audioData)
 			if err != nil {
 				ws.WriteJSON(map[string]interface{}{
 					"type":       "recognition_error",
@@ -81,11 +152,38 @@ func (sm *SessionManager) EndSession(sessionID string, ws *websocket.Conn) error
 				return
 			}
 
-			ws.WriteJSON(map[string]interface{}{
+			response := map[string]interface{}{
 				"type":       "recognition_complete",
 				"session_id": sessionID,
-				"text":       text,
-			})
+				"text":       transcript.Text,
+			}
+			// 提供商返回了分段或候选信息时一并下发，便于生成字幕和按置信度复核
+			if len(transcript.Segments) > 0 {
+				response["segments"] = transcript.Segments
+			}
+			if len(transcript.Alternatives) > 0 {
+				response["alternatives"] = transcript.Alternatives
+			}
+			if transcript.Language != "" {
+				response["language"] = transcript.Language
+			}
+			// text 始终为原始识别结果，修正后的文本放在 cleaned_text 中，后续的对话使用修正后的文本
+			if session.postProcess && transcript.Text != "" {
+				if cleaned, err := transcriptCleaner.Clean(context.Background(), transcript.Text); err != nil {
+					logger.Warnf("识别结果后处理失败，使用原始结果: %v", err)
+					response["post_process_error"] = err.Error()
+				} else {
+					response["cleaned_text"] = cleaned
+					corrected := *transcript
+					corrected.Text = cleaned
+					transcript = &corrected
+				}
+			}
+			ws.WriteJSON(response)
+
+			if onTranscript != nil && transcript.Text != "" {
+				onTranscript(transcript, stored)
+			}
 		}()
 
 		select {
@@ -95,12 +193,14 @@ func (sm *SessionManager) EndSession(sessionID string, ws *websocket.Conn) error
 				"session_id": sessionID,
 				"audio_url":  audioURL,
 			})
+			stored <- audioURL
 		case err := <-errChan:
 			ws.WriteJSON(map[string]interface{}{
 				"type":  "storage_error",
 				"error": err.Error(),
 			})
 		}
+		close(stored)
 	}()
 
 	sm.mu.Lock()
@@ -112,3 +212,28 @@ func (sm *SessionManager) EndSession(sessionID string, ws *websocket.Conn) error
 
 	return nil
 }
+
+// takeStream 取出并清除会话的识别流，识别流已被取出时返回 nil
+func (s *audioSession) takeStream(sm *SessionManager) models.RecognitionStream {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	stream := s.stream
+	s.stream = nil
+	return stream
+}
+
+// recognize 优先使用流式识别的结果，流式识别失败时对完整音频重新识别
+func (s *audioSession) recognize(stream models.RecognitionStream, audioData []byte) (*models.Transcript, error) {
+	if stream != nil {
+		transcript, err := stream.Finish()
+		if err == nil {
+			return transcript, nil
+		}
+		logger.Warnf("流式识别失败，使用完整音频重新识别: %v", err)
+	}
+	if s.streamed && audio.DetectContainer(audioData) == audio.ContainerUnknown {
+		// 流式识别的裸 PCM 封装为 WAV，超过提供商限制时可以切分识别
+		audioData = audio.EncodeWAV(streamPCMFormat, audioData)
+	}
+	return stt.RecognizeDetailed(sttService, audioData, "", s.opts)
+}
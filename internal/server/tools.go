@@ -0,0 +1,40 @@
+// tools.go - 对话中的工具调用
+package server
+
+import (
+	"encoding/json"
+
+	"github.com/telepace/voiceflow/internal/llm"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+// RegisterTool 注册一个由 Go 代码实现的工具，注册后的工具对之后的所有对话可用
+// 配置文件中声明的 webhook 工具由 InitServices 注册
+func RegisterTool(tool models.Tool, handler llm.ToolHandler) error {
+	return toolRegistry.Register(tool, handler)
+}
+
+// sendToolEvent 通知客户端助手调用了工具：开始执行时发送 tool_call，工具返回后发送 tool_result
+func sendToolEvent(conn *safeConn, sessionID string, event llm.ToolEvent) {
+	response := map[string]interface{}{
+		"type":       "tool_call",
+		"session_id": sessionID,
+		"call_id":    event.Call.ID,
+		"name":       event.Call.Function.Name,
+	}
+	switch {
+	case !event.Done:
+		if json.Valid([]byte(event.Call.Function.Arguments)) {
+			response["arguments"] = json.RawMessage(event.Call.Function.Arguments)
+		} else {
+			response["arguments"] = event.Call.Function.Arguments
+		}
+	case event.Err != nil:
+		response["type"] = "tool_result"
+		response["error"] = event.Err.Error()
+	default:
+		response["type"] = "tool_result"
+		response["result"] = event.Result
+	}
+	conn.WriteJSON(response)
+}
@@ -0,0 +1,153 @@
+// translation.go - 语音翻译模式
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/pkg/logger"
+)
+
+// translationStartMessage 为客户端开启翻译模式的消息
+type translationStartMessage struct {
+	// SourceLanguage 为原文语言，为空时使用识别结果中的语言，仍未知时由翻译服务判断
+	SourceLanguage string   `json:"source_language"`
+	Targets        []string `json:"targets"`     // 目标语言，为空时使用配置中的 targets
+	RequireTTS     bool     `json:"require_tts"` // 为 true 时每个译文都会合成语音
+	// Voices 按目标语言指定音色，优先于配置
+	Voices map[string]string `json:"voices"`
+	// 合成参数，voice 和 language 按目标语言选择
+	models.SynthesisOptions
+}
+
+// translationSession 为一个连接上的翻译模式
+type translationSession struct {
+	config translationStartMessage
+	voices map[string]string
+}
+
+// startTranslation 开启翻译模式并发送 translation_started，失败时通知客户端并返回 nil
+func startTranslation(conn *safeConn, data []byte) *translationSession {
+	var msg translationStartMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		sendTranslationError(conn, "", "", err)
+		return nil
+	}
+	if err := msg.SynthesisOptions.Validate(); err != nil {
+		sendTranslationError(conn, "", "", err)
+		return nil
+	}
+	if len(msg.Targets) == 0 {
+		msg.Targets = translationTargets
+	}
+
+	t := &translationSession{config: msg, voices: make(map[string]string)}
+	for _, target := range msg.Targets {
+		t.voices[target] = t.voiceFor(target)
+	}
+
+	if err := conn.WriteJSON(map[string]interface{}{
+		"type":            "translation_started",
+		"source_language": msg.SourceLanguage,
+		"targets":         msg.Targets,
+		"voices":          t.voices,
+	}); err != nil {
+		logger.Error("发送响应失败", "error", err)
+	}
+	return t
+}
+
+// voiceFor 选择目标语言的音色：客户端指定的优先，其次是配置，最后从音色目录中选择当前 TTS 提供商的第一个匹配音色
+func (t *translationSession) voiceFor(language string) string {
+	for key, voice := range t.config.Voices {
+		if strings.EqualFold(key, language) {
+			return voice
+		}
+	}
+	// viper 会将配置中的键转为小写
+	if voice, ok := translationVoices[strings.ToLower(language)]; ok {
+		return voice
+	}
+	return catalogVoice(language)
+}
+
+// translate 将一段原文并发翻译为全部目标语言，每个目标语言完成后发送一条 translation_result
+func (t *translationSession) translate(ctx context.Context, conn *safeConn, text, language string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	source := t.config.SourceLanguage
+	if source == "" {
+		source = language
+	}
+	utteranceID := uuid.New().String()
+
+	var wg sync.WaitGroup
+	for _, target := range t.config.Targets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			translated, err := translator.Translate(ctx, text, source, target)
+			if err != nil {
+				sendTranslationError(conn, utteranceID, target, err)
+				return
+			}
+
+			response := map[string]interface{}{
+				"type":            "translation_result",
+				"utterance_id":    utteranceID,
+				"source_text":     text,
+				"source_language": source,
+				"target_language": target,
+				"text":            translated,
+			}
+			if t.config.RequireTTS {
+				opts := t.config.SynthesisOptions
+				opts.Voice = t.voices[target]
+				// 音色已经决定了语言，火山引擎等提供商不接受同时指定 language；
+				// 没有可用的音色时才通过 language 让提供商选择
+				opts.Language = ""
+				if opts.Voice == "" {
+					opts.Language = target
+				}
+				if result, err := ttsCache.Synthesize(translated, opts); err != nil {
+					sendTTSError(conn, "", err)
+				} else {
+					response["voice"] = opts.Voice
+					response["audio_url"] = result.AudioURL
+				}
+			}
+			if err := conn.WriteJSON(response); err != nil {
+				logger.Error("发送响应失败", "error", err)
+			}
+		}(target)
+	}
+	wg.Wait()
+
+	conn.WriteJSON(map[string]interface{}{
+		"type":         "translation_complete",
+		"utterance_id": utteranceID,
+		"source_text":  text,
+	})
+}
+
+// sendTranslationError 通知客户端翻译失败
+func sendTranslationError(conn *safeConn, utteranceID, target string, err error) {
+	logger.Error("翻译失败", "error", err)
+	response := map[string]interface{}{
+		"type":  "translation_error",
+		"error": err.Error(),
+	}
+	if utteranceID != "" {
+		response["utterance_id"] = utteranceID
+	}
+	if target != "" {
+		response["target_language"] = target
+	}
+	conn.WriteJSON(response)
+}
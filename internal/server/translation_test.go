@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+type fakeTranslator struct{}
+
+func (fakeTranslator) Translate(ctx context.Context, text, source, target string) (string, error) {
+	return "[" + target + "]" + text, nil
+}
+
+func TestTranslationWithTTS(t *testing.T) {
+	svc := useFakeTTS(t)
+	prevTranslator, prevVoices := translator, translationVoices
+	translator = fakeTranslator{}
+	translationVoices = map[string]string{"en-us": "BV503_streaming"}
+	defer func() { translator, translationVoices = prevTranslator, prevVoices }()
+
+	client := dialTestConn(t, func(conn *safeConn) {
+		session := startTranslation(conn, []byte(`{"targets":["en-US","ja-JP"],"require_tts":true,"voices":{"ja-JP":"BV522_streaming"},"language":"zh-CN"}`))
+		if assert.NotNil(t, session) {
+			session.translate(context.Background(), conn, "你好", "zh-CN")
+		}
+	})
+
+	started := readUntil(t, client, "translation_started")
+	assert.Equal(t, map[string]interface{}{"en-US": "BV503_streaming", "ja-JP": "BV522_streaming"}, started[len(started)-1]["voices"])
+
+	messages := readUntil(t, client, "translation_complete")
+	results := map[string]map[string]interface{}{}
+	for _, msg := range messages {
+		assert.NotEqual(t, "tts_error", msg["type"], msg["error"])
+		if msg["type"] == "translation_result" {
+			results[msg["target_language"].(string)] = msg
+		}
+	}
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, "[en-US]你好", results["en-US"]["text"])
+		assert.Equal(t, "BV503_streaming", results["en-US"]["voice"])
+		assert.NotEmpty(t, results["en-US"]["audio_url"])
+		assert.Equal(t, "BV522_streaming", results["ja-JP"]["voice"])
+		assert.NotEmpty(t, results["ja-JP"]["audio_url"])
+	}
+
+	// 选定了音色时不再传递 language，客户端指定的 language 也被忽略
+	for _, opts := range svc.options() {
+		assert.Empty(t, opts.Language)
+	}
+}
+
+func TestTranslationWithoutVoiceFallsBackToLanguage(t *testing.T) {
+	svc := useFakeTTS(t)
+	prevTranslator, prevVoices, prevCatalog := translator, translationVoices, voiceCatalog
+	translator = fakeTranslator{}
+	translationVoices = map[string]string{}
+	voiceCatalog = nil
+	defer func() { translator, translationVoices, voiceCatalog = prevTranslator, prevVoices, prevCatalog }()
+
+	client := dialTestConn(t, func(conn *safeConn) {
+		session := startTranslation(conn, []byte(`{"targets":["fr-FR"],"require_tts":true}`))
+		if assert.NotNil(t, session) {
+			session.translate(context.Background(), conn, "你好", "zh-CN")
+		}
+	})
+
+	// 没有可用的音色时由 language 交给提供商选择，不接受 language 的提供商返回参数错误
+	messages := readUntil(t, client, "translation_complete")
+	var ttsError map[string]interface{}
+	for _, msg := range messages {
+		if msg["type"] == "tts_error" {
+			ttsError = msg
+		}
+	}
+	if assert.NotNil(t, ttsError) {
+		assert.Equal(t, true, ttsError["invalid_options"])
+		assert.Contains(t, ttsError["error"], "语种")
+	}
+	assert.Equal(t, []models.SynthesisOptions{{Language: "fr-FR"}}, svc.options())
+}
@@ -0,0 +1,149 @@
+// tts_stream.go - 流式语音合成
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/server/message"
+	"github.com/telepace/voiceflow/internal/tts"
+	"github.com/telepace/voiceflow/internal/tts/cache"
+	"github.com/telepace/voiceflow/pkg/logger"
+)
+
+// cachedFrameSize 下发缓存音频时每个二进制帧的大小
+const cachedFrameSize = 32 << 10
+
+// streamSpeech 将合成的音频块实时转发给客户端
+// 事件顺序：tts_stream_start → 若干二进制音频帧 → tts_complete，完整音频在后台存储后再发送 tts_stored
+// 命中缓存时不再合成，事件顺序不变，已存储的音频同样以二进制帧下发，tts_complete 带上 audio_url
+func streamSpeech(ctx context.Context, conn *safeConn, text string, opts models.SynthesisOptions) {
+	streamID := uuid.New().String()
+
+	if cached, ok := ttsCache.LookupAudio(text, opts); ok {
+		streamCached(ctx, conn, streamID, text, cached)
+		return
+	}
+
+	chunks, err := tts.SynthesizeStream(ctx, ttsService, text, opts)
+	if err != nil {
+		sendTTSError(conn, streamID, err)
+		return
+	}
+
+	if err := conn.WriteJSON(map[string]interface{}{
+		"type":      "tts_stream_start",
+		"stream_id": streamID,
+		"text":      text,
+	}); err != nil {
+		logger.Error("发送响应失败", "error", err)
+		return
+	}
+
+	var audioBuffer bytes.Buffer
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			sendTTSError(conn, streamID, chunk.Err)
+			return
+		}
+		audioBuffer.Write(chunk.Data)
+
+		frame, err := message.EncodeAudioFrame(streamID, chunk.Data)
+		if err != nil {
+			logger.Error("编码音频帧失败", "error", err)
+			return
+		}
+		if err := conn.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+			logger.Error("发送音频数据失败", "error", err)
+			return
+		}
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err := conn.WriteJSON(map[string]interface{}{
+		"type":      "tts_complete",
+		"stream_id": streamID,
+		"text":      text,
+	}); err != nil {
+		logger.Error("发送响应失败", "error", err)
+	}
+
+	// 客户端已经拿到全部音频，存储不再阻塞播放
+	go func() {
+		audioURL, err := ttsCache.Store(text, opts, audioBuffer.Bytes())
+		if err != nil {
+			logger.Error("存储音频失败", "error", err)
+			conn.WriteJSON(map[string]interface{}{
+				"type":      "storage_error",
+				"stream_id": streamID,
+				"error":     err.Error(),
+			})
+			return
+		}
+		conn.WriteJSON(map[string]interface{}{
+			"type":      "tts_stored",
+			"stream_id": streamID,
+			"audio_url": audioURL,
+		})
+	}()
+}
+
+// streamCached 将缓存中的音频按流式合成的事件顺序下发
+func streamCached(ctx context.Context, conn *safeConn, streamID, text string, cached *cache.Result) {
+	if err := conn.WriteJSON(map[string]interface{}{
+		"type":      "tts_stream_start",
+		"stream_id": streamID,
+		"text":      text,
+		"cached":    true,
+	}); err != nil {
+		logger.Error("发送响应失败", "error", err)
+		return
+	}
+	for start := 0; start < len(cached.Audio); start += cachedFrameSize {
+		if ctx.Err() != nil {
+			return
+		}
+		end := start + cachedFrameSize
+		if end > len(cached.Audio) {
+			end = len(cached.Audio)
+		}
+		frame, err := message.EncodeAudioFrame(streamID, cached.Audio[start:end])
+		if err != nil {
+			logger.Error("编码音频帧失败", "error", err)
+			return
+		}
+		if err := conn.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+			logger.Error("发送音频数据失败", "error", err)
+			return
+		}
+	}
+	if err := conn.WriteJSON(map[string]interface{}{
+		"type":      "tts_complete",
+		"stream_id": streamID,
+		"text":      text,
+		"audio_url": cached.AudioURL,
+		"cached":    true,
+	}); err != nil {
+		logger.Error("发送响应失败", "error", err)
+	}
+}
+
+// sendTTSError 通知客户端合成失败，参数不被提供商支持时 invalid_options 为 true
+func sendTTSError(conn *safeConn, streamID string, err error) {
+	logger.Error("语音合成失败", "error", err)
+	response := map[string]interface{}{
+		"type":            "tts_error",
+		"error":           err.Error(),
+		"invalid_options": errors.Is(err, models.ErrUnsupportedOption),
+	}
+	if streamID != "" {
+		response["stream_id"] = streamID
+	}
+	conn.WriteJSON(response)
+}
@@ -0,0 +1,344 @@
+// voice_reply.go - 流式语音回复：LLM 的输出按句切分，每句完整后立即合成
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/internal/llm"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/server/message"
+	"github.com/telepace/voiceflow/internal/tts"
+	"github.com/telepace/voiceflow/internal/tts/textsplit"
+	"github.com/telepace/voiceflow/pkg/logger"
+)
+
+const (
+	// replyClauseRunes 句子过长时在分句标点处提前送去合成，降低首句的等待时间
+	replyClauseRunes = 40
+	// replySynthesisAhead 逐句合成时最多领先发送进度的句子数
+	replySynthesisAhead = 2
+)
+
+// replyMetrics 记录一次流式回复的延迟，均从收到用户输入开始计算
+type replyMetrics struct {
+	FirstTokenMs int64 `json:"time_to_first_token_ms"`
+	FirstAudioMs int64 `json:"time_to_first_audio_ms,omitempty"`
+	TotalMs      int64 `json:"total_ms"`
+	Sentences    int   `json:"sentences"`
+}
+
+// spokenReply 为一次流式回复的完整文本、拼接后的音频和延迟
+// delivered 为已经下发给客户端的句子，需要语音时以该句音频已下发为准
+type spokenReply struct {
+	text      string
+	audio     []byte
+	metrics   replyMetrics
+	delivered []string
+}
+
+// replyStream 将 LLM 的流式输出切分为句子，speak 为 true 时逐句合成并按顺序以二进制帧下发
+// 每切出一句发送一条 chat_sentence，音频帧使用 streamID 标识
+type replyStream struct {
+	conn      *safeConn
+	sessionID string
+	streamID  string
+	speak     bool
+	chatOpts  models.ChatOptions
+	opts      models.SynthesisOptions
+	start     time.Time
+
+	reply spokenReply
+
+	// delivered 可能在增量合成的写入协程中追加
+	mu        sync.Mutex
+	delivered []string
+}
+
+func (r *replyStream) since() int64 {
+	return time.Since(r.start).Milliseconds()
+}
+
+// run 请求 LLM 并等待文本和音频全部发送完毕
+// parent 被取消时返回已生成的部分和 parent 的错误，其中 delivered 记录已经下发的句子
+func (r *replyStream) run(parent context.Context, history []models.ChatMessage) (*spokenReply, error) {
+	// 任一环节失败时取消 LLM 和合成
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	sentences := make(chan string, 16)
+	var (
+		text   bytes.Buffer
+		llmErr error
+	)
+	produced := make(chan struct{})
+	go func() {
+		defer close(produced)
+		defer close(sentences)
+
+		segmenter := textsplit.Segmenter{MaxRunes: replyClauseRunes}
+		emit := func(sentence string) bool {
+			r.reply.metrics.Sentences++
+			select {
+			case sentences <- sentence:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// 模型请求调用工具时执行工具并继续生成，工具调用前后的文本都会播报
+		messages := append([]models.ChatMessage(nil), history...)
+		opts := r.chatOpts
+		opts.Tools = toolRegistry.Tools()
+		for round := 0; ; round++ {
+			if round == maxToolRounds {
+				opts.Tools = nil
+			}
+			chunks, err := llm.ChatStream(ctx, llmService, messages, opts)
+			if err != nil {
+				llmErr = err
+				return
+			}
+			var (
+				content   bytes.Buffer
+				toolCalls []models.ToolCall
+			)
+			for chunk := range chunks {
+				if chunk.Err != nil {
+					llmErr = chunk.Err
+					return
+				}
+				if len(chunk.ToolCalls) > 0 {
+					toolCalls = chunk.ToolCalls
+				}
+				if chunk.Content == "" {
+					continue
+				}
+				if text.Len() == 0 {
+					r.reply.metrics.FirstTokenMs = r.since()
+				}
+				text.WriteString(chunk.Content)
+				content.WriteString(chunk.Content)
+				for _, sentence := range segmenter.Write(chunk.Content) {
+					if !emit(sentence) {
+						return
+					}
+				}
+			}
+			if ctx.Err() != nil || len(toolCalls) == 0 || len(opts.Tools) == 0 {
+				break
+			}
+			messages = append(messages, models.ChatMessage{
+				Role:      models.RoleAssistant,
+				Content:   content.String(),
+				ToolCalls: toolCalls,
+			})
+			messages = append(messages, llm.RunToolCalls(ctx, toolRegistry, toolCalls, func(event llm.ToolEvent) {
+				sendToolEvent(r.conn, r.sessionID, event)
+			})...)
+		}
+		if rest := segmenter.Flush(); rest != "" {
+			emit(rest)
+		}
+	}()
+
+	var err error
+	if r.speak {
+		err = r.speakSentences(ctx, sentences)
+	} else {
+		for sentence := range sentences {
+			r.sendSentence(sentence)
+			r.deliver(sentence)
+		}
+	}
+	cancel()
+	<-produced
+
+	r.reply.text = text.String()
+	r.reply.metrics.TotalMs = r.since()
+	r.mu.Lock()
+	r.reply.delivered = append([]string(nil), r.delivered...)
+	r.mu.Unlock()
+
+	if err := parent.Err(); err != nil {
+		return &r.reply, err
+	}
+	if llmErr != nil {
+		return nil, llmErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r.reply, nil
+}
+
+// speakSentences 优先使用提供商的增量合成，不支持时逐句合成
+func (r *replyStream) speakSentences(ctx context.Context, sentences <-chan string) error {
+	stream, err := tts.StartIncremental(ctx, ttsService, r.opts)
+	if err == nil {
+		return r.speakIncremental(ctx, stream, sentences)
+	}
+	if !errors.Is(err, tts.ErrIncrementalUnsupported) {
+		logger.Warnf("开启增量合成失败，改为逐句合成: %v", err)
+	}
+	return r.speakEachSentence(ctx, sentences)
+}
+
+// speakIncremental 将句子依次写入同一个合成流，提供商返回的是一段连续的音频
+// 提供商每报告一句合成完毕，最早写入且尚未下发的一句即视为已下发，此时该句的音频帧都已发出；
+// 提供商自行断句，断句与写入的句子不一致时只是近似。全部音频下发完毕后其余的句子也视为已下发
+func (r *replyStream) speakIncremental(ctx context.Context, stream models.SynthesisStream, sentences <-chan string) error {
+	defer stream.Abort()
+
+	var (
+		mu      sync.Mutex
+		written []string // 已写入合成流、尚未确认下发的句子
+	)
+	go func() {
+		for sentence := range sentences {
+			r.sendSentence(sentence)
+			mu.Lock()
+			written = append(written, sentence)
+			mu.Unlock()
+			if err := stream.Write(sentence); err != nil {
+				logger.Warnf("写入增量合成失败: %v", err)
+				mu.Lock()
+				written = written[:len(written)-1]
+				mu.Unlock()
+				break
+			}
+		}
+		stream.Finish()
+	}()
+	// deliverWritten 将最早写入的 n 句标记为已下发，n 小于 0 表示全部
+	deliverWritten := func(n int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if n < 0 || n > len(written) {
+			n = len(written)
+		}
+		for _, sentence := range written[:n] {
+			r.deliver(sentence)
+		}
+		written = written[n:]
+	}
+
+	var buf bytes.Buffer
+	for chunk := range stream.Audio() {
+		if chunk.Err != nil {
+			return chunk.Err
+		}
+		if chunk.SentenceEnd {
+			deliverWritten(1)
+			continue
+		}
+		buf.Write(chunk.Data)
+		if err := r.sendAudio(chunk.Data); err != nil {
+			return err
+		}
+	}
+	// 被打断时合成流也会关闭，此时尚未确认的句子不算已下发
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	deliverWritten(-1)
+	r.reply.audio = buf.Bytes()
+	return nil
+}
+
+// speakEachSentence 并发合成多句，按句子顺序拼接为连续的音频流下发
+func (r *replyStream) speakEachSentence(ctx context.Context, sentences <-chan string) error {
+	type result struct {
+		sentence string
+		audio    []byte
+		err      error
+	}
+	queue := make(chan chan result, replySynthesisAhead)
+	go func() {
+		defer close(queue)
+		for sentence := range sentences {
+			r.sendSentence(sentence)
+			res := make(chan result, 1)
+			select {
+			case queue <- res:
+			case <-ctx.Done():
+				return
+			}
+			go func(sentence string) {
+				// 被打断时 ctx 取消，支持的提供商随之中止请求
+				data, err := tts.SynthesizeContext(ctx, ttsService, sentence, r.opts)
+				res <- result{sentence, data, err}
+			}(sentence)
+		}
+	}()
+
+	var (
+		joiner audio.Joiner
+		parts  [][]byte
+	)
+	for res := range queue {
+		var out result
+		select {
+		case out = <-res:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if out.err != nil {
+			return out.err
+		}
+		data, err := joiner.Next(out.audio)
+		if err != nil {
+			return err
+		}
+		if err := r.sendAudio(data); err != nil {
+			return err
+		}
+		r.deliver(out.sentence)
+		parts = append(parts, out.audio)
+	}
+
+	if len(parts) > 0 {
+		full, err := audio.Concat(parts)
+		if err != nil {
+			return err
+		}
+		r.reply.audio = full
+	}
+	return nil
+}
+
+func (r *replyStream) sendSentence(sentence string) {
+	r.conn.WriteJSON(map[string]interface{}{
+		"type":       "chat_sentence",
+		"session_id": r.sessionID,
+		"stream_id":  r.streamID,
+		"text":       sentence,
+	})
+}
+
+// deliver 记录一句已经下发给客户端
+func (r *replyStream) deliver(sentence string) {
+	r.mu.Lock()
+	r.delivered = append(r.delivered, sentence)
+	r.mu.Unlock()
+}
+
+func (r *replyStream) sendAudio(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if r.reply.metrics.FirstAudioMs == 0 {
+		r.reply.metrics.FirstAudioMs = r.since()
+	}
+	frame, err := message.EncodeAudioFrame(r.streamID, data)
+	if err != nil {
+		return err
+	}
+	return r.conn.WriteMessage(websocket.BinaryMessage, frame)
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+// fakeSynthesisStream 由测试扮演提供商：读取写入的文本，并按需返回音频
+type fakeSynthesisStream struct {
+	written  chan string
+	audio    chan models.AudioChunk
+	finished chan struct{}
+	once     sync.Once
+}
+
+func newFakeSynthesisStream() *fakeSynthesisStream {
+	return &fakeSynthesisStream{
+		written:  make(chan string, 16),
+		audio:    make(chan models.AudioChunk),
+		finished: make(chan struct{}),
+	}
+}
+
+func (f *fakeSynthesisStream) Write(text string) error {
+	f.written <- text
+	return nil
+}
+
+func (f *fakeSynthesisStream) Audio() <-chan models.AudioChunk { return f.audio }
+
+func (f *fakeSynthesisStream) Finish() error {
+	f.once.Do(func() { close(f.finished) })
+	return nil
+}
+
+func (f *fakeSynthesisStream) Abort() {}
+
+func newTestReplyStream(t *testing.T) *replyStream {
+	return &replyStream{
+		conn:      newServerConn(t),
+		sessionID: "s1",
+		streamID:  "stream-1",
+		speak:     true,
+		start:     time.Now(),
+	}
+}
+
+func sentenceChannel(sentences ...string) chan string {
+	ch := make(chan string, len(sentences))
+	for _, sentence := range sentences {
+		ch <- sentence
+	}
+	close(ch)
+	return ch
+}
+
+func TestSpeakIncrementalInterrupted(t *testing.T) {
+	r := newTestReplyStream(t)
+	stream := newFakeSynthesisStream()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- r.speakIncremental(ctx, stream, sentenceChannel("第一句。", "第二句。"))
+	}()
+	assert.Equal(t, "第一句。", <-stream.written)
+	assert.Equal(t, "第二句。", <-stream.written)
+
+	// 第一句的音频全部发出，第二句只发出一部分时被打断
+	stream.audio <- models.AudioChunk{Data: []byte("a1")}
+	stream.audio <- models.AudioChunk{SentenceEnd: true}
+	stream.audio <- models.AudioChunk{Data: []byte("a2")}
+	cancel()
+	close(stream.audio)
+
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Equal(t, []string{"第一句。"}, r.delivered)
+}
+
+func TestSpeakIncrementalNotDeliveredBeforeAudio(t *testing.T) {
+	r := newTestReplyStream(t)
+	stream := newFakeSynthesisStream()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- r.speakIncremental(ctx, stream, sentenceChannel("第一句。"))
+	}()
+	<-stream.written
+	// 写入合成流但还没有音频时被打断，不算已下发
+	cancel()
+	close(stream.audio)
+
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Empty(t, r.delivered)
+}
+
+func TestSpeakIncrementalCompleted(t *testing.T) {
+	r := newTestReplyStream(t)
+	stream := newFakeSynthesisStream()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- r.speakIncremental(context.Background(), stream, sentenceChannel("第一句。", "第二句。", "第三句。"))
+	}()
+	for i := 0; i < 3; i++ {
+		<-stream.written
+	}
+	// 提供商断句与写入的句子不一致时，全部音频发出后其余的句子也视为已下发
+	stream.audio <- models.AudioChunk{Data: []byte("a1")}
+	stream.audio <- models.AudioChunk{SentenceEnd: true}
+	stream.audio <- models.AudioChunk{Data: []byte("a2")}
+	<-stream.finished
+	close(stream.audio)
+
+	assert.NoError(t, <-done)
+	assert.Equal(t, []string{"第一句。", "第二句。", "第三句。"}, r.delivered)
+	assert.Equal(t, []byte("a1a2"), r.reply.audio)
+}
+
+// blockingTTS 在 ctx 取消前不返回，记录被取消的请求数
+type blockingTTS struct {
+	started  chan string
+	canceled atomic.Int32
+}
+
+func (b *blockingTTS) Synthesize(text string, opts models.SynthesisOptions) ([]byte, error) {
+	return b.SynthesizeContext(context.Background(), text, opts)
+}
+
+func (b *blockingTTS) SynthesizeContext(ctx context.Context, text string, opts models.SynthesisOptions) ([]byte, error) {
+	b.started <- text
+	<-ctx.Done()
+	b.canceled.Add(1)
+	return nil, ctx.Err()
+}
+
+func TestSpeakEachSentenceCancelsSynthesis(t *testing.T) {
+	svc := &blockingTTS{started: make(chan string, 4)}
+	prev := ttsService
+	ttsService = svc
+	defer func() { ttsService = prev }()
+
+	r := newTestReplyStream(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.speakEachSentence(ctx, sentenceChannel("第一句。", "第二句。"))
+	}()
+	// 两句并发合成，开始的顺序不固定
+	assert.ElementsMatch(t, []string{"第一句。", "第二句。"}, []string{<-svc.started, <-svc.started})
+
+	// 打断后正在进行的合成随 ctx 一起取消
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Eventually(t, func() bool { return svc.canceled.Load() == 2 }, time.Second, 5*time.Millisecond)
+	assert.Empty(t, r.delivered)
+}
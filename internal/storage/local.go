@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/telepace/voiceflow/internal/audio"
 	"github.com/telepace/voiceflow/pkg/config"
 	"github.com/telepace/voiceflow/pkg/logger"
 )
@@ -34,7 +36,7 @@ func (l *LocalStorageService) StoreAudio(audioData []byte) (string, error) {
 	}
 
 	// 创建文件名（可以基于时间戳生成唯一的文件名）
-	fileName := fmt.Sprintf("audio_%d.wav", time.Now().UnixNano())
+	fileName := fmt.Sprintf("audio_%d%s", time.Now().UnixNano(), audio.DetectContainer(audioData).Extension)
 	filePath := filepath.Join(l.storagePath, fileName)
 
 	// 将音频数据写入文件
@@ -46,3 +48,49 @@ func (l *LocalStorageService) StoreAudio(audioData []byte) (string, error) {
 	// 返回文件的相对路径
 	return filePath, nil
 }
+
+// StoreAudioAs 将音频存储为 storagePath/name + 扩展名
+func (l *LocalStorageService) StoreAudioAs(name string, audioData []byte) (string, error) {
+	filePath := filepath.Join(l.storagePath, name) + audio.DetectContainer(audioData).Extension
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return "", fmt.Errorf("error creating storage directory: %v", err)
+	}
+
+	// 先写临时文件再重命名，避免并发读取到写了一半的文件
+	tempPath := filePath + ".tmp"
+	if err := os.WriteFile(tempPath, audioData, 0644); err != nil {
+		return "", fmt.Errorf("error writing audio file to local storage: %v", err)
+	}
+	if err := os.Rename(tempPath, filePath); err != nil {
+		os.Remove(tempPath)
+		return "", fmt.Errorf("error writing audio file to local storage: %v", err)
+	}
+	return filePath, nil
+}
+
+// LookupAudio 查找任意扩展名的同名文件
+func (l *LocalStorageService) LookupAudio(name string) (string, bool, error) {
+	matches, err := filepath.Glob(filepath.Join(l.storagePath, name) + ".*")
+	if err != nil {
+		return "", false, err
+	}
+	for _, match := range matches {
+		if filepath.Ext(match) != ".tmp" {
+			return match, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// LoadAudio 读取任意扩展名的同名文件
+func (l *LocalStorageService) LoadAudio(name string) ([]byte, bool, error) {
+	filePath, found, err := l.LookupAudio(name)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading audio file from local storage: %v", err)
+	}
+	return data, true, nil
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/audio"
+)
+
+func TestLocalStorageExtension(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+		ext  string
+	}{
+		{"WAV", audio.EncodeWAV(audio.Format{SampleRate: 16000, Channels: 1, BitsPerSample: 16}, nil), ".wav"},
+		{"MP3", []byte("ID3\x04"), ".mp3"},
+		{"Ogg Opus", []byte("OggS\x00"), ".ogg"},
+		{"未知格式", []byte("data"), ".bin"},
+	}
+	l := &LocalStorageService{storagePath: t.TempDir()}
+	for _, tc := range cases {
+		// 文件扩展名与音频的实际格式一致
+		path, err := l.StoreAudio(tc.data)
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.ext, filepath.Ext(path), tc.name)
+
+		path, err = l.StoreAudioAs("cache/"+tc.name, tc.data)
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.ext, filepath.Ext(path), tc.name)
+
+		// 按名称查找时不需要知道扩展名
+		data, found, err := l.LoadAudio("cache/" + tc.name)
+		assert.NoError(t, err, tc.name)
+		assert.True(t, found, tc.name)
+		assert.Equal(t, tc.data, data, tc.name)
+	}
+}
@@ -5,16 +5,21 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/telepace/voiceflow/internal/audio"
 	"github.com/telepace/voiceflow/pkg/config"
 	"github.com/telepace/voiceflow/pkg/logger"
 )
 
+// PresignExpiry MinIO 预签名 URL 的有效期
+const PresignExpiry = 24 * time.Hour
+
 type MinIOService struct {
 	client      *minio.Client
 	bucketName  string
@@ -48,23 +53,93 @@ func NewMinIOService() *MinIOService {
 func (m *MinIOService) StoreAudio(audioData []byte) (string, error) {
 	ctx := context.Background()
 
-	// 生成唯一文件名，并添加存储路径前缀
-	objectName := fmt.Sprintf("%s%s.wav", m.storagePath, uuid.New().String())
+	// 生成唯一文件名，并添加存储路径前缀，扩展名和 Content-Type 与实际的音频格式一致
+	container := audio.DetectContainer(audioData)
+	objectName := fmt.Sprintf("%s%s%s", m.storagePath, uuid.New().String(), container.Extension)
 
 	// 上传音频数据
 	_, err := m.client.PutObject(ctx, m.bucketName, objectName, bytes.NewReader(audioData), int64(len(audioData)), minio.PutObjectOptions{
-		ContentType: "audio/wav",
+		ContentType: container.MIMEType,
 	})
 	if err != nil {
 		return "", fmt.Errorf("上传到 MinIO 失败: %v", err)
 	}
 
-	// 生成预签名 URL
-	presignedURL, err := m.client.PresignedGetObject(ctx, m.bucketName, objectName, time.Hour*24, nil)
+	return m.presign(ctx, objectName)
+}
+
+// StoreAudioAs 以 storagePath + name + 扩展名作为对象名存储音频
+func (m *MinIOService) StoreAudioAs(name string, audioData []byte) (string, error) {
+	ctx := context.Background()
+
+	container := audio.DetectContainer(audioData)
+	objectName := m.storagePath + name + container.Extension
+	_, err := m.client.PutObject(ctx, m.bucketName, objectName, bytes.NewReader(audioData), int64(len(audioData)), minio.PutObjectOptions{
+		ContentType: container.MIMEType,
+	})
 	if err != nil {
-		return "", fmt.Errorf("生成预签名 URL 失败: %v", err)
+		return "", fmt.Errorf("上传到 MinIO 失败: %v", err)
+	}
+
+	return m.presign(ctx, objectName)
+}
+
+// LookupAudio 按前缀查找任意扩展名的同名对象
+func (m *MinIOService) LookupAudio(name string) (string, bool, error) {
+	// 找到第一个对象后取消列举，避免 ListObjects 的后台协程泄漏
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	objects := m.client.ListObjects(ctx, m.bucketName, minio.ListObjectsOptions{
+		Prefix:  m.storagePath + name + ".",
+		MaxKeys: 1,
+	})
+	for object := range objects {
+		if object.Err != nil {
+			return "", false, fmt.Errorf("查询 MinIO 对象失败: %v", object.Err)
+		}
+		url, err := m.presign(ctx, object.Key)
+		if err != nil {
+			return "", false, err
+		}
+		return url, true, nil
+	}
+	return "", false, nil
+}
+
+// LoadAudio 按前缀查找任意扩展名的同名对象并读取内容
+func (m *MinIOService) LoadAudio(name string) ([]byte, bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	objects := m.client.ListObjects(ctx, m.bucketName, minio.ListObjectsOptions{
+		Prefix:  m.storagePath + name + ".",
+		MaxKeys: 1,
+	})
+	for object := range objects {
+		if object.Err != nil {
+			return nil, false, fmt.Errorf("查询 MinIO 对象失败: %v", object.Err)
+		}
+		reader, err := m.client.GetObject(ctx, m.bucketName, object.Key, minio.GetObjectOptions{})
+		if err != nil {
+			return nil, false, fmt.Errorf("读取 MinIO 对象失败: %v", err)
+		}
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, false, fmt.Errorf("读取 MinIO 对象失败: %v", err)
+		}
+		return data, true, nil
 	}
+	return nil, false, nil
+}
 
+// presign 生成有效期 24 小时的预签名 URL
+func (m *MinIOService) presign(ctx context.Context, objectName string) (string, error) {
+	presignedURL, err := m.client.PresignedGetObject(ctx, m.bucketName, objectName, PresignExpiry, nil)
+	if err != nil {
+		return "", fmt.Errorf("生成预签名 URL 失败: %v", err)
+	}
 	return presignedURL.String(), nil
 }
 
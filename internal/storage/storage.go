@@ -7,6 +7,12 @@ import (
 
 type Service interface {
 	StoreAudio(audioData []byte) (string, error) // 存储音频并返回 URL 或路径
+	// StoreAudioAs 以指定的名称存储音频，扩展名按音频格式自动添加，同名对象会被覆盖
+	StoreAudioAs(name string, audioData []byte) (string, error)
+	// LookupAudio 查找通过 StoreAudioAs 存储的音频，不存在时 found 为 false
+	LookupAudio(name string) (url string, found bool, err error)
+	// LoadAudio 读取通过 StoreAudioAs 存储的音频，不存在时 found 为 false
+	LoadAudio(name string) (audioData []byte, found bool, err error)
 }
 
 // NewService 根据配置返回相应的存储服务
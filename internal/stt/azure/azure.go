@@ -4,17 +4,57 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/internal/models"
 	"github.com/telepace/voiceflow/pkg/config"
 	"github.com/telepace/voiceflow/pkg/logger"
 )
 
+const (
+	// ticksPerSecond Azure 返回的时间偏移以 100 纳秒为单位
+	ticksPerSecond = 10_000_000
+	// streamBufferSize 流式识别时缓冲的音频包数量
+	streamBufferSize = 256
+	// maxStreamDuration 短音频 REST 接口最多识别 60 秒，流式上传超过该时长后改为录音结束后分段识别
+	maxStreamDuration = 55 * time.Second
+)
+
+// streamFormat 为流式上传的音频格式，与请求的 Content-Type 一致
+var streamFormat = audio.Format{SampleRate: 16000, Channels: 1, BitsPerSample: 16}
+
 type STT struct {
-	apiKey   string
-	region   string
-	endpoint string
+	apiKey    string
+	region    string
+	endpoint  string
+	language  string
+	profanity string
+}
+
+// detailedResponse 对应 format=detailed 的识别结果
+type detailedResponse struct {
+	RecognitionStatus string `json:"RecognitionStatus"`
+	DisplayText       string `json:"DisplayText"`
+	Offset            int64  `json:"Offset"`
+	Duration          int64  `json:"Duration"`
+	NBest             []struct {
+		Confidence float64 `json:"Confidence"`
+		Lexical    string  `json:"Lexical"`
+		ITN        string  `json:"ITN"`
+		MaskedITN  string  `json:"MaskedITN"`
+		Display    string  `json:"Display"`
+		Words      []struct {
+			Word     string `json:"Word"`
+			Offset   int64  `json:"Offset"`
+			Duration int64  `json:"Duration"`
+		} `json:"Words"`
+	} `json:"NBest"`
 }
 
 // NewAzureSTT 创建并返回一个新的 AzureSTT 实例
@@ -23,55 +63,223 @@ func NewAzureSTT() *STT {
 	if err != nil {
 		logger.Fatalf("配置初始化失败: %v", err)
 	}
-	return &STT{
-		apiKey:   cfg.Azure.STTKey,
-		region:   cfg.Azure.Region,
-		endpoint: fmt.Sprintf("https://%s.stt.speech.microsoft.com/speech/recognition/conversation/cognitiveservices/v1", cfg.Azure.Region),
+	s := &STT{
+		apiKey:    cfg.Azure.STTKey,
+		region:    cfg.Azure.Region,
+		endpoint:  fmt.Sprintf("https://%s.stt.speech.microsoft.com/speech/recognition/conversation/cognitiveservices/v1", cfg.Azure.Region),
+		language:  cfg.Azure.STT.Language,
+		profanity: cfg.Azure.STT.Profanity,
+	}
+	if s.language == "" {
+		s.language = "en-US"
+	}
+	switch s.profanity {
+	case "":
+		s.profanity = "masked"
+	case "masked", "removed", "raw":
+	default:
+		logger.Fatalf("不支持的 Azure 脏话过滤模式: %s，可选值为 masked、removed、raw", s.profanity)
 	}
+	return s
 }
 
 // Recognize 调用 Azure 的 STT API 将音频数据转换为文本
 // 新增 audioURL 参数，但 Azure 不使用该参数
 func (a *STT) Recognize(audioData []byte, audioURL string) (string, error) {
+	result, err := a.RecognizeDetailed(audioData, audioURL, models.RecognitionOptions{})
+	if err != nil {
+		return "", err
+	}
+	return result.Text, nil
+}
+
+// RecognizeDetailed 返回包含 NBest 候选、置信度和词级时间戳的识别结果
+func (a *STT) RecognizeDetailed(audioData []byte, audioURL string, opts models.RecognitionOptions) (*models.Transcript, error) {
 	if audioURL != "" {
 		logger.Infof("Azure STT 不支持使用 audioURL，忽略该参数")
 	}
 
-	req, err := http.NewRequest("POST", a.endpoint, bytes.NewReader(audioData))
+	req, err := a.newRequest(bytes.NewReader(audioData), opts)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return a.do(req)
+}
+
+// StartStream 使用分块传输编码建立识别请求，音频在用户说话的同时上传给 Azure
+func (a *STT) StartStream(opts models.RecognitionOptions) (models.RecognitionStream, error) {
+	pr, pw := io.Pipe()
+	req, err := a.newRequest(pr, opts)
+	if err != nil {
+		return nil, err
+	}
+	// 长度未知时 net/http 会使用 Transfer-Encoding: chunked
+	req.ContentLength = -1
+
+	s := &stream{
+		writer:   pw,
+		chunks:   make(chan []byte, streamBufferSize),
+		done:     make(chan struct{}),
+		maxBytes: streamFormat.Bytes(maxStreamDuration),
+	}
+	go s.pump()
+	go func() {
+		defer close(s.done)
+		s.result, s.err = a.do(req)
+		// 请求提前结束时让后续写入立即返回错误
+		pr.CloseWithError(fmt.Errorf("azure 识别请求已结束"))
+	}()
+	return s, nil
+}
+
+func (a *STT) newRequest(body io.Reader, opts models.RecognitionOptions) (*http.Request, error) {
+	language := a.language
+	if opts.Language != "" {
+		language = opts.Language
+	}
+
+	query := url.Values{}
+	query.Set("language", language)
+	query.Set("format", "detailed")
+	query.Set("profanity", a.profanity)
+	query.Set("wordLevelTimestamps", "true")
+
+	req, err := http.NewRequest("POST", a.endpoint+"?"+query.Encode(), body)
+	if err != nil {
+		return nil, err
 	}
 	req.Header.Set("Ocp-Apim-Subscription-Key", a.apiKey)
-	req.Header.Set("Content-Type", "audio/wav; codec=\"audio/pcm\"; samplerate=16000")
+	req.Header.Set("Content-Type", "audio/wav; codecs=audio/pcm; samplerate=16000")
+	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
 
+func (a *STT) do(req *http.Request) (*models.Transcript, error) {
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
 	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return "", fmt.Errorf("Azure STT 错误: %s", string(body))
+		return nil, fmt.Errorf("Azure STT 错误: %s", string(body))
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	var result detailedResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("无法解析 Azure STT 的响应: %v", err)
 	}
+	return result.toTranscript()
+}
 
-	// 假设返回的结果中有识别文本（JSON 格式，需解析）
-	var result map[string]interface{}
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		return "", err
+func (r *detailedResponse) toTranscript() (*models.Transcript, error) {
+	switch r.RecognitionStatus {
+	case "Success":
+	case "NoMatch", "InitialSilenceTimeout", "BabbleTimeout":
+		// 音频中没有可识别的语音
+		return &models.Transcript{}, nil
+	default:
+		return nil, fmt.Errorf("Azure STT 识别失败: %s", r.RecognitionStatus)
 	}
 
-	text, ok := result["DisplayText"].(string)
-	if !ok {
-		return "", fmt.Errorf("无法解析 Azure STT 的响应")
+	transcript := &models.Transcript{
+		Text:     r.DisplayText,
+		Duration: float64(r.Offset+r.Duration) / ticksPerSecond,
 	}
+	for _, nbest := range r.NBest {
+		alt := models.TranscriptAlternative{
+			Text:       nbest.Display,
+			Confidence: nbest.Confidence,
+		}
+		for _, w := range nbest.Words {
+			alt.Words = append(alt.Words, models.WordInfo{
+				Word:  w.Word,
+				Start: float64(w.Offset) / ticksPerSecond,
+				End:   float64(w.Offset+w.Duration) / ticksPerSecond,
+			})
+		}
+		transcript.Alternatives = append(transcript.Alternatives, alt)
+	}
+	if transcript.Text == "" && len(transcript.Alternatives) > 0 {
+		transcript.Text = transcript.Alternatives[0].Text
+	}
+	transcript.Text = strings.TrimSpace(transcript.Text)
+	return transcript, nil
+}
+
+// stream 实现 models.RecognitionStream
+// 音频先写入缓冲通道再由后台协程送入请求体，避免网络延迟阻塞调用方
+type stream struct {
+	writer   *io.PipeWriter
+	chunks   chan []byte
+	done     chan struct{}
+	result   *models.Transcript
+	err      error
+	maxBytes int
 
-	return text, nil
+	mu      sync.Mutex
+	closed  bool
+	written int
+}
+
+func (s *stream) pump() {
+	for chunk := range s.chunks {
+		if _, err := s.writer.Write(chunk); err != nil {
+			// 请求已失败，丢弃剩余音频
+			for range s.chunks {
+			}
+			return
+		}
+	}
+	s.writer.Close()
+}
+
+func (s *stream) Write(chunk []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("识别流已关闭")
+	}
+	select {
+	case <-s.done:
+		return fmt.Errorf("azure 识别请求已结束: %v", s.err)
+	default:
+	}
+	if s.written+len(chunk) > s.maxBytes {
+		return fmt.Errorf("音频超过 Azure 流式识别 %s 的限制", maxStreamDuration)
+	}
+	// 缓冲已满说明上传跟不上录音，不阻塞调用方，由调用方改为录音结束后识别
+	select {
+	case s.chunks <- append([]byte(nil), chunk...):
+		s.written += len(chunk)
+		return nil
+	default:
+		return fmt.Errorf("azure 流式识别上传缓冲已满")
+	}
+}
+
+func (s *stream) Finish() (*models.Transcript, error) {
+	s.close()
+	<-s.done
+	return s.result, s.err
+}
+
+func (s *stream) Abort() {
+	s.writer.CloseWithError(fmt.Errorf("识别已取消"))
+	s.close()
+	<-s.done
+}
+
+func (s *stream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.chunks)
+	}
 }
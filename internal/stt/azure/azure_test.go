@@ -0,0 +1,169 @@
+package azure
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+func newTestSTT(endpoint string) *STT {
+	return &STT{
+		apiKey:    "key",
+		endpoint:  endpoint,
+		language:  "en-US",
+		profanity: "masked",
+	}
+}
+
+// detailedResult 为 format=detailed 的成功响应，包含两个候选结果
+const detailedResult = `{
+	"RecognitionStatus": "Success",
+	"DisplayText": " Hello world. ",
+	"Offset": 5000000,
+	"Duration": 15000000,
+	"NBest": [
+		{"Confidence": 0.92, "Lexical": "hello world", "Display": "Hello world.",
+			"Words": [{"Word": "hello", "Offset": 5000000, "Duration": 4000000},
+				{"Word": "world", "Offset": 10000000, "Duration": 10000000}]},
+		{"Confidence": 0.41, "Lexical": "hello word", "Display": "Hello word."}
+	]
+}`
+
+func TestRecognizeDetailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		// 会话指定的语言优先于配置
+		assert.Equal(t, "zh-CN", query.Get("language"))
+		assert.Equal(t, "detailed", query.Get("format"))
+		assert.Equal(t, "raw", query.Get("profanity"))
+		assert.Equal(t, "true", query.Get("wordLevelTimestamps"))
+		assert.Equal(t, "key", r.Header.Get("Ocp-Apim-Subscription-Key"))
+		assert.Equal(t, "audio/wav; codecs=audio/pcm; samplerate=16000", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "audio", string(body))
+		fmt.Fprint(w, detailedResult)
+	}))
+	defer server.Close()
+
+	a := newTestSTT(server.URL)
+	a.profanity = "raw"
+	transcript, err := a.RecognizeDetailed([]byte("audio"), "", models.RecognitionOptions{Language: "zh-CN"})
+	assert.NoError(t, err)
+	assert.Equal(t, &models.Transcript{
+		Text:     "Hello world.",
+		Duration: 2,
+		Alternatives: []models.TranscriptAlternative{
+			{Text: "Hello world.", Confidence: 0.92, Words: []models.WordInfo{
+				{Word: "hello", Start: 0.5, End: 0.9},
+				{Word: "world", Start: 1, End: 2},
+			}},
+			{Text: "Hello word.", Confidence: 0.41},
+		},
+	}, transcript)
+}
+
+func TestRecognizeStatus(t *testing.T) {
+	cases := []struct {
+		name     string
+		response string
+		want     string
+		wantErr  bool
+	}{
+		{"没有 DisplayText 时使用首个候选", `{"RecognitionStatus":"Success","NBest":[{"Display":"你好"}]}`, "你好", false},
+		{"静音", `{"RecognitionStatus":"NoMatch"}`, "", false},
+		{"开头静音超时", `{"RecognitionStatus":"InitialSilenceTimeout"}`, "", false},
+		{"识别失败", `{"RecognitionStatus":"Error"}`, "", true},
+		{"响应不是 JSON", `not json`, "", true},
+	}
+	for _, tc := range cases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, tc.response)
+		}))
+		text, err := newTestSTT(server.URL).Recognize([]byte("audio"), "")
+		server.Close()
+		if tc.wantErr {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.want, text, tc.name)
+	}
+}
+
+func TestRecognizeHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid subscription key", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	_, err := newTestSTT(server.URL).Recognize([]byte("audio"), "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid subscription key")
+}
+
+func TestStartStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 流式上传使用分块传输编码，请求体为全部写入的音频
+		assert.Equal(t, []string{"chunked"}, r.TransferEncoding)
+		assert.Equal(t, "en-US", r.URL.Query().Get("language"))
+		assert.Equal(t, "key", r.Header.Get("Ocp-Apim-Subscription-Key"))
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "chunk1chunk2", string(body))
+		fmt.Fprint(w, detailedResult)
+	}))
+	defer server.Close()
+
+	s, err := newTestSTT(server.URL).StartStream(models.RecognitionOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Write([]byte("chunk1")))
+	assert.NoError(t, s.Write([]byte("chunk2")))
+	transcript, err := s.Finish()
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello world.", transcript.Text)
+	assert.Error(t, s.Write([]byte("chunk3")))
+}
+
+func TestStartStreamRequestFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	s, err := newTestSTT(server.URL).StartStream(models.RecognitionOptions{})
+	assert.NoError(t, err)
+	_, err = s.Finish()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "bad request")
+}
+
+func newTestStream(buffer, maxBytes int) *stream {
+	return &stream{
+		chunks:   make(chan []byte, buffer),
+		done:     make(chan struct{}),
+		maxBytes: maxBytes,
+	}
+}
+
+func TestStreamWriteLimit(t *testing.T) {
+	s := newTestStream(4, 100)
+	assert.NoError(t, s.Write(make([]byte, 60)))
+	assert.NoError(t, s.Write(make([]byte, 40)))
+	// 超过时长上限后拒绝写入，由调用方改为分段识别
+	assert.Error(t, s.Write(make([]byte, 1)))
+}
+
+func TestStreamWriteDoesNotBlock(t *testing.T) {
+	s := newTestStream(1, 100)
+	assert.NoError(t, s.Write(make([]byte, 10)))
+	// 上传协程没有消费缓冲时立即返回错误
+	assert.Error(t, s.Write(make([]byte, 10)))
+}
+
+func TestMaxStreamBytes(t *testing.T) {
+	assert.Equal(t, 55*32000, streamFormat.Bytes(maxStreamDuration))
+}
@@ -0,0 +1,131 @@
+// chunked.go
+package stt
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/stt/chunker"
+	"github.com/telepace/voiceflow/pkg/config"
+	"github.com/telepace/voiceflow/pkg/logger"
+)
+
+// DetailedService 是可选接口，实现该接口的提供商可以返回带时间戳分段的识别结果，
+// 并支持按会话指定识别语言等参数
+type DetailedService interface {
+	RecognizeDetailed(audioData []byte, audioURL string, opts models.RecognitionOptions) (*models.Transcript, error)
+}
+
+// StreamingService 是可选接口，实现该接口的提供商可以在接收音频的同时上传识别
+type StreamingService interface {
+	StartStream(opts models.RecognitionOptions) (models.RecognitionStream, error)
+}
+
+// ErrStreamingUnsupported 表示当前提供商不支持流式识别
+var ErrStreamingUnsupported = errors.New("当前 STT 提供商不支持流式识别")
+
+// providerLimits 记录各批量识别接口对单次请求的音频限制
+var providerLimits = map[string]chunker.Options{
+	// 同步 speech:recognize 最多约 1 分钟
+	"google": {MaxDuration: 55 * time.Second},
+	// 短音频 REST 接口最多 60 秒
+	"azure": {MaxDuration: 55 * time.Second},
+	// 上传文件不超过 25MB
+	"whisper-v3":        {MaxBytes: 25 << 20},
+	"openai-compatible": {MaxBytes: 25 << 20},
+}
+
+// googleLongRunningLimit 长音频识别对时长没有限制，但内联上传的音频经 base64 编码后不能超过 10MB
+var googleLongRunningLimit = chunker.Options{MaxBytes: 7 << 20}
+
+// chunkedService 在音频超过提供商限制时自动切分、并发识别并拼接结果
+type chunkedService struct {
+	inner Service
+	opts  chunker.Options
+}
+
+// withChunking 根据提供商限制和配置为 STT 服务包装长音频分段能力
+func withChunking(provider string, svc Service, cfg *config.Config) Service {
+	limits, ok := providerLimits[provider]
+	if !ok || !cfg.STT.Chunking.Enabled {
+		return svc
+	}
+	// Google 开启长音频识别后只需按内联上传的大小切分
+	if provider == "google" && cfg.Google.STT.LongRunning {
+		limits = googleLongRunningLimit
+	}
+
+	opts := limits
+	opts.Concurrency = cfg.STT.Chunking.Concurrency
+	if cfg.STT.Chunking.TargetSeconds > 0 {
+		opts.TargetDuration = seconds(cfg.STT.Chunking.TargetSeconds)
+	}
+	if cfg.STT.Chunking.OverlapSeconds > 0 {
+		opts.Overlap = seconds(cfg.STT.Chunking.OverlapSeconds)
+	}
+	// Whisper 的 batch_size 指定了单段音频的最大时长
+	if provider == "whisper-v3" && cfg.Whisper.BatchSize > 0 {
+		opts.MaxDuration = time.Duration(cfg.Whisper.BatchSize) * time.Second
+	}
+
+	logger.Debugf("STT 提供商 %s 启用长音频分段: %+v", provider, opts)
+	return &chunkedService{inner: svc, opts: opts}
+}
+
+// Recognize 实现 Service 接口
+func (c *chunkedService) Recognize(audioData []byte, audioURL string) (string, error) {
+	if !chunker.NeedsSplit(audioData, c.opts) {
+		return c.inner.Recognize(audioData, audioURL)
+	}
+	transcript, err := c.RecognizeDetailed(audioData, audioURL, models.RecognitionOptions{})
+	if err != nil {
+		return "", err
+	}
+	return transcript.Text, nil
+}
+
+// RecognizeDetailed 实现 DetailedService 接口
+func (c *chunkedService) RecognizeDetailed(audioData []byte, audioURL string, opts models.RecognitionOptions) (*models.Transcript, error) {
+	if !chunker.NeedsSplit(audioData, c.opts) {
+		return RecognizeDetailed(c.inner, audioData, audioURL, opts)
+	}
+
+	logger.Infof("音频超过提供商限制，切分后并发识别")
+	// 分段后的音频与原始 URL 不再对应，只能上传分段数据
+	return chunker.Transcribe(context.Background(), audioData, c.opts,
+		func(ctx context.Context, chunk chunker.Chunk) (*models.Transcript, error) {
+			logger.Debugf("识别第 %d 段音频，起始 %s，时长 %s", chunk.Index+1, chunk.Offset, chunk.Duration)
+			return RecognizeDetailed(c.inner, chunk.Data, "", opts)
+		})
+}
+
+// StartStream 实现 StreamingService 接口，流式识别直接交给被包装的提供商
+func (c *chunkedService) StartStream(opts models.RecognitionOptions) (models.RecognitionStream, error) {
+	return StartStream(c.inner, opts)
+}
+
+// RecognizeDetailed 优先使用提供商的详细识别接口，否则退化为纯文本结果
+func RecognizeDetailed(svc Service, audioData []byte, audioURL string, opts models.RecognitionOptions) (*models.Transcript, error) {
+	if detailed, ok := svc.(DetailedService); ok {
+		return detailed.RecognizeDetailed(audioData, audioURL, opts)
+	}
+	text, err := svc.Recognize(audioData, audioURL)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Transcript{Text: text}, nil
+}
+
+// StartStream 为支持流式识别的提供商开启识别流，否则返回 ErrStreamingUnsupported
+func StartStream(svc Service, opts models.RecognitionOptions) (models.RecognitionStream, error) {
+	if streaming, ok := svc.(StreamingService); ok {
+		return streaming.StartStream(opts)
+	}
+	return nil, ErrStreamingUnsupported
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,68 @@
+package stt
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/pkg/config"
+)
+
+// recordingService 记录每次识别收到的音频大小
+type recordingService struct {
+	mu    sync.Mutex
+	sizes []int
+}
+
+func (r *recordingService) Recognize(audioData []byte, audioURL string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sizes = append(r.sizes, len(audioData))
+	return "", nil
+}
+
+func googleConfig(longRunning bool) *config.Config {
+	cfg := &config.Config{}
+	cfg.STT.Chunking.Enabled = true
+	cfg.Google.STT.LongRunning = longRunning
+	return cfg
+}
+
+func TestWithChunkingGoogle(t *testing.T) {
+	format := audio.Format{SampleRate: 16000, Channels: 1, BitsPerSample: 16}
+	// 4 分钟的 WAV 约 7.3MB，超过同步识别的时长，也超过内联上传的大小
+	wav := audio.EncodeWAV(format, make([]byte, format.Bytes(4*time.Minute)))
+
+	cases := []struct {
+		name        string
+		longRunning bool
+		maxSize     int
+		chunks      int
+	}{
+		{"同步识别按 55 秒切分", false, format.Bytes(55*time.Second) + 44, 5},
+		{"长音频识别按内联上传大小切分", true, 7 << 20, 2},
+	}
+	for _, tc := range cases {
+		inner := &recordingService{}
+		svc := withChunking("google", inner, googleConfig(tc.longRunning))
+		_, err := RecognizeDetailed(svc, wav, "", models.RecognitionOptions{})
+		assert.NoError(t, err, tc.name)
+		assert.Len(t, inner.sizes, tc.chunks, tc.name)
+		for _, size := range inner.sizes {
+			assert.LessOrEqual(t, size, tc.maxSize, tc.name)
+		}
+	}
+}
+
+func TestWithChunkingGoogleShortAudio(t *testing.T) {
+	format := audio.Format{SampleRate: 16000, Channels: 1, BitsPerSample: 16}
+	// 开启长音频识别后，未超过内联上传大小的音频整段交给提供商
+	wav := audio.EncodeWAV(format, make([]byte, format.Bytes(2*time.Minute)))
+	inner := &recordingService{}
+	_, err := withChunking("google", inner, googleConfig(true)).Recognize(wav, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{len(wav)}, inner.sizes)
+}
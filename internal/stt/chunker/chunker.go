@@ -0,0 +1,215 @@
+// internal/stt/chunker/chunker.go
+package chunker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+const (
+	defaultOverlap      = 1 * time.Second
+	defaultSearchWindow = 3 * time.Second
+	defaultConcurrency  = 4
+	// wavHeaderSize 分段重新封装为 WAV 时的文件头长度
+	wavHeaderSize = 44
+)
+
+// Options 控制长音频的分段方式
+type Options struct {
+	TargetDuration time.Duration // 期望的分段时长，为 0 时根据上限自动计算
+	MaxDuration    time.Duration // 单段时长上限，为 0 表示不限制
+	MaxBytes       int           // 单段文件大小上限（含 WAV 头），为 0 表示不限制
+	Overlap        time.Duration // 相邻分段之间的重叠时长，为 0 时使用默认值，小于 0 表示不重叠
+	SearchWindow   time.Duration // 在目标切分点前后搜索静音的范围
+	Concurrency    int           // 并发识别的分段数量上限
+}
+
+// Chunk 表示切分后的一段音频
+type Chunk struct {
+	Index    int
+	Offset   time.Duration // 分段在原始音频中的起始位置
+	Duration time.Duration
+	Overlap  time.Duration // 与上一分段重叠的时长
+	Data     []byte        // WAV 格式的分段音频
+}
+
+// TranscribeFunc 识别单个分段
+type TranscribeFunc func(ctx context.Context, chunk Chunk) (*models.Transcript, error)
+
+// NeedsSplit 判断 WAV 音频是否超过了分段上限
+func NeedsSplit(audioData []byte, opts Options) bool {
+	if !audio.IsWAV(audioData) {
+		return false
+	}
+	format, pcm, err := audio.ParseWAV(audioData)
+	if err != nil {
+		return false
+	}
+	maxBytes := maxChunkBytes(format, opts)
+	return maxBytes > 0 && len(pcm) > maxBytes
+}
+
+// Split 在静音处将 WAV 音频切分为不超过上限的分段，相邻分段保留少量重叠
+func Split(audioData []byte, opts Options) ([]Chunk, error) {
+	format, pcm, err := audio.ParseWAV(audioData)
+	if err != nil {
+		return nil, err
+	}
+
+	maxBytes := maxChunkBytes(format, opts)
+	if maxBytes <= 0 || len(pcm) <= maxBytes {
+		return []Chunk{{
+			Duration: format.Duration(len(pcm)),
+			Data:     audio.EncodeWAV(format, pcm),
+		}}, nil
+	}
+
+	overlap := format.Bytes(opts.overlap())
+	window := format.Bytes(opts.searchWindow())
+	// 搜索窗口和重叠不能占满整段，否则无法保证切分前进
+	if window > maxBytes/4 {
+		window = format.Bytes(format.Duration(maxBytes / 4))
+	}
+	if overlap > maxBytes/4 {
+		overlap = format.Bytes(format.Duration(maxBytes / 4))
+	}
+
+	target := maxBytes - window
+	if opts.TargetDuration > 0 {
+		if t := format.Bytes(opts.TargetDuration); t < target {
+			target = t
+		}
+	}
+
+	var chunks []Chunk
+	start := 0
+	for {
+		leading := 0
+		if len(chunks) > 0 {
+			leading = overlap
+		}
+
+		end := len(pcm)
+		if end-start > maxBytes {
+			ideal := start + target
+			hi := ideal + window
+			if hi > start+maxBytes {
+				hi = start + maxBytes
+			}
+			end = audio.FindQuietestPoint(format, pcm, ideal-window, hi)
+			if end <= start+overlap {
+				end = start + maxBytes
+			}
+		}
+
+		chunks = append(chunks, Chunk{
+			Index:    len(chunks),
+			Offset:   format.Duration(start),
+			Duration: format.Duration(end - start),
+			Overlap:  format.Duration(leading),
+			Data:     audio.EncodeWAV(format, pcm[start:end]),
+		})
+
+		if end >= len(pcm) {
+			break
+		}
+		start = end - overlap
+	}
+
+	return chunks, nil
+}
+
+// Transcribe 切分音频并以受限的并发度识别各分段，最后拼接为完整结果
+func Transcribe(ctx context.Context, audioData []byte, opts Options, fn TranscribeFunc) (*models.Transcript, error) {
+	chunks, err := Split(audioData, opts)
+	if err != nil {
+		return nil, fmt.Errorf("音频分段失败: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*models.Transcript, len(chunks))
+	sem := make(chan struct{}, opts.concurrency())
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+
+	for i := range chunks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+
+			result, err := fn(ctx, chunks[i])
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = fmt.Errorf("第 %d 段识别失败: %v", i+1, err)
+					cancel()
+				})
+				return
+			}
+			results[i] = result
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return Stitch(chunks, results), nil
+}
+
+func maxChunkBytes(format audio.Format, opts Options) int {
+	limit := 0
+	if opts.MaxDuration > 0 {
+		limit = format.Bytes(opts.MaxDuration)
+	}
+	if opts.MaxBytes > 0 {
+		byBytes := format.Bytes(format.Duration(opts.MaxBytes - wavHeaderSize))
+		if limit == 0 || byBytes < limit {
+			limit = byBytes
+		}
+	}
+	return limit
+}
+
+func (o Options) overlap() time.Duration {
+	if o.Overlap < 0 {
+		return 0
+	}
+	if o.Overlap == 0 {
+		return defaultOverlap
+	}
+	return o.Overlap
+}
+
+func (o Options) searchWindow() time.Duration {
+	if o.SearchWindow <= 0 {
+		return defaultSearchWindow
+	}
+	return o.SearchWindow
+}
+
+func (o Options) concurrency() int {
+	if o.Concurrency <= 0 {
+		return defaultConcurrency
+	}
+	return o.Concurrency
+}
@@ -0,0 +1,123 @@
+package chunker
+
+import (
+	"context"
+	"encoding/binary"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+var testFormat = audio.Format{SampleRate: 16000, Channels: 1, BitsPerSample: 16}
+
+// buildSpeech 生成总时长为 total 的测试音频，每隔 every 插入一段 300ms 的静音
+func buildSpeech(total, every time.Duration) []byte {
+	pcm := make([]byte, testFormat.Bytes(total))
+	gap := testFormat.Bytes(300 * time.Millisecond)
+	period := testFormat.Bytes(every)
+	for i := 0; i+1 < len(pcm); i += 2 {
+		pos := i % period
+		if pos >= period-gap {
+			continue
+		}
+		v := int16(8000)
+		if (i/2)%20 < 10 {
+			v = -8000
+		}
+		binary.LittleEndian.PutUint16(pcm[i:], uint16(v))
+	}
+	return audio.EncodeWAV(testFormat, pcm)
+}
+
+func TestSplit(t *testing.T) {
+	data := buildSpeech(130*time.Second, 10*time.Second)
+	opts := Options{MaxDuration: 55 * time.Second, Overlap: time.Second}
+
+	assert.True(t, NeedsSplit(data, opts))
+	assert.False(t, NeedsSplit(buildSpeech(30*time.Second, 10*time.Second), opts))
+
+	chunks, err := Split(data, opts)
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 3)
+
+	var covered time.Duration
+	for i, chunk := range chunks {
+		assert.LessOrEqual(t, chunk.Duration, opts.MaxDuration)
+		if i > 0 {
+			assert.Equal(t, time.Second, chunk.Overlap)
+			assert.Equal(t, covered-chunk.Overlap, chunk.Offset)
+
+			// 切分点应当落在静音区间内
+			cut := chunk.Offset + chunk.Overlap
+			inPeriod := cut % (10 * time.Second)
+			assert.GreaterOrEqual(t, inPeriod, 9700*time.Millisecond, "cut at %s", cut)
+		}
+		covered = chunk.Offset + chunk.Duration
+	}
+	assert.Equal(t, 130*time.Second, covered)
+}
+
+func TestTranscribe(t *testing.T) {
+	data := buildSpeech(130*time.Second, 10*time.Second)
+	opts := Options{MaxDuration: 55 * time.Second, Concurrency: 2}
+
+	var running, peak int32
+	result, err := Transcribe(context.Background(), data, opts, func(ctx context.Context, chunk Chunk) (*models.Transcript, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return &models.Transcript{
+			Language: "en",
+			Segments: []models.TranscriptSegment{{Start: 0, End: 0.4, Text: "chunk"}},
+		}, nil
+	})
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, peak, int32(2))
+	assert.Equal(t, "en", result.Language)
+	assert.InDelta(t, 130, result.Duration, 0.01)
+	// 第二、三段开头的片段落在重叠区域内，属于上一段
+	assert.Len(t, result.Segments, 1)
+}
+
+func TestStitchSegments(t *testing.T) {
+	chunks := []Chunk{
+		{Index: 0, Offset: 0, Duration: 50 * time.Second},
+		{Index: 1, Offset: 48 * time.Second, Duration: 30 * time.Second, Overlap: 2 * time.Second},
+	}
+	results := []*models.Transcript{
+		{Segments: []models.TranscriptSegment{
+			{Start: 0, End: 20, Text: "hello there"},
+			{Start: 45, End: 49.5, Text: "how are you"},
+		}},
+		{Segments: []models.TranscriptSegment{
+			{Start: 0, End: 1.5, Text: "you"},
+			{Start: 2, End: 10, Text: "fine thanks"},
+		}},
+	}
+
+	merged := Stitch(chunks, results)
+	assert.Equal(t, "hello there how are you fine thanks", merged.Text)
+	assert.Len(t, merged.Segments, 3)
+	assert.Equal(t, 50.0, merged.Segments[2].Start)
+	assert.Equal(t, 58.0, merged.Segments[2].End)
+	assert.Equal(t, 78.0, merged.Duration)
+}
+
+func TestMergeText(t *testing.T) {
+	assert.Equal(t, "the quick brown fox jumps over the lazy dog",
+		mergeText("the quick brown fox jumps", "Fox jumps over the lazy dog"))
+	assert.Equal(t, "今天天气很好，我们去公园散步吧。",
+		mergeText("今天天气很好，我们去", "我们去公园散步吧。"))
+	assert.Equal(t, "one two three four",
+		mergeText("one two", "three four"))
+}
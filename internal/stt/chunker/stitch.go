@@ -0,0 +1,168 @@
+// internal/stt/chunker/stitch.go
+package chunker
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+// maxOverlapTokens 文本去重时向前比较的最大词数
+const maxOverlapTokens = 30
+
+// Stitch 将各分段的识别结果拼接为完整结果。
+// 带时间戳的分段会平移到原始音频时间轴，重叠区域以中点为界各取一半；
+// 只有纯文本的分段则通过比较首尾词语去掉重叠部分的重复文本。
+func Stitch(chunks []Chunk, results []*models.Transcript) *models.Transcript {
+	merged := &models.Transcript{}
+
+	for i, chunk := range chunks {
+		if end := (chunk.Offset + chunk.Duration).Seconds(); end > merged.Duration {
+			merged.Duration = end
+		}
+		if i >= len(results) || results[i] == nil {
+			continue
+		}
+		result := results[i]
+		if merged.Language == "" {
+			merged.Language = result.Language
+		}
+
+		if len(result.Segments) == 0 {
+			merged.Text = mergeText(merged.Text, result.Text)
+			continue
+		}
+
+		// 重叠区域的中点作为与上一/下一分段的分界
+		lower := (chunk.Offset + chunk.Overlap/2).Seconds()
+		upper := -1.0
+		if i+1 < len(chunks) {
+			next := chunks[i+1]
+			upper = (next.Offset + next.Overlap/2).Seconds()
+		}
+
+		offset := chunk.Offset.Seconds()
+		for _, seg := range result.Segments {
+			seg.Start += offset
+			seg.End += offset
+			mid := (seg.Start + seg.End) / 2
+			if i > 0 && mid < lower {
+				continue
+			}
+			if upper >= 0 && mid >= upper {
+				continue
+			}
+			merged.Segments = append(merged.Segments, seg)
+			merged.Text = joinText(merged.Text, strings.TrimSpace(seg.Text))
+		}
+	}
+
+	return merged
+}
+
+// mergeText 拼接两段文本，并去掉前一段结尾与后一段开头重复的词语
+func mergeText(prev, next string) string {
+	next = strings.TrimSpace(next)
+	if prev == "" || next == "" {
+		return joinText(prev, next)
+	}
+
+	prevTokens := tokenize(prev)
+	nextTokens := tokenize(next)
+
+	limit := maxOverlapTokens
+	if len(prevTokens) < limit {
+		limit = len(prevTokens)
+	}
+	if len(nextTokens) < limit {
+		limit = len(nextTokens)
+	}
+
+	// 单个词的重合很可能是巧合，至少需要两个词才认为是重叠
+	for k := limit; k >= 2; k-- {
+		if tokensEqual(prevTokens[len(prevTokens)-k:], nextTokens[:k]) {
+			rest := ""
+			if k < len(nextTokens) {
+				rest = strings.TrimSpace(next[nextTokens[k].start:])
+			}
+			return joinText(prev, rest)
+		}
+	}
+	return joinText(prev, next)
+}
+
+type token struct {
+	norm  string
+	start int
+}
+
+// tokenize 按空白切分文本，中日韩文字按单字切分，归一化时忽略大小写和标点
+func tokenize(text string) []token {
+	var tokens []token
+	wordStart := -1
+	var word strings.Builder
+
+	flush := func() {
+		if wordStart >= 0 && word.Len() > 0 {
+			tokens = append(tokens, token{norm: word.String(), start: wordStart})
+		}
+		wordStart = -1
+		word.Reset()
+	}
+
+	for i, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case unicode.IsPunct(r):
+			if wordStart < 0 {
+				wordStart = i
+			}
+		case isCJK(r):
+			flush()
+			tokens = append(tokens, token{norm: string(r), start: i})
+		default:
+			if wordStart < 0 {
+				wordStart = i
+			}
+			word.WriteRune(unicode.ToLower(r))
+		}
+	}
+	flush()
+	return tokens
+}
+
+func tokensEqual(a, b []token) bool {
+	for i := range a {
+		if a[i].norm != b[i].norm {
+			return false
+		}
+	}
+	return true
+}
+
+// joinText 拼接文本，中日韩文字之间不插入空格
+func joinText(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	last := []rune(a)
+	first := []rune(b)
+	if isCJK(last[len(last)-1]) || isCJK(first[0]) || unicode.IsSpace(last[len(last)-1]) {
+		return a + b
+	}
+	return a + " " + b
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r) ||
+		(r >= 0x3000 && r <= 0x303F) || // CJK 标点
+		(r >= 0xFF00 && r <= 0xFFEF) // 全角字符
+}
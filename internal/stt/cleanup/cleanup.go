@@ -0,0 +1,84 @@
+// Package cleanup 使用 LLM 对识别结果做后处理：补全标点、按术语表纠正专有名词、去掉语气词
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/telepace/voiceflow/internal/llm"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+// DefaultPrompt 为默认的修正指令
+const DefaultPrompt = "你是语音识别结果的校对员。请修正用户消息中的识别文本：补全标点符号，" +
+	"纠正同音或近音导致的错别字，去掉“嗯”“呃”“那个”等无意义的语气词和重复词。" +
+	"不要改变原意，不要回答或评论其中的内容，不要翻译，只输出修正后的文本。"
+
+const defaultTimeout = 10 * time.Second
+
+// Options 控制后处理的提示词和使用的模型
+type Options struct {
+	// Prompt 为修正指令，为空时使用 DefaultPrompt
+	Prompt string
+	// Glossary 为领域术语，识别结果中发音相近的词会被纠正为这些写法
+	Glossary []string
+	// Model 为空时使用 LLM 提供商的默认模型
+	Model   string
+	Timeout time.Duration
+}
+
+// Cleaner 调用 LLM 修正识别结果，可以并发使用
+type Cleaner struct {
+	llm     llm.Service
+	system  string
+	model   string
+	timeout time.Duration
+}
+
+// New 创建后处理器
+func New(svc llm.Service, opts Options) *Cleaner {
+	system := opts.Prompt
+	if system == "" {
+		system = DefaultPrompt
+	}
+	if len(opts.Glossary) > 0 {
+		system += "\n\n术语表（识别结果中发音相近的词请改为以下写法）：\n" + strings.Join(opts.Glossary, "\n")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	return &Cleaner{llm: svc, system: system, model: opts.Model, timeout: opts.Timeout}
+}
+
+// Clean 返回修正后的文本
+// 模型的输出明显不像是对原文的修正（为空或远长于原文）时返回错误，调用方应继续使用原文
+func (c *Cleaner) Clean(ctx context.Context, text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	reply, err := llm.Chat(ctx, c.llm, []models.ChatMessage{
+		{Role: models.RoleSystem, Content: c.system},
+		{Role: models.RoleUser, Content: text},
+	}, models.ChatOptions{Model: c.model})
+	if err != nil {
+		return "", fmt.Errorf("识别结果后处理失败: %v", err)
+	}
+
+	cleaned := strings.TrimSpace(reply.Content)
+	if cleaned == "" {
+		return "", fmt.Errorf("识别结果后处理返回了空文本")
+	}
+	// 补全标点不会让文本变长太多，远长于原文通常是模型回答了其中的问题
+	if limit := 2*utf8.RuneCountInString(text) + 20; utf8.RuneCountInString(cleaned) > limit {
+		return "", fmt.Errorf("识别结果后处理的输出过长，疑似没有按要求修正")
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,46 @@
+package cleanup
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+type fakeLLM struct {
+	reply    string
+	messages []models.ChatMessage
+}
+
+func (f *fakeLLM) GetResponse(prompt string) (string, error) {
+	return f.reply, nil
+}
+
+func (f *fakeLLM) Chat(ctx context.Context, messages []models.ChatMessage, opts models.ChatOptions) (models.ChatMessage, error) {
+	f.messages = messages
+	return models.ChatMessage{Role: models.RoleAssistant, Content: f.reply}, nil
+}
+
+func TestClean(t *testing.T) {
+	svc := &fakeLLM{reply: "我想查一下 VoiceFlow 的订单。"}
+	cleaner := New(svc, Options{Glossary: []string{"VoiceFlow"}})
+
+	cleaned, err := cleaner.Clean(context.Background(), "嗯 我想查一下 voice flow 的 订单")
+	assert.NoError(t, err)
+	assert.Equal(t, "我想查一下 VoiceFlow 的订单。", cleaned)
+	assert.True(t, strings.HasPrefix(svc.messages[0].Content, DefaultPrompt))
+	assert.Contains(t, svc.messages[0].Content, "VoiceFlow")
+	assert.Equal(t, "嗯 我想查一下 voice flow 的 订单", svc.messages[1].Content)
+}
+
+func TestCleanRejectsAnswers(t *testing.T) {
+	svc := &fakeLLM{reply: strings.Repeat("今天北京晴，气温二十度，适合出门。", 3)}
+	_, err := New(svc, Options{}).Clean(context.Background(), "今天天气怎么样")
+	assert.Error(t, err)
+
+	svc.reply = ""
+	_, err = New(svc, Options{}).Clean(context.Background(), "今天天气怎么样")
+	assert.Error(t, err)
+}
@@ -2,18 +2,92 @@ package google
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/internal/models"
 	"github.com/telepace/voiceflow/pkg/config"
 	"github.com/telepace/voiceflow/pkg/logger"
 )
 
+const (
+	// v1p1beta1 支持 alternativeLanguageCodes、speechContexts boost 以及 MP3/WEBM_OPUS 编码
+	apiBase = "https://speech.googleapis.com/v1p1beta1"
+	// syncLimit 同步识别接口可处理的最大音频时长
+	syncLimit = 60 * time.Second
+	// longRunningTimeout 长音频识别的最长等待时间
+	longRunningTimeout = 10 * time.Minute
+	// pollMaxBackoff 轮询长音频识别结果的最大间隔
+	pollMaxBackoff = 5 * time.Second
+	// maxInlineContent 请求中内联的音频（base64 编码后）的大小上限
+	maxInlineContent = 10 << 20
+)
+
 type GoogleSTT struct {
-	apiKey string
+	apiKey       string
+	languageCode string
+	altLanguages []string
+	sampleRate   int
+	punctuation  bool
+	phrases      []string
+	boost        float64
+	model        string
+	longRunning  bool
+	baseURL      string
+	client       *http.Client
+}
+
+// 以下为 Google Speech-to-Text REST 接口的请求与响应结构
+
+type recognitionConfig struct {
+	Encoding                   string          `json:"encoding,omitempty"`
+	SampleRateHertz            int             `json:"sampleRateHertz,omitempty"`
+	LanguageCode               string          `json:"languageCode"`
+	AlternativeLanguageCodes   []string        `json:"alternativeLanguageCodes,omitempty"`
+	EnableAutomaticPunctuation bool            `json:"enableAutomaticPunctuation,omitempty"`
+	SpeechContexts             []speechContext `json:"speechContexts,omitempty"`
+	Model                      string          `json:"model,omitempty"`
+}
+
+type speechContext struct {
+	Phrases []string `json:"phrases"`
+	Boost   float64  `json:"boost,omitempty"`
+}
+
+type recognizeRequest struct {
+	Config recognitionConfig `json:"config"`
+	Audio  struct {
+		Content string `json:"content"`
+	} `json:"audio"`
+}
+
+type recognizeResponse struct {
+	Results []struct {
+		Alternatives []struct {
+			Transcript string  `json:"transcript"`
+			Confidence float64 `json:"confidence"`
+		} `json:"alternatives"`
+		ResultEndTime string `json:"resultEndTime"`
+		LanguageCode  string `json:"languageCode"`
+	} `json:"results"`
+}
+
+type operation struct {
+	Name  string `json:"name"`
+	Done  bool   `json:"done"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Response *recognizeResponse `json:"response"`
 }
 
 // NewGoogleSTT 创建并返回一个新的 GoogleSTT 实例
@@ -22,55 +96,250 @@ func NewGoogleSTT() *GoogleSTT {
 	if err != nil {
 		logger.Fatalf("配置初始化失败: %v", err)
 	}
-	return &GoogleSTT{
-		apiKey: cfg.Google.STTKey,
+
+	sttCfg := cfg.Google.STT
+	g := &GoogleSTT{
+		apiKey:       cfg.Google.STTKey,
+		languageCode: sttCfg.LanguageCode,
+		altLanguages: sttCfg.AlternativeLanguageCodes,
+		sampleRate:   sttCfg.SampleRate,
+		punctuation:  sttCfg.EnableAutomaticPunctuation,
+		phrases:      sttCfg.Phrases,
+		boost:        sttCfg.Boost,
+		model:        sttCfg.Model,
+		longRunning:  sttCfg.LongRunning,
+		baseURL:      apiBase,
+		client:       &http.Client{Timeout: 2 * time.Minute},
+	}
+	if g.languageCode == "" {
+		g.languageCode = "en-US"
 	}
+	if g.sampleRate <= 0 {
+		g.sampleRate = 16000
+	}
+	return g
 }
 
 // Recognize 调用 Google STT API 将音频数据转换为文本
 func (g *GoogleSTT) Recognize(audioData []byte, audioURL string) (string, error) {
-	// 忽略 audioURL，仅使用 audioData 进行识别
-	return g.recognizeFromData(audioData)
-}
-
-func (g *GoogleSTT) recognizeFromData(audioData []byte) (string, error) {
-	requestBody, err := json.Marshal(map[string]interface{}{
-		"config": map[string]interface{}{
-			"encoding":        "LINEAR16",
-			"sampleRateHertz": 16000,
-			"languageCode":    "en-US",
-		},
-		"audio": map[string]string{
-			"content": base64.StdEncoding.EncodeToString(audioData),
-		},
-	})
+	result, err := g.RecognizeDetailed(audioData, audioURL, models.RecognitionOptions{})
 	if err != nil {
 		return "", err
 	}
+	return result.Text, nil
+}
+
+// RecognizeDetailed 返回识别出的全部结果片段，超过同步接口限制的音频使用长音频识别
+func (g *GoogleSTT) RecognizeDetailed(audioData []byte, audioURL string, opts models.RecognitionOptions) (*models.Transcript, error) {
+	// 忽略 audioURL，仅使用 audioData 进行识别
+	if len(audioData) == 0 {
+		return nil, fmt.Errorf("音频数据为空")
+	}
+
+	request := recognizeRequest{Config: g.buildConfig(audioData)}
+	if opts.Language != "" {
+		request.Config.LanguageCode = opts.Language
+	}
+	request.Audio.Content = base64.StdEncoding.EncodeToString(audioData)
+	if len(request.Audio.Content) > maxInlineContent {
+		return nil, fmt.Errorf("音频超过 Google 内联上传 10MB 的限制，请使用可以切分的 WAV 音频")
+	}
+
+	duration, known := g.duration(audioData)
+	var (
+		resp *recognizeResponse
+		err  error
+	)
+	if g.longRunning && (!known || duration > syncLimit) {
+		// 压缩格式无法得知时长，使用长音频识别避免超过同步接口的限制
+		if known {
+			logger.Infof("音频时长 %s 超过同步识别限制，使用长音频识别", duration)
+		}
+		resp, err = g.longRunningRecognize(request)
+	} else {
+		resp, err = g.recognize(request)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	transcript := toTranscript(resp)
+	if known {
+		transcript.Duration = duration.Seconds()
+	}
+	return transcript, nil
+}
 
-	endpoint := fmt.Sprintf("https://speech.googleapis.com/v1/speech:recognize?key=%s", g.apiKey)
+func (g *GoogleSTT) buildConfig(audioData []byte) recognitionConfig {
+	cfg := recognitionConfig{
+		LanguageCode:               g.languageCode,
+		AlternativeLanguageCodes:   g.altLanguages,
+		EnableAutomaticPunctuation: g.punctuation,
+		Model:                      g.model,
+	}
+	if len(g.phrases) > 0 {
+		cfg.SpeechContexts = []speechContext{{Phrases: g.phrases, Boost: g.boost}}
+	}
+
+	// 带文件头的格式由服务端读取采样率，裸数据按 LINEAR16 处理
+	switch audio.DetectContainer(audioData) {
+	case audio.ContainerWAV:
+		cfg.Encoding = "LINEAR16"
+		if format, _, err := audio.ParseWAV(audioData); err == nil {
+			cfg.SampleRateHertz = format.SampleRate
+		}
+	case audio.ContainerFLAC:
+		cfg.Encoding = "FLAC"
+	case audio.ContainerOGG:
+		cfg.Encoding = "OGG_OPUS"
+		cfg.SampleRateHertz = 48000
+	case audio.ContainerWebM:
+		cfg.Encoding = "WEBM_OPUS"
+		cfg.SampleRateHertz = 48000
+	case audio.ContainerMP3:
+		cfg.Encoding = "MP3"
+		cfg.SampleRateHertz = g.sampleRate
+	default:
+		cfg.Encoding = "LINEAR16"
+		cfg.SampleRateHertz = g.sampleRate
+	}
+	return cfg
+}
+
+// duration 计算 PCM 音频的时长，压缩格式无法直接得到时长
+func (g *GoogleSTT) duration(audioData []byte) (time.Duration, bool) {
+	switch audio.DetectContainer(audioData) {
+	case audio.ContainerWAV:
+		format, pcm, err := audio.ParseWAV(audioData)
+		if err != nil {
+			return 0, false
+		}
+		return format.Duration(len(pcm)), true
+	case audio.ContainerUnknown:
+		format := audio.Format{SampleRate: g.sampleRate, Channels: 1, BitsPerSample: 16}
+		return format.Duration(len(audioData)), true
+	default:
+		return 0, false
+	}
+}
+
+func (g *GoogleSTT) recognize(request recognizeRequest) (*recognizeResponse, error) {
+	var result recognizeResponse
+	if err := g.post("/speech:recognize", request, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// longRunningRecognize 提交长音频识别任务并轮询操作状态直到完成
+func (g *GoogleSTT) longRunningRecognize(request recognizeRequest) (*recognizeResponse, error) {
+	var op operation
+	if err := g.post("/speech:longrunningrecognize", request, &op); err != nil {
+		return nil, err
+	}
+	if op.Name == "" {
+		return nil, fmt.Errorf("google STT 未返回操作名称")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), longRunningTimeout)
+	defer cancel()
+
+	backoff := 500 * time.Millisecond
+	for !op.Done {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("google 长音频识别超时: %s", op.Name)
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > pollMaxBackoff {
+			backoff = pollMaxBackoff
+		}
+
+		endpoint := fmt.Sprintf("%s/operations/%s?key=%s", g.baseURL, op.Name, g.apiKey)
+		req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := g.do(req, &op); err != nil {
+			return nil, err
+		}
+		logger.Debugf("google 长音频识别进行中: %s", op.Name)
+	}
+
+	if op.Error != nil {
+		return nil, fmt.Errorf("google STT error(code=%d): %s", op.Error.Code, op.Error.Message)
+	}
+	if op.Response == nil {
+		return &recognizeResponse{}, nil
+	}
+	return op.Response, nil
+}
+
+func (g *GoogleSTT) post(path string, body interface{}, out interface{}) error {
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s%s?key=%s", g.baseURL, path, g.apiKey)
 	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(requestBody))
 	if err != nil {
-		return "", err
+		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	return g.do(req, out)
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func (g *GoogleSTT) do(req *http.Request, out interface{}) error {
+	resp, err := g.client.Do(req)
 	if err != nil {
-		return "", err
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return "", fmt.Errorf("google STT error: %s", string(body))
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("google STT error: %s", string(body))
 	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+// toTranscript 将每个结果的首选候选转换为分段，静音音频返回空结果
+func toTranscript(resp *recognizeResponse) *models.Transcript {
+	transcript := &models.Transcript{}
+	var texts []string
+	start := 0.0
+	for _, result := range resp.Results {
+		end := parseDuration(result.ResultEndTime)
+		if len(result.Alternatives) == 0 {
+			start = end
+			continue
+		}
+		text := strings.TrimSpace(result.Alternatives[0].Transcript)
+		if text == "" {
+			start = end
+			continue
+		}
+		if transcript.Language == "" {
+			transcript.Language = result.LanguageCode
+		}
+		texts = append(texts, text)
+		transcript.Segments = append(transcript.Segments, models.TranscriptSegment{
+			Start: start,
+			End:   end,
+			Text:  text,
+		})
+		start = end
 	}
+	transcript.Text = strings.Join(texts, " ")
+	return transcript
+}
 
-	return result["results"].([]interface{})[0].(map[string]interface{})["alternatives"].([]interface{})[0].(map[string]interface{})["transcript"].(string), nil
+// parseDuration 解析 protobuf Duration 的 JSON 表示，例如 "12.340s"
+func parseDuration(s string) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "s"), 64)
+	if err != nil {
+		return 0
+	}
+	return v
 }
@@ -0,0 +1,209 @@
+package google
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+func newTestSTT(baseURL string) *GoogleSTT {
+	return &GoogleSTT{
+		apiKey:       "key",
+		languageCode: "en-US",
+		sampleRate:   16000,
+		baseURL:      baseURL,
+		client:       http.DefaultClient,
+	}
+}
+
+// decodeRequest 解析识别请求，并检查 API Key
+func decodeRequest(t *testing.T, r *http.Request) recognizeRequest {
+	assert.Equal(t, "key", r.URL.Query().Get("key"))
+	var request recognizeRequest
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+	return request
+}
+
+func TestRecognizeSilence(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/speech:recognize", r.URL.Path)
+		decodeRequest(t, r)
+		// 静音音频的响应中没有 results
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	transcript, err := newTestSTT(server.URL).RecognizeDetailed(make([]byte, 32000), "", models.RecognitionOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, &models.Transcript{Duration: 1}, transcript)
+}
+
+func TestRecognizeStitchesResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decodeRequest(t, r)
+		fmt.Fprint(w, `{"results":[
+			{"alternatives":[{"transcript":" hello there ","confidence":0.9}],"resultEndTime":"1.500s","languageCode":"en-us"},
+			{"alternatives":[],"resultEndTime":"2s"},
+			{"alternatives":[{"transcript":"general kenobi"},{"transcript":"general canobi"}],"resultEndTime":"3.250s","languageCode":"en-us"}
+		]}`)
+	}))
+	defer server.Close()
+
+	transcript, err := newTestSTT(server.URL).RecognizeDetailed(make([]byte, 3200), "", models.RecognitionOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello there general kenobi", transcript.Text)
+	assert.Equal(t, "en-us", transcript.Language)
+	// 没有候选的结果只推进起始时间
+	assert.Equal(t, []models.TranscriptSegment{
+		{Start: 0, End: 1.5, Text: "hello there"},
+		{Start: 2, End: 3.25, Text: "general kenobi"},
+	}, transcript.Segments)
+}
+
+func TestRecognizeRequestConfig(t *testing.T) {
+	wav := audio.EncodeWAV(audio.Format{SampleRate: 8000, Channels: 1, BitsPerSample: 16}, make([]byte, 1600))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := decodeRequest(t, r)
+		assert.Equal(t, recognitionConfig{
+			Encoding:                   "LINEAR16",
+			SampleRateHertz:            8000,
+			LanguageCode:               "zh-CN",
+			AlternativeLanguageCodes:   []string{"en-US"},
+			EnableAutomaticPunctuation: true,
+			SpeechContexts:             []speechContext{{Phrases: []string{"telepace", "voiceflow"}, Boost: 15}},
+			Model:                      "latest_long",
+		}, request.Config)
+		assert.Equal(t, base64.StdEncoding.EncodeToString(wav), request.Audio.Content)
+		fmt.Fprint(w, `{"results":[{"alternatives":[{"transcript":"你好"}]}]}`)
+	}))
+	defer server.Close()
+
+	g := newTestSTT(server.URL)
+	g.altLanguages = []string{"en-US"}
+	g.punctuation = true
+	g.phrases = []string{"telepace", "voiceflow"}
+	g.boost = 15
+	g.model = "latest_long"
+	// 会话指定的语言优先于配置
+	text, err := g.RecognizeDetailed(wav, "", models.RecognitionOptions{Language: "zh-CN"})
+	assert.NoError(t, err)
+	assert.Equal(t, "你好", text.Text)
+	assert.Equal(t, 0.1, text.Duration)
+}
+
+func TestBuildConfigEncoding(t *testing.T) {
+	cases := []struct {
+		name       string
+		data       []byte
+		encoding   string
+		sampleRate int
+	}{
+		{"裸 PCM", make([]byte, 4), "LINEAR16", 16000},
+		{"FLAC 由服务端读取采样率", []byte("fLaC"), "FLAC", 0},
+		{"Ogg Opus", []byte("OggS"), "OGG_OPUS", 48000},
+		{"WebM Opus", []byte{0x1A, 0x45, 0xDF, 0xA3}, "WEBM_OPUS", 48000},
+		{"MP3", []byte("ID3"), "MP3", 16000},
+	}
+	g := newTestSTT("")
+	for _, tc := range cases {
+		cfg := g.buildConfig(tc.data)
+		assert.Equal(t, tc.encoding, cfg.Encoding, tc.name)
+		assert.Equal(t, tc.sampleRate, cfg.SampleRateHertz, tc.name)
+		assert.Empty(t, cfg.SpeechContexts, tc.name)
+	}
+}
+
+func TestLongRunningRecognize(t *testing.T) {
+	var polls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "key", r.URL.Query().Get("key"))
+		switch r.URL.Path {
+		case "/speech:longrunningrecognize":
+			decodeRequest(t, r)
+			fmt.Fprint(w, `{"name":"op-1"}`)
+		case "/operations/op-1":
+			assert.Equal(t, "GET", r.Method)
+			// 第一次轮询时尚未完成
+			if polls.Add(1) == 1 {
+				fmt.Fprint(w, `{"name":"op-1","done":false}`)
+				return
+			}
+			fmt.Fprint(w, `{"name":"op-1","done":true,"response":{"results":[
+				{"alternatives":[{"transcript":"long audio"}],"resultEndTime":"75s"}]}}`)
+		default:
+			t.Errorf("未预期的请求: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	g := newTestSTT(server.URL)
+	g.longRunning = true
+	// 压缩格式无法得知时长，使用长音频识别
+	transcript, err := g.RecognizeDetailed([]byte("OggS"), "", models.RecognitionOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "long audio", transcript.Text)
+	assert.Equal(t, int32(2), polls.Load())
+}
+
+func TestLongRunningRecognizeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/speech:longrunningrecognize", r.URL.Path)
+		fmt.Fprint(w, `{"name":"op-2","done":true,"error":{"code":3,"message":"bad encoding"}}`)
+	}))
+	defer server.Close()
+
+	g := newTestSTT(server.URL)
+	g.longRunning = true
+	_, err := g.RecognizeDetailed([]byte("OggS"), "", models.RecognitionOptions{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "bad encoding")
+}
+
+func TestRecognizeShortAudioWithLongRunning(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 时长在同步接口限制内时仍使用同步识别
+		assert.Equal(t, "/speech:recognize", r.URL.Path)
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	g := newTestSTT(server.URL)
+	g.longRunning = true
+	_, err := g.RecognizeDetailed(make([]byte, 32000), "", models.RecognitionOptions{})
+	assert.NoError(t, err)
+}
+
+func TestRecognizeInlineLimit(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	// base64 编码后超过 10MB 的音频不发送请求
+	_, err := newTestSTT(server.URL).RecognizeDetailed(make([]byte, 8<<20), "", models.RecognitionOptions{})
+	assert.Error(t, err)
+	assert.Equal(t, int32(0), requests.Load())
+}
+
+func TestRecognizeHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"API key not valid"}}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	_, err := newTestSTT(server.URL).Recognize(make([]byte, 3200), "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "API key not valid")
+
+	_, err = newTestSTT(server.URL).Recognize(nil, "")
+	assert.Error(t, err)
+}
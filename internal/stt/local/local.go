@@ -2,21 +2,90 @@ package local
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
 	"os/exec"
+	"strings"
+	"time"
 
+	"github.com/gorilla/websocket"
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/pkg/config"
 	"github.com/telepace/voiceflow/pkg/logger"
 )
 
+const (
+	modeCommand   = "command"
+	modeHTTP      = "http"
+	modeWebSocket = "websocket"
+
+	// wsChunkSize 通过 WebSocket 发送音频时每包的大小（16kHz 16 位单声道约 250ms）
+	wsChunkSize = 8000
+)
+
 type LocalSTT struct {
-	modelPath string // 本地模型路径
+	mode       string
+	command    string
+	args       []string
+	modelPath  string // 本地模型路径，可以是 VOSK、whisper.cpp 等模型
+	language   string
+	httpURL    string
+	wsURL      string
+	sampleRate int
+	timeout    time.Duration
 }
 
 // NewLocalSTT 创建并返回一个新的 LocalSTT 实例
 func NewLocalSTT() *LocalSTT {
-	return &LocalSTT{
-		modelPath: "/path/to/your/local/model", // 可以是 VOSK、DeepSpeech 等模型
+	cfg, err := config.GetConfig()
+	if err != nil {
+		logger.Fatalf("配置初始化失败: %v", err)
+	}
+
+	c := cfg.LocalSTT
+	l := &LocalSTT{
+		mode:       c.Mode,
+		command:    c.Command,
+		args:       c.Args,
+		modelPath:  c.ModelPath,
+		language:   c.Language,
+		httpURL:    c.HTTPURL,
+		wsURL:      c.WsURL,
+		sampleRate: c.SampleRate,
+		timeout:    time.Duration(c.Timeout) * time.Second,
+	}
+	if l.mode == "" {
+		l.mode = modeCommand
+	}
+	if l.sampleRate <= 0 {
+		l.sampleRate = 16000
+	}
+	if l.timeout <= 0 {
+		l.timeout = 60 * time.Second
 	}
+
+	switch l.mode {
+	case modeCommand:
+		if l.command == "" {
+			logger.Fatalf("本地 STT 使用 command 模式时必须配置 local_stt.command")
+		}
+	case modeHTTP:
+		if l.httpURL == "" {
+			logger.Fatalf("本地 STT 使用 http 模式时必须配置 local_stt.http_url")
+		}
+	case modeWebSocket:
+		if l.wsURL == "" {
+			logger.Fatalf("本地 STT 使用 websocket 模式时必须配置 local_stt.ws_url")
+		}
+	default:
+		logger.Fatalf("未知的本地 STT 模式: %s，可选值为 command、http、websocket", l.mode)
+	}
+	return l
 }
 
 // Recognize 使用本地 STT 模型将音频转换为文本
@@ -24,43 +93,213 @@ func (l *LocalSTT) Recognize(audioData []byte, audioURL string) (string, error)
 	if audioURL != "" {
 		logger.Infof("本地 STT 不支持使用 audioURL，忽略该参数")
 	}
+	if len(audioData) == 0 {
+		return "", fmt.Errorf("音频数据为空")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
 
-	// 将音频数据写入临时文件
-	tempFilePath := "/tmp/input.wav"
-	err := writeTempFile(tempFilePath, audioData)
+	var (
+		text string
+		err  error
+	)
+	switch l.mode {
+	case modeHTTP:
+		text, err = l.recognizeHTTP(ctx, audioData)
+	case modeWebSocket:
+		text, err = l.recognizeWebSocket(ctx, audioData)
+	default:
+		text, err = l.recognizeCommand(ctx, audioData)
+	}
 	if err != nil {
+		return "", err
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("本地 STT 未能识别出文本")
+	}
+	return text, nil
+}
+
+// recognizeCommand 将音频写入独立的临时文件后调用本地命令行工具识别
+// 参数中的 {input}、{model}、{language} 会被替换，命令不经过 shell 执行
+func (l *LocalSTT) recognizeCommand(ctx context.Context, audioData []byte) (string, error) {
+	container := audio.DetectContainer(audioData)
+	if container == audio.ContainerUnknown {
+		audioData = audio.EncodeWAV(l.pcmFormat(), audioData)
+		container = audio.ContainerWAV
+	}
+
+	tempFile, err := os.CreateTemp("", "voiceflow-stt-*"+container.Extension)
+	if err != nil {
+		return "", fmt.Errorf("创建临时音频文件失败: %v", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(audioData); err != nil {
+		tempFile.Close()
 		return "", fmt.Errorf("写入临时音频文件失败: %v", err)
 	}
+	if err := tempFile.Close(); err != nil {
+		return "", fmt.Errorf("写入临时音频文件失败: %v", err)
+	}
+
+	replacer := strings.NewReplacer(
+		"{input}", tempFile.Name(),
+		"{model}", l.modelPath,
+		"{language}", l.language,
+	)
+	args := make([]string, len(l.args))
+	for i, arg := range l.args {
+		args[i] = replacer.Replace(arg)
+	}
 
-	// 调用 VOSK 或其他本地 STT 工具进行识别
-	// 示例：调用 VOSK 命令行接口
-	cmd := exec.Command("vosk", "--model", l.modelPath, "--input", tempFilePath)
+	cmd := exec.CommandContext(ctx, l.command, args...)
 	var out bytes.Buffer
 	var stderr bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &stderr
 
-	err = cmd.Run()
-	if err != nil {
+	if err := cmd.Run(); err != nil {
 		logger.Errorf("本地 STT 命令执行错误: %v, stderr: %s", err, stderr.String())
 		return "", fmt.Errorf("本地 STT 命令执行错误: %v, stderr: %s", err, stderr.String())
 	}
+	return out.String(), nil
+}
 
-	recognizedText := out.String()
-	if recognizedText == "" {
-		return "", fmt.Errorf("本地 STT 未能识别出文本")
+// recognizeHTTP 调用本地 HTTP 识别服务，兼容 whisper.cpp server 的 /inference 接口
+func (l *LocalSTT) recognizeHTTP(ctx context.Context, audioData []byte) (string, error) {
+	container := audio.DetectContainer(audioData)
+	if container == audio.ContainerUnknown {
+		audioData = audio.EncodeWAV(l.pcmFormat(), audioData)
+		container = audio.ContainerWAV
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", "audio"+container.Extension)
+	if err != nil {
+		return "", fmt.Errorf("创建表单文件失败: %v", err)
+	}
+	if _, err := part.Write(audioData); err != nil {
+		return "", fmt.Errorf("写入音频数据失败: %v", err)
+	}
+	fields := [][2]string{{"response_format", "json"}}
+	if l.language != "" {
+		fields = append(fields, [2]string{"language", l.language})
+	}
+	for _, field := range fields {
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return "", fmt.Errorf("写入参数 %s 失败: %v", field[0], err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("关闭 writer 失败: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", l.httpURL, body)
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %v", err)
 	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	return recognizedText, nil
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("请求本地 STT 服务失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("本地 STT 服务返回错误，状态码: %d，响应: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("解析响应失败: %v, 响应内容: %s", err, string(respBody))
+	}
+	return result.Text, nil
 }
 
-// writeTempFile 将音频数据写入指定路径的临时文件
-func writeTempFile(filePath string, data []byte) error {
-	cmd := exec.Command("sh", "-c", fmt.Sprintf("echo '%s' > %s", bytesToString(data), filePath))
-	return cmd.Run()
+// recognizeWebSocket 调用 Vosk server 风格的 WebSocket 识别服务
+// 协议：先发送 config，随后发送 16 位 PCM 数据，最后发送 eof 并读取最终结果
+func (l *LocalSTT) recognizeWebSocket(ctx context.Context, audioData []byte) (string, error) {
+	pcm := audioData
+	sampleRate := l.sampleRate
+	if audio.IsWAV(audioData) {
+		format, data, err := audio.ParseWAV(audioData)
+		if err != nil {
+			return "", fmt.Errorf("解析 WAV 音频失败: %v", err)
+		}
+		pcm = data
+		sampleRate = format.SampleRate
+	} else if container := audio.DetectContainer(audioData); container != audio.ContainerUnknown {
+		return "", fmt.Errorf("WebSocket 模式仅支持 WAV 或 PCM 音频，收到 %s", container.Name)
+	}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, l.wsURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("连接本地 STT 服务失败: %v", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetReadDeadline(deadline)
+		conn.SetWriteDeadline(deadline)
+	}
+
+	if err := conn.WriteJSON(map[string]interface{}{
+		"config": map[string]interface{}{"sample_rate": sampleRate},
+	}); err != nil {
+		return "", fmt.Errorf("发送配置失败: %v", err)
+	}
+
+	var texts []string
+	readResult := func() error {
+		var result struct {
+			Text    string `json:"text"`
+			Partial string `json:"partial"`
+		}
+		if err := conn.ReadJSON(&result); err != nil {
+			return fmt.Errorf("读取识别结果失败: %v", err)
+		}
+		// partial 为中间结果，只保留每句的最终 text
+		if result.Text != "" {
+			texts = append(texts, result.Text)
+		}
+		return nil
+	}
+
+	// Vosk 每收到一包音频都会返回一条中间或最终结果
+	for start := 0; start < len(pcm); start += wsChunkSize {
+		end := start + wsChunkSize
+		if end > len(pcm) {
+			end = len(pcm)
+		}
+		if err := conn.WriteMessage(websocket.BinaryMessage, pcm[start:end]); err != nil {
+			return "", fmt.Errorf("发送音频数据失败: %v", err)
+		}
+		if err := readResult(); err != nil {
+			return "", err
+		}
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"eof" : 1}`)); err != nil {
+		return "", fmt.Errorf("发送结束信号失败: %v", err)
+	}
+	if err := readResult(); err != nil {
+		return "", err
+	}
+
+	return strings.Join(texts, " "), nil
 }
 
-// bytesToString 将字节数组转换为字符串，确保数据安全
-func bytesToString(data []byte) string {
-	return fmt.Sprintf("%s", data)
+func (l *LocalSTT) pcmFormat() audio.Format {
+	return audio.Format{SampleRate: l.sampleRate, Channels: 1, BitsPerSample: 16}
 }
@@ -0,0 +1,151 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/audio"
+)
+
+func newTestSTT(mode string) *LocalSTT {
+	return &LocalSTT{
+		mode:       mode,
+		modelPath:  "/models/vosk-cn",
+		language:   "zh",
+		sampleRate: 16000,
+		timeout:    5 * time.Second,
+	}
+}
+
+func TestRecognizeCommand(t *testing.T) {
+	l := newTestSTT(modeCommand)
+	// 通过 sh 输出命令收到的参数：临时文件扩展名、文件头、模型和语言
+	l.command = "sh"
+	l.args = []string{"-c", `printf '%s %s %s %s' "${1##*.}" "$(head -c 4 "$1")" "$2" "$3"`, "sh", "{input}", "{model}", "{language}"}
+
+	text, err := l.Recognize(make([]byte, 3200), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "wav RIFF /models/vosk-cn zh", text)
+}
+
+func TestRecognizeCommandError(t *testing.T) {
+	l := newTestSTT(modeCommand)
+	l.command = "sh"
+	l.args = []string{"-c", "echo 模型不存在 >&2; exit 3"}
+
+	_, err := l.Recognize(make([]byte, 3200), "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "模型不存在")
+}
+
+func TestRecognizeHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, "json", r.FormValue("response_format"))
+		assert.Equal(t, "zh", r.FormValue("language"))
+		file, header, err := r.FormFile("file")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "audio.wav", header.Filename)
+			data, _ := io.ReadAll(file)
+			assert.True(t, audio.IsWAV(data))
+		}
+		fmt.Fprint(w, `{"text":" 你好世界 "}`)
+	}))
+	defer server.Close()
+
+	l := newTestSTT(modeHTTP)
+	l.httpURL = server.URL
+	text, err := l.Recognize(make([]byte, 3200), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "你好世界", text)
+}
+
+func TestRecognizeHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not loaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	l := newTestSTT(modeHTTP)
+	l.httpURL = server.URL
+	_, err := l.Recognize(make([]byte, 3200), "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "503")
+}
+
+// newVoskServer 模拟 Vosk server：每包音频返回一条结果，收到 eof 后返回最后一句
+func newVoskServer(t *testing.T, sampleRate int, results []string) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		var cfg struct {
+			Config struct {
+				SampleRate int `json:"sample_rate"`
+			} `json:"config"`
+		}
+		assert.NoError(t, conn.ReadJSON(&cfg))
+		assert.Equal(t, sampleRate, cfg.Config.SampleRate)
+
+		for _, result := range results {
+			mt, data, err := conn.ReadMessage()
+			if !assert.NoError(t, err) {
+				return
+			}
+			if mt == websocket.TextMessage {
+				assert.JSONEq(t, `{"eof":1}`, string(data))
+			} else {
+				assert.LessOrEqual(t, len(data), wsChunkSize)
+			}
+			conn.WriteMessage(websocket.TextMessage, []byte(result))
+		}
+	}))
+}
+
+func TestRecognizeWebSocket(t *testing.T) {
+	server := newVoskServer(t, 16000, []string{
+		`{"partial":"你"}`,
+		`{"text":"你好"}`,
+		`{"partial":"世"}`,
+		`{"text":"世界"}`,
+	})
+	defer server.Close()
+
+	l := newTestSTT(modeWebSocket)
+	l.wsURL = "ws" + strings.TrimPrefix(server.URL, "http")
+	// 三包音频加上 eof
+	text, err := l.Recognize(make([]byte, 2*wsChunkSize+100), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "你好 世界", text)
+}
+
+func TestRecognizeWebSocketWAV(t *testing.T) {
+	server := newVoskServer(t, 8000, []string{`{"partial":""}`, `{"text":"hello"}`})
+	defer server.Close()
+
+	l := newTestSTT(modeWebSocket)
+	l.wsURL = "ws" + strings.TrimPrefix(server.URL, "http")
+	wav := audio.EncodeWAV(audio.Format{SampleRate: 8000, Channels: 1, BitsPerSample: 16}, make([]byte, 1600))
+	text, err := l.Recognize(wav, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", text)
+}
+
+func TestRecognizeWebSocketRejectsCompressed(t *testing.T) {
+	l := newTestSTT(modeWebSocket)
+	l.wsURL = "ws://127.0.0.1:1"
+	_, err := l.recognizeWebSocket(context.Background(), append([]byte("fLaC"), make([]byte, 64)...))
+	assert.Error(t, err)
+}
@@ -0,0 +1,212 @@
+// internal/stt/openai/openai.go
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/pkg/config"
+	"github.com/telepace/voiceflow/pkg/logger"
+)
+
+// 没有文件头的音频按 16kHz、单声道、16 位 PCM 封装为 WAV 后上传
+var rawPCMFormat = audio.Format{SampleRate: 16000, Channels: 1, BitsPerSample: 16}
+
+// STT 对接任意实现了 /v1/audio/transcriptions 的服务，
+// 例如 OpenAI、Groq、自建的 faster-whisper 或 whisper.cpp 服务
+type STT struct {
+	apiKey         string
+	baseURL        string
+	model          string
+	language       string
+	task           string
+	temperature    float64
+	responseFormat string
+	extraFields    map[string]string
+	client         *http.Client
+}
+
+type transcriptionResponse struct {
+	Text     string  `json:"text"`
+	Language string  `json:"language,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+	Segments []struct {
+		Start      float64 `json:"start"`
+		End        float64 `json:"end"`
+		Text       string  `json:"text"`
+		AvgLogprob float64 `json:"avg_logprob"`
+	} `json:"segments,omitempty"`
+}
+
+// NewOpenAICompatibleSTT 创建并返回一个新的 OpenAI 兼容 STT 实例
+func NewOpenAICompatibleSTT() *STT {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		logger.Fatalf("配置初始化失败: %v", err)
+	}
+
+	c := cfg.OpenAICompatible
+	if c.BaseURL == "" {
+		logger.Fatalf("openai_compatible.base_url 未配置")
+	}
+	if c.Model == "" {
+		logger.Fatalf("openai_compatible.model 未配置")
+	}
+	task := c.Task
+	if task == "" {
+		task = "transcribe"
+	}
+	if task != "transcribe" && task != "translate" {
+		logger.Fatalf("不支持的任务类型: %s，可选值为 transcribe 或 translate", task)
+	}
+	responseFormat := c.ResponseFormat
+	if responseFormat == "" {
+		responseFormat = "verbose_json"
+	}
+	timeout := time.Duration(c.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+
+	return &STT{
+		apiKey:         c.APIKey,
+		baseURL:        strings.TrimRight(c.BaseURL, "/"),
+		model:          c.Model,
+		language:       c.Language,
+		task:           task,
+		temperature:    c.Temperature,
+		responseFormat: responseFormat,
+		extraFields:    c.ExtraFields,
+		client:         &http.Client{Timeout: timeout},
+	}
+}
+
+// Recognize 实现 stt.Service 接口
+func (s *STT) Recognize(audioData []byte, audioURL string) (string, error) {
+	result, err := s.RecognizeDetailed(audioData, audioURL, models.RecognitionOptions{})
+	if err != nil {
+		return "", err
+	}
+	return result.Text, nil
+}
+
+// RecognizeDetailed 上传音频并返回识别结果，response_format 为 verbose_json 时包含分段信息
+func (s *STT) RecognizeDetailed(audioData []byte, audioURL string, opts models.RecognitionOptions) (*models.Transcript, error) {
+	if audioURL != "" {
+		logger.Debugf("OpenAI 兼容 STT 不支持使用 audioURL，忽略该参数")
+	}
+	if len(audioData) == 0 {
+		return nil, fmt.Errorf("音频数据为空")
+	}
+
+	container := audio.DetectContainer(audioData)
+	if container == audio.ContainerUnknown {
+		logger.Debugf("无法识别音频格式，按 %d Hz 裸 PCM 封装为 WAV", rawPCMFormat.SampleRate)
+		audioData = audio.EncodeWAV(rawPCMFormat, audioData)
+		container = audio.ContainerWAV
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", "audio"+container.Extension)
+	if err != nil {
+		return nil, fmt.Errorf("创建表单文件失败: %v", err)
+	}
+	if _, err := part.Write(audioData); err != nil {
+		return nil, fmt.Errorf("写入音频数据失败: %v", err)
+	}
+
+	fields := [][2]string{
+		{"model", s.model},
+		{"response_format", s.responseFormat},
+		{"temperature", fmt.Sprintf("%g", s.temperature)},
+	}
+	language := s.language
+	if opts.Language != "" {
+		language = opts.Language
+	}
+	if language != "" && language != "auto" {
+		fields = append(fields, [2]string{"language", language})
+	}
+	for key, value := range s.extraFields {
+		fields = append(fields, [2]string{key, value})
+	}
+	for _, field := range fields {
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return nil, fmt.Errorf("写入参数 %s 失败: %v", field[0], err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("关闭 writer 失败: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", s.endpoint(), body)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	// 自建服务通常不需要鉴权
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API 请求失败，状态码: %d，响应: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	return s.parseResponse(bodyBytes)
+}
+
+// endpoint 返回当前任务对应的接口地址
+func (s *STT) endpoint() string {
+	if s.task == "translate" {
+		return s.baseURL + "/audio/translations"
+	}
+	return s.baseURL + "/audio/transcriptions"
+}
+
+func (s *STT) parseResponse(body []byte) (*models.Transcript, error) {
+	// text、srt、vtt 等格式直接返回原始文本
+	if s.responseFormat != "json" && s.responseFormat != "verbose_json" {
+		return &models.Transcript{Text: strings.TrimSpace(string(body))}, nil
+	}
+
+	var result transcriptionResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v, 响应内容: %s", err, string(body))
+	}
+
+	transcript := &models.Transcript{
+		Text:     strings.TrimSpace(result.Text),
+		Language: result.Language,
+		Duration: result.Duration,
+	}
+	for _, seg := range result.Segments {
+		transcript.Segments = append(transcript.Segments, models.TranscriptSegment{
+			Start:      seg.Start,
+			End:        seg.End,
+			Text:       strings.TrimSpace(seg.Text),
+			AvgLogprob: seg.AvgLogprob,
+		})
+	}
+	return transcript, nil
+}
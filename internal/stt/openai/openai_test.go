@@ -0,0 +1,143 @@
+package openai
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+func newTestSTT(baseURL string) *STT {
+	return &STT{
+		baseURL:        baseURL,
+		model:          "whisper-1",
+		task:           "transcribe",
+		responseFormat: "verbose_json",
+		client:         http.DefaultClient,
+	}
+}
+
+func TestRecognizeFormFields(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/audio/transcriptions", r.URL.Path)
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, "whisper-1", r.FormValue("model"))
+		assert.Equal(t, "verbose_json", r.FormValue("response_format"))
+		assert.Equal(t, "0.2", r.FormValue("temperature"))
+		// 会话指定的语言优先于配置
+		assert.Equal(t, "en", r.FormValue("language"))
+		assert.Equal(t, "语音助手", r.FormValue("prompt"))
+		file, header, err := r.FormFile("file")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "audio.webm", header.Filename)
+			data, _ := io.ReadAll(file)
+			assert.Equal(t, []byte{0x1A, 0x45, 0xDF, 0xA3, 1}, data)
+		}
+		fmt.Fprint(w, `{"text":" hello world ","language":"english","duration":2.5,
+			"segments":[{"start":0,"end":1.2,"text":" hello","avg_logprob":-0.2},
+			{"start":1.2,"end":2.5,"text":" world ","avg_logprob":-0.4}]}`)
+	}))
+	defer server.Close()
+
+	s := newTestSTT(server.URL + "/v1")
+	s.apiKey = "key"
+	s.language = "zh"
+	s.temperature = 0.2
+	s.extraFields = map[string]string{"prompt": "语音助手"}
+	transcript, err := s.RecognizeDetailed([]byte{0x1A, 0x45, 0xDF, 0xA3, 1}, "", models.RecognitionOptions{Language: "en"})
+	assert.NoError(t, err)
+	assert.Equal(t, &models.Transcript{
+		Text:     "hello world",
+		Language: "english",
+		Duration: 2.5,
+		Segments: []models.TranscriptSegment{
+			{Start: 0, End: 1.2, Text: "hello", AvgLogprob: -0.2},
+			{Start: 1.2, End: 2.5, Text: "world", AvgLogprob: -0.4},
+		},
+	}, transcript)
+}
+
+func TestRecognizeRawPCM(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		// 未配置 api_key 时不发送鉴权头，auto 时不传 language
+		assert.Empty(t, r.Header.Get("Authorization"))
+		_, ok := r.MultipartForm.Value["language"]
+		assert.False(t, ok)
+		// 无法识别的音频按裸 PCM 封装为 WAV
+		file, header, err := r.FormFile("file")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "audio.wav", header.Filename)
+			data, _ := io.ReadAll(file)
+			format, pcm, err := audio.ParseWAV(data)
+			assert.NoError(t, err)
+			assert.Equal(t, rawPCMFormat, format)
+			assert.Len(t, pcm, 320)
+		}
+		fmt.Fprint(w, `{"text":"你好"}`)
+	}))
+	defer server.Close()
+
+	s := newTestSTT(server.URL)
+	s.language = "auto"
+	text, err := s.Recognize(make([]byte, 320), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "你好", text)
+}
+
+func TestRecognizeTranslate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/audio/translations", r.URL.Path)
+		fmt.Fprint(w, `{"text":"hello"}`)
+	}))
+	defer server.Close()
+
+	s := newTestSTT(server.URL + "/v1")
+	s.task = "translate"
+	text, err := s.Recognize([]byte("OggS"), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", text)
+}
+
+func TestRecognizePlainText(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, "text", r.FormValue("response_format"))
+		fmt.Fprint(w, " 你好世界\n")
+	}))
+	defer server.Close()
+
+	s := newTestSTT(server.URL)
+	s.responseFormat = "text"
+	transcript, err := s.RecognizeDetailed([]byte("OggS"), "", models.RecognitionOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, &models.Transcript{Text: "你好世界"}, transcript)
+}
+
+func TestRecognizeErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bad-json/audio/transcriptions" {
+			fmt.Fprint(w, "not json")
+			return
+		}
+		http.Error(w, "model not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := newTestSTT(server.URL).Recognize([]byte("OggS"), "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "404")
+	assert.Contains(t, err.Error(), "model not found")
+
+	_, err = newTestSTT(server.URL+"/bad-json").Recognize([]byte("OggS"), "")
+	assert.Error(t, err)
+
+	_, err = newTestSTT(server.URL).Recognize(nil, "")
+	assert.Error(t, err)
+}
@@ -9,8 +9,10 @@ import (
 	"github.com/telepace/voiceflow/internal/stt/azure"
 	"github.com/telepace/voiceflow/internal/stt/google"
 	"github.com/telepace/voiceflow/internal/stt/local"
+	"github.com/telepace/voiceflow/internal/stt/openai"
 	"github.com/telepace/voiceflow/internal/stt/volcengine"
 	"github.com/telepace/voiceflow/internal/stt/whisper"
+	"github.com/telepace/voiceflow/pkg/config"
 	"github.com/telepace/voiceflow/pkg/logger"
 )
 
@@ -22,22 +24,31 @@ type Service interface {
 // NewService 根据配置返回相应的 STT 服务实现
 func NewService(provider string) (Service, error) {
 	logger.Debugf("Using STT provider: %s", provider)
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("配置初始化失败: %v", err)
+	}
+
+	var svc Service
 	switch provider {
 	case "azure":
-		return azure.NewAzureSTT(), nil
+		svc = azure.NewAzureSTT()
 	case "google":
-		return google.NewGoogleSTT(), nil
+		svc = google.NewGoogleSTT()
 	case "assemblyai-ws":
-		return aaiws.NewAssemblyAI(), nil
+		svc = aaiws.NewAssemblyAI()
 	case "volcengine":
-		return volcengine.NewVolcengineSTT(), nil
+		svc = volcengine.NewVolcengineSTT()
 	case "local":
-		return local.NewLocalSTT(), nil
+		svc = local.NewLocalSTT()
 	case "assemblyai":
-		return assemblyai.NewAssemblyAI(), nil
+		svc = assemblyai.NewAssemblyAI()
 	case "whisper-v3":
-		return whisper.NewWhisperSTT(), nil
+		svc = whisper.NewWhisperSTT()
+	case "openai-compatible":
+		svc = openai.NewOpenAICompatibleSTT()
 	default:
 		return nil, fmt.Errorf("未知的 STT 提供商: %s", provider)
 	}
+	return withChunking(provider, svc, cfg), nil
 }
@@ -2,7 +2,6 @@
 package volcengine
 
 import (
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -10,6 +9,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/telepace/voiceflow/internal/volcengine/protocol"
 	"github.com/telepace/voiceflow/pkg/config"
 	"github.com/telepace/voiceflow/pkg/logger"
 )
@@ -59,15 +59,6 @@ func (s *STT) validateAudioFormat(audioData []byte) error {
 	return nil
 }
 
-// isFinalResponse 判断响应是否为最终响应
-func isFinalResponse(result map[string]interface{}) bool {
-	// 根据 VolcEngine 的响应结构调整逻辑
-	if isFinal, ok := result["is_final"].(bool); ok {
-		return isFinal
-	}
-	return false
-}
-
 // Recognize 调用 VolcEngine 的 STT API 将音频数据转换为文本
 // 新增 audioURL 参数，但 VolcEngine 不使用该参数
 func (s *STT) Recognize(audioData []byte, audioURL string) (string, error) {
@@ -139,21 +130,11 @@ func (s *STT) Recognize(audioData []byte, audioURL string) (string, error) {
 	}
 
 	// 不使用压缩，直接发送
-	fullClientRequest := generateHeader(
-		FULL_CLIENT_REQUEST,
-		NOT_LAST_PACKAGE_NO_SEQUENCE,
-		JSON_SERIALIZATION,
-		NO_COMPRESSION,
-		0x00,
-	)
-	payloadSize := make([]byte, 4)
-	binary.BigEndian.PutUint32(payloadSize, uint32(len(payloadBytes)))
-
-	message := append(fullClientRequest, payloadSize...)
-	message = append(message, payloadBytes...)
-
-	err = conn.WriteMessage(websocket.BinaryMessage, message)
-	if err != nil {
+	if err := writeMessage(conn, &protocol.Message{
+		Type:          protocol.MsgFullClientRequest,
+		Serialization: protocol.SerializationJSON,
+		Payload:       payloadBytes,
+	}); err != nil {
 		logger.Errorf("发送初始消息错误: %v", err)
 		return "", err
 	}
@@ -165,17 +146,12 @@ func (s *STT) Recognize(audioData []byte, audioURL string) (string, error) {
 		return "", err
 	}
 
-	result, err := parseResponse(resData)
+	msg, result, err := parseResponse(resData)
 	if err != nil {
 		logger.Errorf("解析响应错误: %v", err)
 		return "", err
 	}
 
-	if errCode, ok := result["error_code"]; ok {
-		logger.Errorf("服务器返回错误码 %v: %v", errCode, result["error_msg"])
-		return "", fmt.Errorf("服务器返回错误码 %v: %v", errCode, result["error_msg"])
-	}
-
 	logger.Infof("初始响应: %+v", result)
 
 	// 发送音频数据
@@ -183,29 +159,21 @@ func (s *STT) Recognize(audioData []byte, audioURL string) (string, error) {
 	chunkSize := 3200 // 根据需求调整，每个包的音频时长约 100ms（16kHz 采样率，16 位深度，单声道）
 	audioChunks := sliceData(audioData, chunkSize)
 
+	// result_type 为 full 时每次返回的都是截至当前的完整文本
+	var finalText string
 	for i, chunk := range audioChunks {
 		isLast := i == len(audioChunks)-1
 
-		flags := NOT_LAST_PACKAGE_NO_SEQUENCE
+		flags := protocol.FlagNoSequence
 		if isLast {
-			flags = LAST_PACKAGE_NO_SEQUENCE
+			flags = protocol.FlagLastNoSequence
 		}
 
-		audioRequest := generateHeader(
-			AUDIO_ONLY_REQUEST,
-			flags,
-			NO_SERIALIZATION,
-			NO_COMPRESSION,
-			0x00,
-		)
-
-		payloadSize := make([]byte, 4)
-		binary.BigEndian.PutUint32(payloadSize, uint32(len(chunk)))
-
-		message := append(audioRequest, payloadSize...)
-		message = append(message, chunk...)
-
-		err = conn.WriteMessage(websocket.BinaryMessage, message)
+		err = writeMessage(conn, &protocol.Message{
+			Type:    protocol.MsgAudioOnlyRequest,
+			Flags:   flags,
+			Payload: chunk,
+		})
 		if err != nil {
 			logger.Errorf("发送音频数据错误: %v", err)
 			return "", err
@@ -228,15 +196,17 @@ func (s *STT) Recognize(audioData []byte, audioURL string) (string, error) {
 				}
 			}
 
-			result, err = parseResponse(resData)
+			msg, result, err = parseResponse(resData)
 			if err != nil {
+				if msg != nil && msg.Type == protocol.MsgError {
+					logger.Errorf("服务器返回错误: %v", err)
+					return "", err
+				}
 				logger.Errorf("解析响应错误: %v", err)
 				continue
 			}
-
-			if errCode, ok := result["error_code"]; ok {
-				logger.Errorf("服务器返回错误码 %v: %v", errCode, result["error_msg"])
-				return "", fmt.Errorf("服务器返回错误码 %v: %v", errCode, result["error_msg"])
+			if result.Result.Text != "" {
+				finalText = result.Result.Text
 			}
 
 			logger.Infof("中间响应: %+v", result)
@@ -244,7 +214,7 @@ func (s *STT) Recognize(audioData []byte, audioURL string) (string, error) {
 	}
 
 	// 接收服务器的最终响应
-	var finalText string
+	conn.SetReadDeadline(time.Time{})
 	for {
 		_, resData, err := conn.ReadMessage()
 		if err != nil {
@@ -255,25 +225,23 @@ func (s *STT) Recognize(audioData []byte, audioURL string) (string, error) {
 			break
 		}
 
-		var result map[string]interface{}
-		err = json.Unmarshal(resData, &result)
+		msg, result, err := parseResponse(resData)
 		if err != nil {
+			if msg != nil && msg.Type == protocol.MsgError {
+				logger.Errorf("服务器返回错误: %v", err)
+				return "", err
+			}
 			logger.Errorf("解析最终响应错误: %v", err)
 			continue
 		}
 
-		if errCode, ok := result["error_code"]; ok {
-			logger.Errorf("服务器返回错误码 %v: %v", errCode, result["error_msg"])
-			return "", fmt.Errorf("服务器返回错误码 %v: %v", errCode, result["error_msg"])
-		}
-
-		if text, ok := result["text"].(string); ok {
-			finalText += text
-			logger.Infof("识别文本: %s", text)
+		if result.Result.Text != "" {
+			finalText = result.Result.Text
+			logger.Infof("识别文本: %s", finalText)
 		}
 
-		// 使用定义的 isFinalResponse 函数判断是否为最终响应
-		if isFinalResponse(result) {
+		// 服务端以最后一包标志表示识别结束
+		if msg.IsLast() {
 			break
 		}
 	}
@@ -281,126 +249,39 @@ func (s *STT) Recognize(audioData []byte, audioURL string) (string, error) {
 	return finalText, nil
 }
 
-// 定义协议相关的常量和函数
-const (
-	PROTOCOL_VERSION    byte = 0x01
-	DEFAULT_HEADER_SIZE byte = 0x01
-
-	// 消息类型
-	FULL_CLIENT_REQUEST   byte = 0x01
-	AUDIO_ONLY_REQUEST    byte = 0x02
-	FULL_SERVER_RESPONSE  byte = 0x09
-	SERVER_ERROR_RESPONSE byte = 0x0F
-
-	// Message Type Specific Flags
-	NOT_LAST_PACKAGE_NO_SEQUENCE byte = 0x00
-	LAST_PACKAGE_NO_SEQUENCE     byte = 0x02
-
-	// 序列化方法
-	NO_SERIALIZATION   byte = 0x00
-	JSON_SERIALIZATION byte = 0x01
-
-	// 压缩类型
-	NO_COMPRESSION   byte = 0x00
-	GZIP_COMPRESSION byte = 0x01
-)
+// asrResponse 对应识别服务返回的 JSON 负载
+type asrResponse struct {
+	Result struct {
+		Text string `json:"text"`
+	} `json:"result"`
+}
 
-func generateHeader(
-	messageType byte,
-	messageTypeSpecificFlags byte,
-	serialMethod byte,
-	compressionType byte,
-	reservedData byte,
-) []byte {
-	protocolVersion := PROTOCOL_VERSION
-	headerSize := DEFAULT_HEADER_SIZE
-	header := []byte{
-		(protocolVersion << 4) | headerSize,
-		(messageType << 4) | messageTypeSpecificFlags,
-		(serialMethod << 4) | compressionType,
-		reservedData,
+func writeMessage(conn *websocket.Conn, msg *protocol.Message) error {
+	data, err := msg.Marshal()
+	if err != nil {
+		return err
 	}
-	return header
+	return conn.WriteMessage(websocket.BinaryMessage, data)
 }
 
-func parseResponse(data []byte) (map[string]interface{}, error) {
-	if len(data) < 4 {
-		return nil, fmt.Errorf("响应数据过短")
+// parseResponse 使用火山引擎通用的二进制协议解析服务端消息
+// 服务端返回错误消息时同时返回 msg 和 err，便于调用方区分协议错误和业务错误
+func parseResponse(data []byte) (*protocol.Message, *asrResponse, error) {
+	msg, err := protocol.Unmarshal(data)
+	if err != nil {
+		return nil, nil, err
 	}
-	//protocolVersion := data[0] >> 4
-	headerSize := data[0] & 0x0F
-	messageType := data[1] >> 4
-	messageTypeSpecificFlags := data[1] & 0x0F
-	serializationMethod := data[2] >> 4
-	compressionType := data[2] & 0x0F
-	// reserved := data[3]
-
-	headerLength := int(headerSize) * 4
-	if len(data) < headerLength {
-		return nil, fmt.Errorf("数据长度不足以包含完整的头部")
+	if err := msg.Err(); err != nil {
+		return msg, nil, err
 	}
 
-	payload := data[headerLength:]
-
-	result := make(map[string]interface{})
-
-	if messageType == FULL_SERVER_RESPONSE {
-		if len(payload) < 8 {
-			return nil, fmt.Errorf("payload 长度不足以包含序列号和大小信息")
-		}
-		sequence := binary.BigEndian.Uint32(payload[0:4])
-		payloadSize := binary.BigEndian.Uint32(payload[4:8])
-
-		if len(payload) < int(8+payloadSize) {
-			return nil, fmt.Errorf("payload 长度不足以包含完整的消息")
-		}
-
-		payloadMsg := payload[8 : 8+payloadSize]
-
-		if compressionType == GZIP_COMPRESSION {
-			// 本例中不使用压缩，保留此代码以备后用
-		}
-
-		if serializationMethod == JSON_SERIALIZATION {
-			var payloadObj interface{}
-			if err := json.Unmarshal(payloadMsg, &payloadObj); err != nil {
-				return nil, err
-			}
-			result["payload_msg"] = payloadObj
-		} else if serializationMethod != NO_SERIALIZATION {
-			result["payload_msg"] = string(payloadMsg)
-		}
-
-		result["sequence"] = sequence
-
-		if messageTypeSpecificFlags&0x02 != 0 {
-			result["is_last_package"] = true
-		} else {
-			result["is_last_package"] = false
-		}
-	} else if messageType == SERVER_ERROR_RESPONSE {
-		// 解析错误响应
-		if len(payload) < 8 {
-			return nil, fmt.Errorf("payload 长度不足以包含错误代码和大小信息")
+	result := &asrResponse{}
+	if msg.Type == protocol.MsgFullServerResponse && msg.Serialization == protocol.SerializationJSON && len(msg.Payload) > 0 {
+		if err := msg.DecodeJSON(result); err != nil {
+			return msg, nil, err
 		}
-		errorCode := binary.BigEndian.Uint32(payload[:4])
-		errorMsgSize := binary.BigEndian.Uint32(payload[4:8])
-		if len(payload) < int(8+errorMsgSize) {
-			return nil, fmt.Errorf("payload 长度不足以包含完整的错误消息")
-		}
-		errorMsg := payload[8 : 8+errorMsgSize]
-
-		if compressionType == GZIP_COMPRESSION {
-			// 本例中不使用压缩，保留此代码以备后用
-		}
-
-		result["error_code"] = errorCode
-		result["error_msg"] = string(errorMsg)
-	} else {
-		logger.Warn("收到未知的消息类型")
 	}
-
-	return result, nil
+	return msg, result, nil
 }
 
 func sliceData(data []byte, chunkSize int) [][]byte {
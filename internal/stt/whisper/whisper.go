@@ -8,23 +8,45 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"strings"
 
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/internal/models"
 	"github.com/telepace/voiceflow/pkg/config"
 	"github.com/telepace/voiceflow/pkg/logger"
 )
 
+const (
+	taskTranscribe = "transcribe"
+	taskTranslate  = "translate"
+)
+
 type WhisperSTT struct {
 	apiKey      string
 	endpoint    string
 	model       string
 	temperature float64
 	vadModel    string
+	language    string
+	task        string
 }
 
 type WhisperResponse struct {
-	Text     string  `json:"text"`
-	Language string  `json:"language,omitempty"`
-	Duration float64 `json:"duration,omitempty"`
+	Text     string           `json:"text"`
+	Task     string           `json:"task,omitempty"`
+	Language string           `json:"language,omitempty"`
+	Duration float64          `json:"duration,omitempty"`
+	Segments []WhisperSegment `json:"segments,omitempty"`
+}
+
+// WhisperSegment 对应 verbose_json 响应中的分段信息
+type WhisperSegment struct {
+	ID           int     `json:"id"`
+	Start        float64 `json:"start"`
+	End          float64 `json:"end"`
+	Text         string  `json:"text"`
+	AvgLogprob   float64 `json:"avg_logprob"`
+	NoSpeechProb float64 `json:"no_speech_prob"`
 }
 
 func NewWhisperSTT() *WhisperSTT {
@@ -33,16 +55,39 @@ func NewWhisperSTT() *WhisperSTT {
 		logger.Fatalf("配置初始化失败: %v", err)
 	}
 
-	return &WhisperSTT{
+	task := cfg.Whisper.Task
+	if task == "" {
+		task = taskTranscribe
+	}
+	if task != taskTranscribe && task != taskTranslate {
+		logger.Fatalf("不支持的 Whisper 任务类型: %s，可选值为 transcribe 或 translate", task)
+	}
+
+	w := &WhisperSTT{
 		apiKey:      cfg.Whisper.APIKey,
 		endpoint:    cfg.Whisper.Endpoint,
 		model:       cfg.Whisper.Model,
 		temperature: cfg.Whisper.Temperature,
 		vadModel:    cfg.Whisper.VADModel,
+		language:    cfg.Whisper.Language,
+		task:        task,
 	}
+	if _, err := w.taskEndpoint(); err != nil {
+		logger.Fatalf("Whisper 配置错误: %v", err)
+	}
+	return w
 }
 
 func (w *WhisperSTT) Recognize(audioData []byte, audioURL string) (string, error) {
+	result, err := w.RecognizeDetailed(audioData, audioURL, models.RecognitionOptions{})
+	if err != nil {
+		return "", err
+	}
+	return result.Text, nil
+}
+
+// RecognizeDetailed 返回包含分段时间戳和置信度的识别结果
+func (w *WhisperSTT) RecognizeDetailed(audioData []byte, audioURL string, opts models.RecognitionOptions) (*models.Transcript, error) {
 	if w.model != "whisper-v3-turbo" {
 		logger.Warnf("检测到不正确的模型名称: %s，自动修正为: whisper-v3-turbo", w.model)
 		w.model = "whisper-v3-turbo"
@@ -51,35 +96,54 @@ func (w *WhisperSTT) Recognize(audioData []byte, audioURL string) (string, error
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 
-	// 写入音频文件
-	part, err := writer.CreateFormFile("file", "audio.mp3")
+	// 写入音频文件，文件扩展名与实际的封装格式保持一致，无法识别时沿用 mp3
+	container := audio.DetectContainer(audioData)
+	if container == audio.ContainerUnknown {
+		container = audio.ContainerMP3
+	}
+	part, err := writer.CreateFormFile("file", "audio"+container.Extension)
 	if err != nil {
-		return "", fmt.Errorf("创建表单文件失败: %v", err)
+		return nil, fmt.Errorf("创建表单文件失败: %v", err)
 	}
 	if _, err := io.Copy(part, bytes.NewReader(audioData)); err != nil {
-		return "", fmt.Errorf("写入音频数据失败: %v", err)
+		return nil, fmt.Errorf("写入音频数据失败: %v", err)
 	}
 
 	// 添加其他参数，使用正确的模型名称
-	if err := writer.WriteField("model", w.model); err != nil {
-		return "", fmt.Errorf("写入模型参数失败: %v", err)
+	fields := map[string]string{
+		"model":           w.model,
+		"temperature":     fmt.Sprintf("%f", w.temperature),
+		"vad_model":       w.vadModel,
+		"response_format": "verbose_json",
 	}
-	if err := writer.WriteField("temperature", fmt.Sprintf("%f", w.temperature)); err != nil {
-		return "", fmt.Errorf("写入温度参数失败: %v", err)
+	// language 为空或 auto 时交给服务端自动检测，会话指定的语言优先
+	language := w.language
+	if opts.Language != "" {
+		language = opts.Language
 	}
-	if err := writer.WriteField("vad_model", w.vadModel); err != nil {
-		return "", fmt.Errorf("写入 VAD 模型参数失败: %v", err)
+	if language != "" && language != "auto" {
+		fields["language"] = language
+	}
+	for key, value := range fields {
+		if err := writer.WriteField(key, value); err != nil {
+			return nil, fmt.Errorf("写入参数 %s 失败: %v", key, err)
+		}
 	}
 
 	// 添加更详细的错误处理和日志
 	if err := writer.Close(); err != nil {
-		return "", fmt.Errorf("关闭 writer 失败: %v", err)
+		return nil, fmt.Errorf("关闭 writer 失败: %v", err)
+	}
+
+	endpoint, err := w.taskEndpoint()
+	if err != nil {
+		return nil, err
 	}
 
 	// 创建请求
-	req, err := http.NewRequest("POST", w.endpoint, body)
+	req, err := http.NewRequest("POST", endpoint, body)
 	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %v", err)
+		return nil, fmt.Errorf("创建请求失败: %v", err)
 	}
 
 	// 设置请求头
@@ -90,32 +154,62 @@ func (w *WhisperSTT) Recognize(audioData []byte, audioURL string) (string, error
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %v", err)
+		return nil, fmt.Errorf("发送请求失败: %v", err)
 	}
 	defer resp.Body.Close()
 
 	// 读取响应体
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("读取响应失败: %v", err)
+		return nil, fmt.Errorf("读取响应失败: %v", err)
 	}
 
 	// 检查响应状态
 	if resp.StatusCode != http.StatusOK {
 		// 添加更详细的错误信息输出
 		logger.Errorf("API请求失败 - 状态码: %d, 响应内容: %s", resp.StatusCode, string(bodyBytes))
-		return "", fmt.Errorf("API 请求失败，状态码: %d，响应: %s", resp.StatusCode, string(bodyBytes))
+		return nil, fmt.Errorf("API 请求失败，状态码: %d，响应: %s", resp.StatusCode, string(bodyBytes))
 	}
 
 	// 解析响应
 	var result WhisperResponse
 	if err := json.Unmarshal(bodyBytes, &result); err != nil {
-		return "", fmt.Errorf("解析响应失败: %v, 响应内容: %s", err, string(bodyBytes))
+		return nil, fmt.Errorf("解析响应失败: %v, 响应内容: %s", err, string(bodyBytes))
 	}
 
-	logger.Infof("语音识别完成，语言: %s, 时长: %.2f秒", result.Language, result.Duration)
+	logger.Infof("语音识别完成，任务: %s, 语言: %s, 时长: %.2f秒, 分段数: %d",
+		w.task, result.Language, result.Duration, len(result.Segments))
 
-	return result.Text, nil
+	return result.toTranscript(), nil
+}
+
+// taskEndpoint 返回当前任务对应的接口地址，翻译任务使用 /audio/translations
+// 无法从配置的地址推导出翻译接口时返回错误，避免翻译任务被静默当作转写执行
+func (w *WhisperSTT) taskEndpoint() (string, error) {
+	if w.task != taskTranslate || strings.Contains(w.endpoint, "/audio/translations") {
+		return w.endpoint, nil
+	}
+	if !strings.Contains(w.endpoint, "/audio/transcriptions") {
+		return "", fmt.Errorf("翻译任务需要 /audio/transcriptions 或 /audio/translations 接口地址，当前为 %s", w.endpoint)
+	}
+	return strings.Replace(w.endpoint, "/audio/transcriptions", "/audio/translations", 1), nil
+}
+
+func (r *WhisperResponse) toTranscript() *models.Transcript {
+	transcript := &models.Transcript{
+		Text:     strings.TrimSpace(r.Text),
+		Language: r.Language,
+		Duration: r.Duration,
+	}
+	for _, seg := range r.Segments {
+		transcript.Segments = append(transcript.Segments, models.TranscriptSegment{
+			Start:      seg.Start,
+			End:        seg.End,
+			Text:       strings.TrimSpace(seg.Text),
+			AvgLogprob: seg.AvgLogprob,
+		})
+	}
+	return transcript
 }
 
 func (w *WhisperSTT) StreamRecognize(ctx context.Context, audioDataChan <-chan []byte,
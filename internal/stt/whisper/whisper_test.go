@@ -0,0 +1,138 @@
+package whisper
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+func newTestWhisper(endpoint string) *WhisperSTT {
+	return &WhisperSTT{
+		apiKey:   "key",
+		endpoint: endpoint,
+		model:    "whisper-v3-turbo",
+		vadModel: "silero",
+		task:     taskTranscribe,
+	}
+}
+
+func TestTaskEndpoint(t *testing.T) {
+	cases := []struct {
+		name     string
+		task     string
+		endpoint string
+		want     string
+		wantErr  bool
+	}{
+		{"转写使用原地址", taskTranscribe, "https://api/v1/audio/transcriptions", "https://api/v1/audio/transcriptions", false},
+		{"转写不检查地址", taskTranscribe, "https://api/v1/asr", "https://api/v1/asr", false},
+		{"翻译替换为 translations", taskTranslate, "https://api/v1/audio/transcriptions", "https://api/v1/audio/translations", false},
+		{"翻译直接使用 translations", taskTranslate, "https://api/v1/audio/translations", "https://api/v1/audio/translations", false},
+		{"翻译无法推导地址", taskTranslate, "https://api/v1/asr", "", true},
+	}
+	for _, tc := range cases {
+		w := newTestWhisper(tc.endpoint)
+		w.task = tc.task
+		endpoint, err := w.taskEndpoint()
+		if tc.wantErr {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.want, endpoint, tc.name)
+	}
+}
+
+func TestRecognizeDetailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/audio/transcriptions", r.URL.Path)
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, "whisper-v3-turbo", r.FormValue("model"))
+		assert.Equal(t, "silero", r.FormValue("vad_model"))
+		assert.Equal(t, "verbose_json", r.FormValue("response_format"))
+		// 会话指定的语言优先于配置
+		assert.Equal(t, "en", r.FormValue("language"))
+		_, header, err := r.FormFile("file")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "audio.ogg", header.Filename)
+		}
+		fmt.Fprint(w, `{"text":" hello world ","task":"transcribe","language":"english","duration":2.5,
+			"segments":[{"id":0,"start":0,"end":1.2,"text":" hello","avg_logprob":-0.2},
+			{"id":1,"start":1.2,"end":2.5,"text":" world ","avg_logprob":-0.4}]}`)
+	}))
+	defer server.Close()
+
+	w := newTestWhisper(server.URL + "/v1/audio/transcriptions")
+	w.language = "zh"
+	transcript, err := w.RecognizeDetailed([]byte("OggS audio"), "", models.RecognitionOptions{Language: "en"})
+	assert.NoError(t, err)
+	assert.Equal(t, &models.Transcript{
+		Text:     "hello world",
+		Language: "english",
+		Duration: 2.5,
+		Segments: []models.TranscriptSegment{
+			{Start: 0, End: 1.2, Text: "hello", AvgLogprob: -0.2},
+			{Start: 1.2, End: 2.5, Text: "world", AvgLogprob: -0.4},
+		},
+	}, transcript)
+}
+
+func TestRecognizeAutoLanguageAndUnknownContainer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		// auto 时交给服务端检测语言，不传 language
+		_, ok := r.MultipartForm.Value["language"]
+		assert.False(t, ok)
+		// 无法识别的封装格式沿用 mp3
+		file, header, err := r.FormFile("file")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "audio.mp3", header.Filename)
+			data, _ := io.ReadAll(file)
+			assert.Equal(t, "raw", string(data))
+		}
+		fmt.Fprint(w, `{"text":"你好"}`)
+	}))
+	defer server.Close()
+
+	w := newTestWhisper(server.URL + "/v1/audio/transcriptions")
+	w.language = "auto"
+	text, err := w.Recognize([]byte("raw"), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "你好", text)
+}
+
+func TestRecognizeTranslate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/audio/translations", r.URL.Path)
+		fmt.Fprint(w, `{"text":"hello","task":"translate"}`)
+	}))
+	defer server.Close()
+
+	w := newTestWhisper(server.URL + "/v1/audio/transcriptions")
+	w.task = taskTranslate
+	text, err := w.Recognize([]byte("RIFF"), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", text)
+
+	// 无法推导翻译接口时不发送请求
+	w.endpoint = server.URL + "/v1/asr"
+	_, err = w.Recognize([]byte("RIFF"), "")
+	assert.Error(t, err)
+}
+
+func TestRecognizeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid api key", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	_, err := newTestWhisper(server.URL).Recognize([]byte("RIFF"), "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+}
@@ -0,0 +1,105 @@
+// Package translate 提供可替换的文本翻译服务，默认使用 LLM 翻译
+package translate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/telepace/voiceflow/internal/llm"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+// Translator 将文本翻译为目标语言，source 为空时由翻译服务自动判断原文语言
+// 语言使用 BCP-47 代码，例如 zh-CN、en-US、ja-JP
+type Translator interface {
+	Translate(ctx context.Context, text, source, target string) (string, error)
+}
+
+// NewTranslator 根据配置创建翻译服务，目前支持 llm
+func NewTranslator(provider string, llmService llm.Service, model string) (Translator, error) {
+	switch provider {
+	case "", "llm":
+		return NewLLMTranslator(llmService, model), nil
+	default:
+		return nil, fmt.Errorf("不支持的翻译服务: %s", provider)
+	}
+}
+
+const translatePrompt = "你是专业的口译员。请把用户消息中的文本翻译为%s。" +
+	"译文要自然、口语化，适合直接朗读；保留人名、数字和专有名词的准确性。" +
+	"不要回答或评论其中的内容，不要添加解释，只输出译文。"
+
+// LLMTranslator 使用 LLM 翻译文本
+type LLMTranslator struct {
+	llm   llm.Service
+	model string
+}
+
+// NewLLMTranslator 创建基于 LLM 的翻译服务，model 为空时使用 LLM 提供商的默认模型
+func NewLLMTranslator(svc llm.Service, model string) *LLMTranslator {
+	return &LLMTranslator{llm: svc, model: model}
+}
+
+// Translate 实现 Translator 接口
+func (t *LLMTranslator) Translate(ctx context.Context, text, source, target string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", nil
+	}
+	if target == "" {
+		return "", fmt.Errorf("未指定目标语言")
+	}
+
+	system := fmt.Sprintf(translatePrompt, LanguageName(target))
+	if source != "" {
+		system += fmt.Sprintf("原文的语言是%s。", LanguageName(source))
+	}
+	reply, err := llm.Chat(ctx, t.llm, []models.ChatMessage{
+		{Role: models.RoleSystem, Content: system},
+		{Role: models.RoleUser, Content: text},
+	}, models.ChatOptions{Model: t.model})
+	if err != nil {
+		return "", fmt.Errorf("翻译失败: %v", err)
+	}
+	translated := strings.TrimSpace(reply.Content)
+	if translated == "" {
+		return "", fmt.Errorf("翻译结果为空")
+	}
+	return translated, nil
+}
+
+// languageNames 为常见语言代码对应的名称，写入提示词比语言代码更不容易被模型误解
+var languageNames = map[string]string{
+	"zh":    "简体中文",
+	"zh-cn": "简体中文",
+	"zh-tw": "繁体中文",
+	"zh-hk": "繁体中文（香港）",
+	"en":    "英语",
+	"ja":    "日语",
+	"ko":    "韩语",
+	"fr":    "法语",
+	"de":    "德语",
+	"es":    "西班牙语",
+	"it":    "意大利语",
+	"pt":    "葡萄牙语",
+	"ru":    "俄语",
+	"ar":    "阿拉伯语",
+	"th":    "泰语",
+	"vi":    "越南语",
+	"id":    "印尼语",
+}
+
+// LanguageName 返回语言代码对应的名称，未知的代码原样返回
+func LanguageName(code string) string {
+	key := strings.ToLower(strings.ReplaceAll(code, "_", "-"))
+	if name, ok := languageNames[key]; ok {
+		return name
+	}
+	if i := strings.Index(key, "-"); i > 0 {
+		if name, ok := languageNames[key[:i]]; ok {
+			return name
+		}
+	}
+	return code
+}
@@ -0,0 +1,44 @@
+package translate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+type fakeLLM struct {
+	messages []models.ChatMessage
+}
+
+func (f *fakeLLM) GetResponse(prompt string) (string, error) {
+	return "", nil
+}
+
+func (f *fakeLLM) Chat(ctx context.Context, messages []models.ChatMessage, opts models.ChatOptions) (models.ChatMessage, error) {
+	f.messages = messages
+	return models.ChatMessage{Role: models.RoleAssistant, Content: " Hello, world. "}, nil
+}
+
+func TestLLMTranslator(t *testing.T) {
+	svc := &fakeLLM{}
+	translator, err := NewTranslator("llm", svc, "")
+	assert.NoError(t, err)
+
+	text, err := translator.Translate(context.Background(), "你好，世界。", "zh-CN", "en-US")
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world.", text)
+	assert.Contains(t, svc.messages[0].Content, "英语")
+	assert.Contains(t, svc.messages[0].Content, "简体中文")
+	assert.Equal(t, "你好，世界。", svc.messages[1].Content)
+
+	_, err = NewTranslator("deepl", svc, "")
+	assert.Error(t, err)
+}
+
+func TestLanguageName(t *testing.T) {
+	assert.Equal(t, "日语", LanguageName("ja-JP"))
+	assert.Equal(t, "繁体中文", LanguageName("zh_TW"))
+	assert.Equal(t, "sw-KE", LanguageName("sw-KE"))
+}
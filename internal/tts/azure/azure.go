@@ -2,71 +2,333 @@ package azure
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"encoding/xml"
 	"fmt"
-	"github.com/telepace/voiceflow/pkg/config"
-	"github.com/telepace/voiceflow/pkg/logger"
 	"io"
 	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/tts/ssml"
+	"github.com/telepace/voiceflow/pkg/config"
+	"github.com/telepace/voiceflow/pkg/logger"
 )
 
+const (
+	defaultVoice        = "en-US-AriaNeural"
+	defaultLanguage     = "en-US"
+	defaultOutputFormat = "riff-24khz-16bit-mono-pcm"
+
+	// tokenTTL 访问令牌有效期为 10 分钟，提前刷新避免请求途中过期
+	tokenTTL = 9 * time.Minute
+)
+
+// outputFormats 将通用的输出格式映射为 X-Microsoft-OutputFormat 的取值
+var outputFormats = map[string]string{
+	models.AudioFormatWAV:     "riff-24khz-16bit-mono-pcm",
+	models.AudioFormatPCM:     "raw-24khz-16bit-mono-pcm",
+	models.AudioFormatMP3:     "audio-24khz-48kbitrate-mono-mp3",
+	models.AudioFormatOggOpus: "ogg-24khz-16bit-mono-opus",
+}
+
+// roles 为 mstts:express-as 支持的角色
+var roles = map[string]bool{
+	"Girl": true, "Boy": true,
+	"YoungAdultFemale": true, "YoungAdultMale": true,
+	"OlderAdultFemale": true, "OlderAdultMale": true,
+	"SeniorFemale": true, "SeniorMale": true,
+}
+
+var stylePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
 type AzureTTS struct {
-	apiKey    string
-	region    string
-	endpoint  string
-	voiceName string // 可以根据需要增加配置
+	apiKey       string
+	region       string
+	endpoint     string
+	tokenURL     string
+	voiceName    string
+	language     string
+	outputFormat string
+	style        string
+	styleDegree  float64
+	role         string
+	client       *http.Client
+
+	// 访问令牌缓存
+	tokenMu      sync.Mutex
+	token        string
+	tokenExpires time.Time
 }
 
 // NewAzureTTS 创建并返回一个新的 AzureTTS 实例
 func NewAzureTTS() *AzureTTS {
 	cfg, err := config.GetConfig()
 	if err != nil {
-		logger.Fatal("配置初始化失败: %v", err)
+		logger.Fatalf("配置初始化失败: %v", err)
+	}
+	ttsCfg := cfg.Azure.TTS
+	a := &AzureTTS{
+		apiKey:       cfg.Azure.TTSKey,
+		region:       cfg.Azure.Region,
+		endpoint:     fmt.Sprintf("https://%s.tts.speech.microsoft.com/cognitiveservices/v1", cfg.Azure.Region),
+		tokenURL:     fmt.Sprintf("https://%s.api.cognitive.microsoft.com/sts/v1.0/issueToken", cfg.Azure.Region),
+		voiceName:    ttsCfg.Voice,
+		language:     ttsCfg.Language,
+		outputFormat: ttsCfg.OutputFormat,
+		style:        ttsCfg.Style,
+		styleDegree:  ttsCfg.StyleDegree,
+		role:         ttsCfg.Role,
+		client:       &http.Client{Timeout: time.Minute},
 	}
-	return &AzureTTS{
-		apiKey:    cfg.Azure.TTSKey,
-		region:    cfg.Azure.Region,
-		endpoint:  fmt.Sprintf("https://%s.tts.speech.microsoft.com/cognitiveservices/v1", cfg.Azure.Region),
-		voiceName: "en-US-AriaNeural", // 设置默认语音，可以从配置文件读取
+	if a.voiceName == "" {
+		a.voiceName = defaultVoice
 	}
+	if a.language == "" {
+		a.language = defaultLanguage
+	}
+	if a.outputFormat == "" {
+		a.outputFormat = defaultOutputFormat
+	}
+	if err := checkStyle(a.style, a.styleDegree, a.role); err != nil {
+		logger.Fatalf("Azure TTS 配置错误: %v", err)
+	}
+	return a
 }
 
 // Synthesize 调用 Azure 的 TTS API，将文本转换为音频
-func (a *AzureTTS) Synthesize(text string) ([]byte, error) {
-	// 定义请求体
-	requestBody, err := json.Marshal(map[string]interface{}{
-		"text":      text,
-		"voiceName": a.voiceName,                 // 使用指定的语音
-		"locale":    "en-US",                     // 可以根据需要设置语言
-		"format":    "riff-16khz-16bit-mono-pcm", // Azure TTS 音频格式
-	})
+// 语速、音调和音量通过 SSML 的 prosody 元素设置，opts.TextType 为 ssml 时直接使用客户端的 SSML
+func (a *AzureTTS) Synthesize(text string, opts models.SynthesisOptions) ([]byte, error) {
+	return a.SynthesizeContext(context.Background(), text, opts)
+}
+
+// SynthesizeContext 实现 tts.ContextService 接口，ctx 取消时中止请求
+func (a *AzureTTS) SynthesizeContext(ctx context.Context, text string, opts models.SynthesisOptions) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	outputFormat := a.outputFormat
+	if opts.Format != "" {
+		format, ok := outputFormats[opts.Format]
+		if !ok {
+			return nil, models.UnsupportedOption("Azure TTS 不支持 %s 格式", opts.Format)
+		}
+		outputFormat = format
+	}
+	body, err := a.buildSSML(text, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// 创建 HTTP 请求
-	req, err := http.NewRequest("POST", a.endpoint, bytes.NewBuffer(requestBody))
+	audio, status, err := a.send(ctx, body, outputFormat)
+	if status == http.StatusUnauthorized {
+		// 令牌可能被提前吊销，刷新后重试一次
+		a.invalidateToken()
+		audio, _, err = a.send(ctx, body, outputFormat)
+	}
+	return audio, err
+}
+
+// send 发送 SSML 请求，返回音频数据和 HTTP 状态码
+func (a *AzureTTS) send(ctx context.Context, body, outputFormat string) ([]byte, int, error) {
+	token, err := a.accessToken()
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", a.endpoint, strings.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/ssml+xml")
+	req.Header.Set("X-Microsoft-OutputFormat", outputFormat)
+	req.Header.Set("User-Agent", "voiceflow")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, resp.StatusCode, fmt.Errorf("Azure TTS error(status=%d): %s", resp.StatusCode, string(respBody))
+	}
+
+	audio, err := io.ReadAll(resp.Body) // 返回音频数据
+	return audio, resp.StatusCode, err
+}
+
+// accessToken 返回缓存的访问令牌，过期后使用订阅密钥重新获取
+func (a *AzureTTS) accessToken() (string, error) {
+	a.tokenMu.Lock()
+	defer a.tokenMu.Unlock()
+
+	if a.token != "" && time.Now().Before(a.tokenExpires) {
+		return a.token, nil
 	}
 
-	// 设置请求头
+	req, err := http.NewRequest("POST", a.tokenURL, nil)
+	if err != nil {
+		return "", err
+	}
 	req.Header.Set("Ocp-Apim-Subscription-Key", a.apiKey)
-	req.Header.Set("Content-Type", "application/json")
 
-	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := a.client.Do(req)
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("获取 Azure 访问令牌失败: %v", err)
 	}
 	defer resp.Body.Close()
 
-	// 处理响应
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取 Azure 访问令牌失败: %v", err)
+	}
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Azure TTS error: %s", string(body))
+		return "", fmt.Errorf("获取 Azure 访问令牌失败(status=%d): %s", resp.StatusCode, string(body))
+	}
+
+	a.token = strings.TrimSpace(string(body))
+	a.tokenExpires = time.Now().Add(tokenTTL)
+	return a.token, nil
+}
+
+func (a *AzureTTS) invalidateToken() {
+	a.tokenMu.Lock()
+	defer a.tokenMu.Unlock()
+	a.token = ""
+}
+
+// buildSSML 生成请求使用的 SSML
+// 输入为 SSML 时原样使用其中的内容，文档未指定 voice 时套上配置的音色
+func (a *AzureTTS) buildSSML(text string, opts models.SynthesisOptions) (string, error) {
+	voice := a.voiceName
+	language := a.language
+	requestLanguage := opts.Language
+
+	var content string
+	if opts.IsSSML() {
+		doc, err := ssml.Parse(text)
+		if err != nil {
+			return "", err
+		}
+		if doc.HasVoice {
+			// 文档已经完整描述了音色和韵律，不再叠加请求参数
+			if opts.Voice != "" || opts.Language != "" || opts.Speed != 0 || opts.Pitch != 0 || opts.Volume != 0 || opts.HasStyle() {
+				return "", models.UnsupportedOption("SSML 中已包含 voice 元素时不能再指定 voice、language、speed、pitch、volume、style、role")
+			}
+			return doc.Raw, nil
+		}
+		if requestLanguage == "" {
+			requestLanguage = doc.Lang
+		}
+		content = doc.Body
+	} else {
+		content = escape(text)
 	}
 
-	return io.ReadAll(resp.Body) // 返回音频数据
+	if requestLanguage != "" && requestLanguage != a.language {
+		// Azure 的音色与语种绑定，切换语种时必须同时指定音色
+		if opts.Voice == "" {
+			return "", models.UnsupportedOption("Azure TTS 指定 language 时需要同时指定对应语种的 voice")
+		}
+		language = requestLanguage
+	}
+	if opts.Voice != "" {
+		voice = opts.Voice
+	}
+
+	if opts.Speed != 0 && (opts.Speed < 0.5 || opts.Speed > 2) {
+		return "", models.UnsupportedOption("Azure TTS 的 speed 取值范围为 0.5 到 2")
+	}
+	if opts.Pitch != 0 && (opts.Pitch < 0.5 || opts.Pitch > 1.5) {
+		return "", models.UnsupportedOption("Azure TTS 的 pitch 取值范围为 0.5 到 1.5")
+	}
+	if opts.Volume > 2 {
+		return "", models.UnsupportedOption("Azure TTS 的 volume 不能超过 2")
+	}
+
+	// prosody 使用相对当前音色的百分比变化
+	var prosody string
+	for _, attr := range []struct {
+		name  string
+		ratio float64
+	}{
+		{"rate", opts.Speed},
+		{"pitch", opts.Pitch},
+		{"volume", opts.Volume},
+	} {
+		if attr.ratio != 0 {
+			prosody += fmt.Sprintf(" %s='%+.0f%%'", attr.name, (attr.ratio-1)*100)
+		}
+	}
+	if prosody != "" {
+		content = fmt.Sprintf("<prosody%s>%s</prosody>", prosody, content)
+	}
+
+	// 请求中的风格参数覆盖配置的默认值
+	style, degree, role := a.style, a.styleDegree, a.role
+	if opts.HasStyle() {
+		style, degree, role = opts.Style, opts.StyleDegree, opts.Role
+	}
+	if err := checkStyle(style, degree, role); err != nil {
+		return "", err
+	}
+	if style != "" || role != "" {
+		var attrs string
+		if style != "" {
+			attrs += fmt.Sprintf(" style='%s'", style)
+		}
+		if degree != 0 {
+			attrs += fmt.Sprintf(" styledegree='%g'", degree)
+		}
+		if role != "" {
+			attrs += fmt.Sprintf(" role='%s'", role)
+		}
+		content = fmt.Sprintf("<mstts:express-as%s>%s</mstts:express-as>", attrs, content)
+	}
+
+	return fmt.Sprintf("<speak version='1.0' xmlns='http://www.w3.org/2001/10/synthesis' xmlns:mstts='https://www.w3.org/2001/mstts' xml:lang='%s'><voice name='%s'>%s</voice></speak>",
+		escape(language), escape(voice), content), nil
+}
+
+// checkStyle 校验说话风格参数，风格是否被具体音色支持由服务端判断
+func checkStyle(style string, degree float64, role string) error {
+	if style != "" && !stylePattern.MatchString(style) {
+		return models.UnsupportedOption("Azure TTS 的 style 格式错误: %s", style)
+	}
+	if degree != 0 {
+		if style == "" {
+			return models.UnsupportedOption("指定 style_degree 时需要同时指定 style")
+		}
+		if degree < 0.01 || degree > 2 {
+			return models.UnsupportedOption("Azure TTS 的 style_degree 取值范围为 0.01 到 2")
+		}
+	}
+	if role != "" && !roles[role] {
+		return models.UnsupportedOption("Azure TTS 不支持角色 %s", role)
+	}
+	return nil
+}
+
+func escape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// SynthesisDefaults 返回请求未指定时使用的配置，用于计算缓存键
+func (a *AzureTTS) SynthesisDefaults() map[string]string {
+	return map[string]string{
+		"voice":         a.voiceName,
+		"language":      a.language,
+		"output_format": a.outputFormat,
+		"style":         a.style,
+		"style_degree":  fmt.Sprint(a.styleDegree),
+		"role":          a.role,
+	}
 }
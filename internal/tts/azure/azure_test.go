@@ -0,0 +1,215 @@
+package azure
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+func newTestTTS() *AzureTTS {
+	return &AzureTTS{
+		apiKey:       "key",
+		voiceName:    defaultVoice,
+		language:     defaultLanguage,
+		outputFormat: defaultOutputFormat,
+		client:       http.DefaultClient,
+	}
+}
+
+// wrap 按 buildSSML 的格式包装 speak 和 voice 元素
+func wrap(language, voice, content string) string {
+	return fmt.Sprintf("<speak version='1.0' xmlns='http://www.w3.org/2001/10/synthesis' xmlns:mstts='https://www.w3.org/2001/mstts' xml:lang='%s'><voice name='%s'>%s</voice></speak>",
+		language, voice, content)
+}
+
+func TestBuildSSML(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		opts models.SynthesisOptions
+		want string
+	}{
+		{"转义纯文本", "a < b & 'c'", models.SynthesisOptions{},
+			wrap("en-US", "en-US-AriaNeural", "a &lt; b &amp; &#39;c&#39;")},
+		{"语速、音调和音量换算为百分比", "hi", models.SynthesisOptions{Speed: 1.5, Pitch: 0.8, Volume: 2},
+			wrap("en-US", "en-US-AriaNeural", "<prosody rate='+50%' pitch='-20%' volume='+100%'>hi</prosody>")},
+		{"说话风格", "hi", models.SynthesisOptions{Style: "cheerful", StyleDegree: 1.5, Role: "Girl"},
+			wrap("en-US", "en-US-AriaNeural", "<mstts:express-as style='cheerful' styledegree='1.5' role='Girl'>hi</mstts:express-as>")},
+		{"风格包在韵律外层", "hi", models.SynthesisOptions{Speed: 2, Style: "sad"},
+			wrap("en-US", "en-US-AriaNeural", "<mstts:express-as style='sad'><prosody rate='+100%'>hi</prosody></mstts:express-as>")},
+		{"切换语种和音色", "你好", models.SynthesisOptions{Language: "zh-CN", Voice: "zh-CN-XiaoxiaoNeural"},
+			wrap("zh-CN", "zh-CN-XiaoxiaoNeural", "你好")},
+		{"SSML 没有 voice 时套上配置的音色", `<speak version="1.0" xml:lang="en-US">hi<break time="1s"/>there</speak>`,
+			models.SynthesisOptions{TextType: models.TextTypeSSML, Speed: 0.5},
+			wrap("en-US", "en-US-AriaNeural", `<prosody rate='-50%'>hi<break time="1s"/>there</prosody>`)},
+		{"SSML 的语种和请求的音色", `<speak xml:lang="zh-CN">你好</speak>`,
+			models.SynthesisOptions{TextType: models.TextTypeSSML, Voice: "zh-CN-YunxiNeural"},
+			wrap("zh-CN", "zh-CN-YunxiNeural", "你好")},
+		{"SSML 带有 voice 时原样使用", `<speak version="1.0" xml:lang="zh-CN"><voice name="zh-CN-XiaoxiaoNeural">你好</voice></speak>`,
+			models.SynthesisOptions{TextType: models.TextTypeSSML},
+			`<speak version="1.0" xml:lang="zh-CN"><voice name="zh-CN-XiaoxiaoNeural">你好</voice></speak>`},
+	}
+	a := newTestTTS()
+	for _, tc := range cases {
+		got, err := a.buildSSML(tc.text, tc.opts)
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.want, got, tc.name)
+	}
+}
+
+func TestBuildSSMLConfiguredStyle(t *testing.T) {
+	a := newTestTTS()
+	a.style, a.styleDegree = "chat", 0.5
+
+	got, err := a.buildSSML("hi", models.SynthesisOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, wrap("en-US", "en-US-AriaNeural", "<mstts:express-as style='chat' styledegree='0.5'>hi</mstts:express-as>"), got)
+
+	// 请求中的风格参数覆盖配置
+	got, err = a.buildSSML("hi", models.SynthesisOptions{Role: "Boy"})
+	assert.NoError(t, err)
+	assert.Equal(t, wrap("en-US", "en-US-AriaNeural", "<mstts:express-as role='Boy'>hi</mstts:express-as>"), got)
+}
+
+func TestBuildSSMLRejected(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		opts models.SynthesisOptions
+	}{
+		{"指定语种但没有音色", "hi", models.SynthesisOptions{Language: "zh-CN"}},
+		{"SSML 的语种没有对应音色", `<speak xml:lang="zh-CN">你好</speak>`, models.SynthesisOptions{TextType: models.TextTypeSSML}},
+		{"SSML 带有 voice 时不能再指定参数", `<speak><voice name="a">hi</voice></speak>`,
+			models.SynthesisOptions{TextType: models.TextTypeSSML, Speed: 1.2}},
+		{"SSML 格式错误", `<speak>hi`, models.SynthesisOptions{TextType: models.TextTypeSSML}},
+		{"语速超出范围", "hi", models.SynthesisOptions{Speed: 3}},
+		{"音调超出范围", "hi", models.SynthesisOptions{Pitch: 2}},
+		{"音量超出范围", "hi", models.SynthesisOptions{Volume: 3}},
+		{"风格格式错误", "hi", models.SynthesisOptions{Style: "Cheerful!"}},
+		{"style_degree 需要 style", "hi", models.SynthesisOptions{StyleDegree: 1}},
+		{"style_degree 超出范围", "hi", models.SynthesisOptions{Style: "sad", StyleDegree: 3}},
+		{"未知角色", "hi", models.SynthesisOptions{Role: "Robot"}},
+	}
+	a := newTestTTS()
+	for _, tc := range cases {
+		_, err := a.buildSSML(tc.text, tc.opts)
+		assert.ErrorIs(t, err, models.ErrUnsupportedOption, tc.name)
+	}
+}
+
+// fakeAzure 模拟令牌接口和合成接口，unauthorized 为合成接口需要先返回 401 的次数
+type fakeAzure struct {
+	server       *httptest.Server
+	tokens       atomic.Int32
+	unauthorized atomic.Int32
+	formats      chan string
+}
+
+func newFakeAzure(t *testing.T) *fakeAzure {
+	f := &fakeAzure{formats: make(chan string, 8)}
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sts/v1.0/issueToken":
+			assert.Equal(t, "key", r.Header.Get("Ocp-Apim-Subscription-Key"))
+			fmt.Fprintf(w, "token-%d\n", f.tokens.Add(1))
+		case "/cognitiveservices/v1":
+			assert.Equal(t, "application/ssml+xml", r.Header.Get("Content-Type"))
+			if f.unauthorized.Add(-1) >= 0 {
+				http.Error(w, "token revoked", http.StatusUnauthorized)
+				return
+			}
+			// 使用最近一次获取的令牌
+			assert.Equal(t, fmt.Sprintf("Bearer token-%d", f.tokens.Load()), r.Header.Get("Authorization"))
+			f.formats <- r.Header.Get("X-Microsoft-OutputFormat")
+			body, _ := io.ReadAll(r.Body)
+			w.Write(body)
+		default:
+			t.Errorf("未预期的请求: %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeAzure) tts() *AzureTTS {
+	a := newTestTTS()
+	a.endpoint = f.server.URL + "/cognitiveservices/v1"
+	a.tokenURL = f.server.URL + "/sts/v1.0/issueToken"
+	return a
+}
+
+func TestSynthesizeOutputFormat(t *testing.T) {
+	f := newFakeAzure(t)
+	a := f.tts()
+
+	cases := []struct {
+		format string
+		want   string
+	}{
+		{"", defaultOutputFormat},
+		{models.AudioFormatWAV, "riff-24khz-16bit-mono-pcm"},
+		{models.AudioFormatPCM, "raw-24khz-16bit-mono-pcm"},
+		{models.AudioFormatMP3, "audio-24khz-48kbitrate-mono-mp3"},
+		{models.AudioFormatOggOpus, "ogg-24khz-16bit-mono-opus"},
+	}
+	for _, tc := range cases {
+		data, err := a.Synthesize("hi", models.SynthesisOptions{Format: tc.format})
+		assert.NoError(t, err, tc.format)
+		assert.Equal(t, wrap("en-US", "en-US-AriaNeural", "hi"), string(data), tc.format)
+		assert.Equal(t, tc.want, <-f.formats, tc.format)
+	}
+	// 令牌在有效期内复用
+	assert.Equal(t, int32(1), f.tokens.Load())
+
+	_, err := a.Synthesize("hi", models.SynthesisOptions{Format: "flac"})
+	assert.ErrorIs(t, err, models.ErrUnsupportedOption)
+}
+
+func TestAccessTokenRefresh(t *testing.T) {
+	f := newFakeAzure(t)
+	a := f.tts()
+
+	_, err := a.Synthesize("hi", models.SynthesisOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), f.tokens.Load())
+
+	// 令牌过期后重新获取
+	a.tokenExpires = time.Now().Add(-time.Second)
+	_, err = a.Synthesize("hi", models.SynthesisOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), f.tokens.Load())
+
+	// 令牌被提前吊销时刷新后重试一次
+	f.unauthorized.Store(1)
+	_, err = a.Synthesize("hi", models.SynthesisOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), f.tokens.Load())
+
+	// 重试仍然失败时返回错误
+	f.unauthorized.Store(2)
+	_, err = a.Synthesize("hi", models.SynthesisOptions{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "status=401")
+	assert.Equal(t, int32(4), f.tokens.Load())
+}
+
+func TestAccessTokenError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid subscription key", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	a := newTestTTS()
+	a.endpoint = server.URL + "/cognitiveservices/v1"
+	a.tokenURL = server.URL + "/sts/v1.0/issueToken"
+	_, err := a.Synthesize("hi", models.SynthesisOptions{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid subscription key")
+}
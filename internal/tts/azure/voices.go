@@ -0,0 +1,73 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+// voiceInfo 对应 voices/list 接口返回的音色信息
+type voiceInfo struct {
+	ShortName           string   `json:"ShortName"`
+	DisplayName         string   `json:"DisplayName"`
+	LocalName           string   `json:"LocalName"`
+	Gender              string   `json:"Gender"`
+	Locale              string   `json:"Locale"`
+	SecondaryLocaleList []string `json:"SecondaryLocaleList"`
+	StyleList           []string `json:"StyleList"`
+	RolePlayList        []string `json:"RolePlayList"`
+	SampleRateHertz     string   `json:"SampleRateHertz"`
+}
+
+// ListVoices 调用 voices/list 接口获取当前区域可用的音色
+func (a *AzureTTS) ListVoices(ctx context.Context) ([]models.Voice, error) {
+	endpoint := fmt.Sprintf("https://%s.tts.speech.microsoft.com/cognitiveservices/voices/list", a.region)
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Ocp-Apim-Subscription-Key", a.apiKey)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("获取 Azure 音色列表失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("获取 Azure 音色列表失败(status=%d): %s", resp.StatusCode, string(body))
+	}
+
+	var infos []voiceInfo
+	if err := json.NewDecoder(resp.Body).Decode(&infos); err != nil {
+		return nil, fmt.Errorf("解析 Azure 音色列表失败: %v", err)
+	}
+
+	voices := make([]models.Voice, 0, len(infos))
+	for _, info := range infos {
+		name := info.LocalName
+		if name == "" {
+			name = info.DisplayName
+		}
+		sampleRate, _ := strconv.Atoi(info.SampleRateHertz)
+		voices = append(voices, models.Voice{
+			ID:         info.ShortName,
+			Name:       name,
+			Provider:   "azure",
+			Language:   info.Locale,
+			Languages:  info.SecondaryLocaleList,
+			Gender:     strings.ToLower(info.Gender),
+			Styles:     info.StyleList,
+			Roles:      info.RolePlayList,
+			SampleRate: sampleRate,
+		})
+	}
+	return voices, nil
+}
@@ -0,0 +1,263 @@
+// Package cache 按内容寻址缓存合成后的音频，相同的提供商、参数和文本直接复用已存储的音频
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/storage"
+	"github.com/telepace/voiceflow/internal/tts"
+	"github.com/telepace/voiceflow/internal/utils"
+	"github.com/telepace/voiceflow/pkg/logger"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// objectPrefix 缓存音频在存储中的目录
+	objectPrefix = "tts-cache/"
+	// memoryTTL 内存中 URL 的有效期，需要小于预签名 URL 的有效期
+	memoryTTL = storage.PresignExpiry / 2
+	// defaultMemoryEntries 内存缓存默认保存的条目数
+	defaultMemoryEntries = 1024
+)
+
+// 命中的缓存层级
+const (
+	TierMemory  = "memory"
+	TierStorage = "storage"
+)
+
+// Options 控制启用哪些缓存层
+type Options struct {
+	Enabled       bool
+	MemoryEntries int  // 内存 LRU 的容量，0 使用默认值，负数表示不使用内存层
+	Storage       bool // 是否在存储中按内容哈希查找已合成的音频
+}
+
+// Result 为一次合成请求的结果
+type Result struct {
+	AudioURL string
+	// Audio 在未命中缓存、实际调用了提供商时返回，LookupAudio 命中时为已存储的音频
+	Audio []byte
+	// Tier 为命中的缓存层，未命中时为空
+	Tier string
+}
+
+// Cached 判断结果是否来自缓存
+func (r *Result) Cached() bool {
+	return r.Tier != ""
+}
+
+// Stats 为缓存命中统计
+type Stats struct {
+	Enabled       bool    `json:"enabled"`
+	Requests      int64   `json:"requests"`
+	MemoryHits    int64   `json:"memory_hits"`
+	StorageHits   int64   `json:"storage_hits"`
+	Misses        int64   `json:"misses"`
+	HitRate       float64 `json:"hit_rate"`
+	MemoryEntries int     `json:"memory_entries"`
+}
+
+// Cache 包装 TTS 服务和存储服务，未启用时直接合成并存储
+type Cache struct {
+	provider string
+	defaults []byte // 提供商的默认合成参数，参与缓存键的计算
+	tts      tts.Service
+	storage  storage.Service
+	enabled  bool
+	memory   *lru // 不使用内存层时为 nil
+	useStore bool
+	// inflight 合并同一缓存键上并发的合成，避免重复调用提供商和重复存储
+	inflight singleflight.Group
+
+	memoryHits  atomic.Int64
+	storageHits atomic.Int64
+	misses      atomic.Int64
+}
+
+// New 创建 TTS 缓存，provider 和提供商的默认合成参数参与缓存键的计算，
+// 切换提供商或修改默认音色、编码后不会命中旧的音频
+func New(provider string, svc tts.Service, store storage.Service, opts Options) *Cache {
+	// map 按键排序序列化，结果稳定
+	defaults, _ := json.Marshal(tts.SynthesisDefaults(svc))
+	c := &Cache{
+		provider: provider,
+		defaults: defaults,
+		tts:      svc,
+		storage:  store,
+		enabled:  opts.Enabled,
+		useStore: opts.Enabled && opts.Storage,
+	}
+	if opts.Enabled && opts.MemoryEntries >= 0 {
+		entries := opts.MemoryEntries
+		if entries == 0 {
+			entries = defaultMemoryEntries
+		}
+		c.memory = newLRU(entries, memoryTTL)
+	}
+	return c
+}
+
+// Key 根据提供商、默认合成参数、请求的合成参数和文本计算缓存键
+func (c *Cache) Key(text string, opts models.SynthesisOptions) string {
+	params, _ := json.Marshal(opts)
+	data := make([]byte, 0, len(c.provider)+len(c.defaults)+len(params)+len(text)+3)
+	data = append(data, c.provider...)
+	data = append(data, 0)
+	data = append(data, c.defaults...)
+	data = append(data, 0)
+	data = append(data, params...)
+	data = append(data, 0)
+	data = append(data, text...)
+	return utils.HashData(data)
+}
+
+// Lookup 依次查询内存和存储，命中时返回已存储音频的 URL 和命中的层级
+func (c *Cache) Lookup(text string, opts models.SynthesisOptions) (string, string, bool) {
+	// 无效的参数由提供商返回错误，不计入未命中
+	if !c.enabled || opts.Validate() != nil {
+		return "", "", false
+	}
+	key := c.Key(text, opts)
+
+	if c.memory != nil {
+		if url, ok := c.memory.get(key); ok {
+			c.memoryHits.Add(1)
+			return url, TierMemory, true
+		}
+	}
+	if c.useStore {
+		url, found, err := c.storage.LookupAudio(objectPrefix + key)
+		if err != nil {
+			logger.Warnf("查询 TTS 缓存失败，按未命中处理: %v", err)
+		} else if found {
+			c.storageHits.Add(1)
+			if c.memory != nil {
+				c.memory.add(key, url)
+			}
+			return url, TierStorage, true
+		}
+	}
+
+	c.misses.Add(1)
+	return "", "", false
+}
+
+// LookupAudio 与 Lookup 相同，命中时同时读取已存储的音频，用于需要逐帧下发音频的流式请求
+// 只有存储层按内容寻址保存音频，未启用存储层或读取失败时按未命中处理
+func (c *Cache) LookupAudio(text string, opts models.SynthesisOptions) (*Result, bool) {
+	url, tier, ok := c.Lookup(text, opts)
+	if !ok || !c.useStore {
+		return nil, false
+	}
+	audio, found, err := c.storage.LoadAudio(objectPrefix + c.Key(text, opts))
+	if err != nil || !found {
+		logger.Warnf("读取缓存音频失败，按未命中处理: %v", err)
+		return nil, false
+	}
+	return &Result{AudioURL: url, Audio: audio, Tier: tier}, true
+}
+
+// Store 存储新合成的音频并写入缓存，未启用缓存时等同于 storage.StoreAudio
+func (c *Cache) Store(text string, opts models.SynthesisOptions, audio []byte) (string, error) {
+	if !c.enabled {
+		return c.storage.StoreAudio(audio)
+	}
+
+	key := c.Key(text, opts)
+	var (
+		url string
+		err error
+	)
+	if c.useStore {
+		url, err = c.storage.StoreAudioAs(objectPrefix+key, audio)
+	} else {
+		url, err = c.storage.StoreAudio(audio)
+	}
+	if err != nil {
+		return "", err
+	}
+	if c.memory != nil {
+		c.memory.add(key, url)
+	}
+	return url, nil
+}
+
+// Synthesize 命中缓存时直接返回已存储音频的 URL，否则合成并存储
+func (c *Cache) Synthesize(text string, opts models.SynthesisOptions) (*Result, error) {
+	return c.SynthesizeContext(context.Background(), text, opts)
+}
+
+// SynthesizeContext 与 Synthesize 相同，ctx 取消时中止合成并返回 ctx 的错误
+// 启用缓存时，同一缓存键上并发的未命中只调用一次提供商，结果由各请求共享
+func (c *Cache) SynthesizeContext(ctx context.Context, text string, opts models.SynthesisOptions) (*Result, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if url, tier, ok := c.Lookup(text, opts); ok {
+		return &Result{AudioURL: url, Tier: tier}, nil
+	}
+	if !c.enabled {
+		return c.synthesize(ctx, text, opts)
+	}
+
+	key := c.Key(text, opts)
+	for {
+		// 合成使用发起请求的 ctx，其余请求只等待结果
+		results := c.inflight.DoChan(key, func() (interface{}, error) {
+			return c.synthesize(ctx, text, opts)
+		})
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case r := <-results:
+			if r.Err != nil && ctx.Err() == nil && isCanceled(r.Err) {
+				// 发起合成的请求被取消，当前请求重新合成
+				continue
+			}
+			if r.Err != nil {
+				return nil, r.Err
+			}
+			return r.Val.(*Result), nil
+		}
+	}
+}
+
+// synthesize 调用提供商合成并存储音频
+func (c *Cache) synthesize(ctx context.Context, text string, opts models.SynthesisOptions) (*Result, error) {
+	audio, err := tts.SynthesizeContext(ctx, c.tts, text, opts)
+	if err != nil {
+		return nil, err
+	}
+	url, err := c.Store(text, opts, audio)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{AudioURL: url, Audio: audio}, nil
+}
+
+func isCanceled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Stats 返回自启动以来的命中统计
+func (c *Cache) Stats() Stats {
+	stats := Stats{
+		Enabled:     c.enabled,
+		MemoryHits:  c.memoryHits.Load(),
+		StorageHits: c.storageHits.Load(),
+		Misses:      c.misses.Load(),
+	}
+	stats.Requests = stats.MemoryHits + stats.StorageHits + stats.Misses
+	if stats.Requests > 0 {
+		stats.HitRate = float64(stats.MemoryHits+stats.StorageHits) / float64(stats.Requests)
+	}
+	if c.memory != nil {
+		stats.MemoryEntries = c.memory.len()
+	}
+	return stats
+}
@@ -0,0 +1,252 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+type fakeTTS struct {
+	calls int
+}
+
+func (f *fakeTTS) Synthesize(text string, opts models.SynthesisOptions) ([]byte, error) {
+	f.calls++
+	return []byte("audio:" + text), nil
+}
+
+type fakeStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	stores  int
+}
+
+func (f *fakeStorage) StoreAudio(audioData []byte) (string, error) {
+	f.mu.Lock()
+	name := fmt.Sprintf("random-%d", len(f.objects))
+	f.mu.Unlock()
+	return f.StoreAudioAs(name, audioData)
+}
+
+func (f *fakeStorage) StoreAudioAs(name string, audioData []byte) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stores++
+	f.objects[name] = audioData
+	return "url://" + name, nil
+}
+
+func (f *fakeStorage) LookupAudio(name string) (string, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.objects[name]; ok {
+		return "url://" + name, true, nil
+	}
+	return "", false, nil
+}
+
+func (f *fakeStorage) LoadAudio(name string) ([]byte, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[name]
+	return data, ok, nil
+}
+
+func TestCacheTiers(t *testing.T) {
+	svc := &fakeTTS{}
+	store := &fakeStorage{objects: map[string][]byte{}}
+	opts := Options{Enabled: true, MemoryEntries: 2, Storage: true}
+	c := New("volcengine", svc, store, opts)
+
+	first, err := c.Synthesize("你好", models.SynthesisOptions{})
+	assert.NoError(t, err)
+	assert.False(t, first.Cached())
+	assert.Equal(t, []byte("audio:你好"), first.Audio)
+
+	second, err := c.Synthesize("你好", models.SynthesisOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, TierMemory, second.Tier)
+	assert.Equal(t, first.AudioURL, second.AudioURL)
+
+	// 参数不同视为不同的音频
+	_, err = c.Synthesize("你好", models.SynthesisOptions{Voice: "other"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, svc.calls)
+
+	// 新实例没有内存缓存，仍然能从存储中命中
+	restarted := New("volcengine", svc, store, opts)
+	third, err := restarted.Synthesize("你好", models.SynthesisOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, TierStorage, third.Tier)
+	assert.Equal(t, first.AudioURL, third.AudioURL)
+	assert.Equal(t, 2, svc.calls)
+
+	// 不同提供商不共享缓存
+	_, err = New("azure", svc, store, opts).Synthesize("你好", models.SynthesisOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, svc.calls)
+
+	stats := c.Stats()
+	assert.Equal(t, int64(3), stats.Requests)
+	assert.Equal(t, int64(1), stats.MemoryHits)
+	assert.Equal(t, int64(2), stats.Misses)
+	assert.InDelta(t, 1.0/3, stats.HitRate, 1e-9)
+	assert.Equal(t, 2, stats.MemoryEntries)
+}
+
+func TestLRUEviction(t *testing.T) {
+	l := newLRU(2, memoryTTL)
+	l.add("a", "1")
+	l.add("b", "2")
+	l.get("a")
+	l.add("c", "3")
+
+	_, ok := l.get("b")
+	assert.False(t, ok)
+	url, ok := l.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", url)
+}
+
+type configuredTTS struct {
+	fakeTTS
+	voice string
+}
+
+func (c *configuredTTS) SynthesisDefaults() map[string]string {
+	return map[string]string{"voice": c.voice}
+}
+
+func TestKeyIncludesProviderDefaults(t *testing.T) {
+	store := &fakeStorage{objects: map[string][]byte{}}
+	opts := Options{Enabled: true, Storage: true}
+	before := New("volcengine", &configuredTTS{voice: "BV001"}, store, opts)
+	after := New("volcengine", &configuredTTS{voice: "BV002"}, store, opts)
+	same := New("volcengine", &configuredTTS{voice: "BV001"}, store, opts)
+
+	assert.NotEqual(t, before.Key("你好", models.SynthesisOptions{}), after.Key("你好", models.SynthesisOptions{}))
+	assert.Equal(t, before.Key("你好", models.SynthesisOptions{}), same.Key("你好", models.SynthesisOptions{}))
+}
+
+func TestLookupAudio(t *testing.T) {
+	store := &fakeStorage{objects: map[string][]byte{}}
+	c := New("volcengine", &fakeTTS{}, store, Options{Enabled: true, Storage: true})
+
+	_, ok := c.LookupAudio("你好", models.SynthesisOptions{})
+	assert.False(t, ok)
+
+	_, err := c.Synthesize("你好", models.SynthesisOptions{})
+	assert.NoError(t, err)
+	cached, ok := c.LookupAudio("你好", models.SynthesisOptions{})
+	assert.True(t, ok)
+	assert.Equal(t, TierMemory, cached.Tier)
+	assert.Equal(t, []byte("audio:你好"), cached.Audio)
+
+	// 只有内存层时无法取回音频
+	memoryOnly := New("volcengine", &fakeTTS{}, store, Options{Enabled: true})
+	_, err = memoryOnly.Synthesize("你好", models.SynthesisOptions{})
+	assert.NoError(t, err)
+	_, ok = memoryOnly.LookupAudio("你好", models.SynthesisOptions{})
+	assert.False(t, ok)
+}
+
+// gatedTTS 在 release 关闭前不返回，用于构造并发的未命中
+type gatedTTS struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (g *gatedTTS) Synthesize(text string, opts models.SynthesisOptions) ([]byte, error) {
+	g.calls.Add(1)
+	<-g.release
+	return []byte("audio:" + text), nil
+}
+
+func TestConcurrentMissesSynthesizeOnce(t *testing.T) {
+	svc := &gatedTTS{release: make(chan struct{})}
+	store := &fakeStorage{objects: map[string][]byte{}}
+	c := New("volcengine", svc, store, Options{Enabled: true, Storage: true})
+
+	const requests = 5
+	var wg sync.WaitGroup
+	results := make([]*Result, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := c.Synthesize("欢迎使用", models.SynthesisOptions{})
+			assert.NoError(t, err)
+			results[i] = result
+		}(i)
+	}
+	// 全部请求都未命中后再让合成返回
+	assert.Eventually(t, func() bool { return c.Stats().Misses == requests }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	close(svc.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), svc.calls.Load())
+	assert.Equal(t, 1, store.stores)
+	for _, result := range results {
+		assert.Equal(t, results[0].AudioURL, result.AudioURL)
+		assert.Equal(t, []byte("audio:欢迎使用"), result.Audio)
+	}
+}
+
+func TestInvalidOptionsNotCountedAsMiss(t *testing.T) {
+	svc := &fakeTTS{}
+	c := New("volcengine", svc, &fakeStorage{objects: map[string][]byte{}}, Options{Enabled: true})
+
+	_, err := c.Synthesize("你好", models.SynthesisOptions{Speed: -1})
+	assert.ErrorIs(t, err, models.ErrUnsupportedOption)
+	_, _, ok := c.Lookup("你好", models.SynthesisOptions{Format: "flac"})
+	assert.False(t, ok)
+
+	assert.Equal(t, 0, svc.calls)
+	assert.Equal(t, int64(0), c.Stats().Misses)
+	assert.Equal(t, int64(0), c.Stats().Requests)
+}
+
+func TestSynthesizeContextCanceled(t *testing.T) {
+	svc := &gatedTTS{release: make(chan struct{})}
+	store := &fakeStorage{objects: map[string][]byte{}}
+	c := New("volcengine", svc, store, Options{Enabled: true, Storage: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := c.SynthesizeContext(ctx, "欢迎使用", models.SynthesisOptions{})
+		canceled <- err
+	}()
+	// 可取消的请求先发起合成，之后的请求等待它的结果
+	assert.Eventually(t, func() bool { return svc.calls.Load() == 1 }, time.Second, time.Millisecond)
+	waiting := make(chan *Result, 1)
+	go func() {
+		result, err := c.Synthesize("欢迎使用", models.SynthesisOptions{})
+		assert.NoError(t, err)
+		waiting <- result
+	}()
+	assert.Eventually(t, func() bool { return c.Stats().Misses == 2 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	// 发起合成的请求被取消后立即返回，等待同一结果的请求重新合成
+	cancel()
+	select {
+	case err := <-canceled:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("取消后合成未返回")
+	}
+	assert.Eventually(t, func() bool { return svc.calls.Load() == 2 }, time.Second, time.Millisecond)
+	close(svc.release)
+
+	result := <-waiting
+	assert.Equal(t, []byte("audio:欢迎使用"), result.Audio)
+	assert.Equal(t, 1, store.stores)
+}
@@ -2,18 +2,66 @@ package google
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/telepace/voiceflow/pkg/config"
-	"github.com/telepace/voiceflow/pkg/logger"
 	"io"
+	"math"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/tts/ssml"
+	"github.com/telepace/voiceflow/pkg/config"
+	"github.com/telepace/voiceflow/pkg/logger"
 )
 
+// synthesizeEndpoint 为 Google Text-to-Speech 的合成接口
+const synthesizeEndpoint = "https://texttospeech.googleapis.com/v1/text:synthesize"
+
+// encodings 将通用的输出格式映射为 Google 的 audioEncoding，LINEAR16 返回带文件头的 WAV
+var encodings = map[string]string{
+	models.AudioFormatWAV:     "LINEAR16",
+	models.AudioFormatMP3:     "MP3",
+	models.AudioFormatOggOpus: "OGG_OPUS",
+}
+
 type GoogleTTS struct {
-	apiKey string
-	voice  string
-	lang   string
+	apiKey     string
+	voice      string
+	lang       string
+	encoding   string
+	sampleRate int
+	effects    []string
+	endpoint   string
+	client     *http.Client
+}
+
+type synthesizeRequest struct {
+	Input struct {
+		Text string `json:"text,omitempty"`
+		SSML string `json:"ssml,omitempty"`
+	} `json:"input"`
+	Voice struct {
+		LanguageCode string `json:"languageCode"`
+		Name         string `json:"name,omitempty"`
+	} `json:"voice"`
+	AudioConfig audioConfig `json:"audioConfig"`
+}
+
+type synthesizeResponse struct {
+	// AudioContent 在 JSON 中为 base64 编码，解码由 encoding/json 完成
+	AudioContent []byte `json:"audioContent"`
+}
+
+type audioConfig struct {
+	AudioEncoding    string   `json:"audioEncoding"`
+	SampleRateHertz  int      `json:"sampleRateHertz,omitempty"`
+	EffectsProfileID []string `json:"effectsProfileId,omitempty"`
+	SpeakingRate     float64  `json:"speakingRate,omitempty"`
+	Pitch            float64  `json:"pitch,omitempty"`        // 半音，-20 到 20
+	VolumeGainDb     float64  `json:"volumeGainDb,omitempty"` // 分贝，-96 到 16
 }
 
 // NewGoogleTTS 创建并返回一个新的 GoogleTTS 实例
@@ -22,41 +70,64 @@ func NewGoogleTTS() *GoogleTTS {
 	if err != nil {
 		logger.Fatalf("配置初始化失败: %v", err)
 	}
-	return &GoogleTTS{
-		apiKey: cfg.Google.TTSKey,
-		voice:  "en-US-Wavenet-D", // 默认的 Google TTS 语音
-		lang:   "en-US",
+	ttsCfg := cfg.Google.TTS
+	g := &GoogleTTS{
+		apiKey:     cfg.Google.TTSKey,
+		voice:      ttsCfg.Voice,
+		lang:       ttsCfg.LanguageCode,
+		encoding:   ttsCfg.AudioEncoding,
+		sampleRate: ttsCfg.SampleRateHertz,
+		effects:    ttsCfg.EffectsProfileID,
+		endpoint:   synthesizeEndpoint,
+		client:     &http.Client{Timeout: time.Minute},
+	}
+	if g.voice == "" {
+		g.voice = "en-US-Wavenet-D" // 默认的 Google TTS 语音
+	}
+	if g.lang == "" {
+		g.lang = "en-US"
+	}
+	if g.encoding, err = checkEncoding(g.encoding); err != nil {
+		logger.Fatalf("%v", err)
+	}
+	return g
+}
+
+// checkEncoding 校验配置的输出编码，未配置时使用 LINEAR16
+func checkEncoding(encoding string) (string, error) {
+	switch encoding {
+	case "":
+		return "LINEAR16", nil
+	case "LINEAR16", "MP3", "OGG_OPUS":
+		return encoding, nil
+	default:
+		return "", fmt.Errorf("不支持的 Google TTS 编码: %s，可选值为 LINEAR16、MP3、OGG_OPUS", encoding)
 	}
 }
 
 // Synthesize 调用 Google TTS API 将文本转换为音频
-func (g *GoogleTTS) Synthesize(text string) ([]byte, error) {
-	requestBody, err := json.Marshal(map[string]interface{}{
-		"input": map[string]string{
-			"text": text,
-		},
-		"voice": map[string]string{
-			"languageCode": g.lang,
-			"name":         g.voice,
-		},
-		"audioConfig": map[string]string{
-			"audioEncoding": "LINEAR16", // 设置音频格式为 LINEAR16
-		},
-	})
+func (g *GoogleTTS) Synthesize(text string, opts models.SynthesisOptions) ([]byte, error) {
+	return g.SynthesizeContext(context.Background(), text, opts)
+}
+
+// SynthesizeContext 实现 tts.ContextService 接口，ctx 取消时中止请求
+func (g *GoogleTTS) SynthesizeContext(ctx context.Context, text string, opts models.SynthesisOptions) ([]byte, error) {
+	request, err := g.buildRequest(text, opts)
+	if err != nil {
+		return nil, err
+	}
+	requestBody, err := json.Marshal(request)
 	if err != nil {
 		return nil, err
 	}
 
-	endpoint := "https://texttospeech.googleapis.com/v1/text:synthesize?key=" + g.apiKey
-
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", g.endpoint+"?key="+g.apiKey, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := g.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -67,15 +138,87 @@ func (g *GoogleTTS) Synthesize(text string) ([]byte, error) {
 		return nil, fmt.Errorf("Google TTS error: %s", string(body))
 	}
 
-	var result map[string]string
+	var result synthesizeResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("解析 Google TTS 响应失败: %v", err)
+	}
+	if len(result.AudioContent) == 0 {
+		return nil, fmt.Errorf("Google TTS 未返回音频数据")
 	}
+	return result.AudioContent, nil
+}
 
-	audioData, err := io.ReadAll(bytes.NewReader([]byte(result["audioContent"])))
-	if err != nil {
+// buildRequest 将合成参数映射为 Google 的请求字段
+// 倍率形式的 pitch 和 volume 分别换算为半音和分贝
+func (g *GoogleTTS) buildRequest(text string, opts models.SynthesisOptions) (*synthesizeRequest, error) {
+	if err := opts.Validate(); err != nil {
 		return nil, err
 	}
+	if opts.HasStyle() {
+		return nil, models.UnsupportedOption("Google TTS 不支持 style、role 参数")
+	}
 
-	return audioData, nil
+	request := &synthesizeRequest{}
+	if opts.IsSSML() {
+		if _, err := ssml.Parse(text); err != nil {
+			return nil, err
+		}
+		request.Input.SSML = text
+	} else {
+		request.Input.Text = text
+	}
+	request.Voice.LanguageCode = g.lang
+	request.Voice.Name = g.voice
+	if opts.Language != "" {
+		request.Voice.LanguageCode = opts.Language
+		// 只指定语种时由 Google 选择该语种的默认音色
+		request.Voice.Name = ""
+	}
+	if opts.Voice != "" {
+		request.Voice.Name = opts.Voice
+	}
+
+	request.AudioConfig.AudioEncoding = g.encoding
+	request.AudioConfig.SampleRateHertz = g.sampleRate
+	request.AudioConfig.EffectsProfileID = g.effects
+	if opts.Format != "" {
+		encoding, ok := encodings[opts.Format]
+		if !ok {
+			return nil, models.UnsupportedOption("Google TTS 不支持 %s 格式", opts.Format)
+		}
+		request.AudioConfig.AudioEncoding = encoding
+	}
+
+	if opts.Speed != 0 {
+		if opts.Speed < 0.25 || opts.Speed > 4 {
+			return nil, models.UnsupportedOption("Google TTS 的 speed 取值范围为 0.25 到 4")
+		}
+		request.AudioConfig.SpeakingRate = opts.Speed
+	}
+	if opts.Pitch != 0 {
+		semitones := 12 * math.Log2(opts.Pitch)
+		if semitones < -20 || semitones > 20 {
+			return nil, models.UnsupportedOption("Google TTS 的 pitch 超出 ±20 个半音的范围")
+		}
+		request.AudioConfig.Pitch = semitones
+	}
+	if opts.Volume != 0 {
+		gain := 20 * math.Log10(opts.Volume)
+		if gain > 16 {
+			return nil, models.UnsupportedOption("Google TTS 的 volume 最大增益为 16dB")
+		}
+		request.AudioConfig.VolumeGainDb = math.Max(gain, -96)
+	}
+	return request, nil
+}
+
+// SynthesisDefaults 返回请求未指定时使用的配置，用于计算缓存键
+func (g *GoogleTTS) SynthesisDefaults() map[string]string {
+	return map[string]string{
+		"voice":       g.voice,
+		"language":    g.lang,
+		"encoding":    g.encoding,
+		"sample_rate": fmt.Sprint(g.sampleRate),
+		"effects":     strings.Join(g.effects, ","),
+	}
 }
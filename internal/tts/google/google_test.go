@@ -0,0 +1,162 @@
+package google
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+func newTestTTS(endpoint string) *GoogleTTS {
+	return &GoogleTTS{
+		apiKey:   "key",
+		voice:    "en-US-Wavenet-D",
+		lang:     "en-US",
+		encoding: "LINEAR16",
+		endpoint: endpoint,
+		client:   http.DefaultClient,
+	}
+}
+
+// newTestServer 返回 audioContent 为 data 的合成接口，并把收到的请求交给 check
+func newTestServer(t *testing.T, data []byte, check func(request synthesizeRequest)) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "key", r.URL.Query().Get("key"))
+		var request synthesizeRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		if check != nil {
+			check(request)
+		}
+		fmt.Fprintf(w, `{"audioContent":%q}`, base64.StdEncoding.EncodeToString(data))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSynthesizeDecodesAudioContent(t *testing.T) {
+	wav := audio.EncodeWAV(audio.Format{SampleRate: 24000, Channels: 1, BitsPerSample: 16}, []byte{1, 2, 3, 4})
+	server := newTestServer(t, wav, func(request synthesizeRequest) {
+		assert.Equal(t, "你好", request.Input.Text)
+		assert.Empty(t, request.Input.SSML)
+		assert.Equal(t, "en-US", request.Voice.LanguageCode)
+		assert.Equal(t, "en-US-Wavenet-D", request.Voice.Name)
+		assert.Equal(t, audioConfig{
+			AudioEncoding:    "LINEAR16",
+			SampleRateHertz:  24000,
+			EffectsProfileID: []string{"telephony-class-application"},
+		}, request.AudioConfig)
+	})
+
+	g := newTestTTS(server.URL)
+	g.sampleRate = 24000
+	g.effects = []string{"telephony-class-application"}
+	data, err := g.Synthesize("你好", models.SynthesisOptions{})
+	assert.NoError(t, err)
+	// 返回解码后的音频，而不是 base64 文本
+	assert.Equal(t, wav, data)
+}
+
+func TestSynthesizeEncodingContainer(t *testing.T) {
+	cases := []struct {
+		name      string
+		encoding  string // 配置的编码
+		format    string // 请求指定的格式
+		want      string
+		audio     []byte
+		container audio.Container
+	}{
+		{"默认 LINEAR16", "LINEAR16", "", "LINEAR16",
+			audio.EncodeWAV(audio.Format{SampleRate: 24000, Channels: 1, BitsPerSample: 16}, nil), audio.ContainerWAV},
+		{"配置为 MP3", "MP3", "", "MP3", []byte("ID3\x04"), audio.ContainerMP3},
+		{"配置为 OGG_OPUS", "OGG_OPUS", "", "OGG_OPUS", []byte("OggS\x00"), audio.ContainerOGG},
+		{"请求的格式优先", "LINEAR16", models.AudioFormatMP3, "MP3", []byte("ID3\x04"), audio.ContainerMP3},
+		{"请求 ogg_opus", "MP3", models.AudioFormatOggOpus, "OGG_OPUS", []byte("OggS\x00"), audio.ContainerOGG},
+	}
+	for _, tc := range cases {
+		server := newTestServer(t, tc.audio, func(request synthesizeRequest) {
+			assert.Equal(t, tc.want, request.AudioConfig.AudioEncoding, tc.name)
+		})
+		g := newTestTTS(server.URL)
+		g.encoding = tc.encoding
+		data, err := g.Synthesize("hello", models.SynthesisOptions{Format: tc.format})
+		assert.NoError(t, err, tc.name)
+		// 存储时按音频内容确定扩展名和 Content-Type
+		container := audio.DetectContainer(data)
+		assert.Equal(t, tc.container, container, tc.name)
+	}
+}
+
+func TestCheckEncoding(t *testing.T) {
+	encoding, err := checkEncoding("")
+	assert.NoError(t, err)
+	assert.Equal(t, "LINEAR16", encoding)
+
+	encoding, err = checkEncoding("OGG_OPUS")
+	assert.NoError(t, err)
+	assert.Equal(t, "OGG_OPUS", encoding)
+
+	_, err = checkEncoding("MULAW")
+	assert.Error(t, err)
+}
+
+func TestBuildRequest(t *testing.T) {
+	g := newTestTTS("")
+
+	request, err := g.buildRequest(`<speak>你好<break time="200ms"/>世界</speak>`,
+		models.SynthesisOptions{TextType: models.TextTypeSSML, Language: "zh-CN"})
+	assert.NoError(t, err)
+	assert.Equal(t, `<speak>你好<break time="200ms"/>世界</speak>`, request.Input.SSML)
+	assert.Empty(t, request.Input.Text)
+	// 只指定语种时由 Google 选择音色
+	assert.Equal(t, "zh-CN", request.Voice.LanguageCode)
+	assert.Empty(t, request.Voice.Name)
+
+	request, err = g.buildRequest("hi", models.SynthesisOptions{Voice: "en-GB-Neural2-A", Speed: 1.5, Pitch: 2, Volume: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "en-GB-Neural2-A", request.Voice.Name)
+	assert.Equal(t, 1.5, request.AudioConfig.SpeakingRate)
+	assert.InDelta(t, 12, request.AudioConfig.Pitch, 1e-9)
+	assert.Equal(t, 0.0, request.AudioConfig.VolumeGainDb)
+
+	for name, opts := range map[string]models.SynthesisOptions{
+		"不支持 pcm":   {Format: models.AudioFormatPCM},
+		"语速超出范围":    {Speed: 5},
+		"音高超出范围":    {Pitch: 4},
+		"音量超出范围":    {Volume: 10},
+		"不支持 style": {Style: "cheerful"},
+		"SSML 格式错误": {TextType: models.TextTypeSSML},
+	} {
+		_, err := g.buildRequest("hi", opts)
+		assert.ErrorIs(t, err, models.ErrUnsupportedOption, name)
+	}
+}
+
+func TestSynthesizeResponseErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"非 200", http.StatusForbidden, `{"error":{"message":"API key not valid"}}`, "API key not valid"},
+		{"audioContent 不是 base64", http.StatusOK, `{"audioContent":"not base64!"}`, "解析"},
+		{"没有音频", http.StatusOK, `{}`, "未返回音频"},
+	}
+	for _, tc := range cases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+			fmt.Fprint(w, tc.body)
+		}))
+		_, err := newTestTTS(server.URL).Synthesize("hello", models.SynthesisOptions{})
+		server.Close()
+		if assert.Error(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.want, tc.name)
+		}
+	}
+}
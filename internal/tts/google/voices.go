@@ -0,0 +1,67 @@
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+type voicesResponse struct {
+	Voices []struct {
+		Name                   string   `json:"name"`
+		LanguageCodes          []string `json:"languageCodes"`
+		SSMLGender             string   `json:"ssmlGender"`
+		NaturalSampleRateHertz int      `json:"naturalSampleRateHertz"`
+	} `json:"voices"`
+}
+
+// ListVoices 调用 voices 接口获取所有可用音色
+func (g *GoogleTTS) ListVoices(ctx context.Context) ([]models.Voice, error) {
+	endpoint := "https://texttospeech.googleapis.com/v1/voices?key=" + g.apiKey
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("获取 Google 音色列表失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("获取 Google 音色列表失败: %s", string(body))
+	}
+
+	var result voicesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析 Google 音色列表失败: %v", err)
+	}
+
+	voices := make([]models.Voice, 0, len(result.Voices))
+	for _, v := range result.Voices {
+		voice := models.Voice{
+			ID:         v.Name,
+			Name:       v.Name,
+			Provider:   "google",
+			SampleRate: v.NaturalSampleRateHertz,
+		}
+		if len(v.LanguageCodes) > 0 {
+			voice.Language = v.LanguageCodes[0]
+			voice.Languages = v.LanguageCodes[1:]
+		}
+		// ssmlGender 为 FEMALE、MALE、NEUTRAL 或 SSML_VOICE_GENDER_UNSPECIFIED
+		switch gender := strings.ToLower(v.SSMLGender); gender {
+		case models.GenderFemale, models.GenderMale, models.GenderNeutral:
+			voice.Gender = gender
+		}
+		voices = append(voices, voice)
+	}
+	return voices, nil
+}
@@ -2,25 +2,61 @@ package local
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"math"
 	"os/exec"
+	"strconv"
+
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/tts/ssml"
+)
+
+// eSpeak 参数的默认值与取值范围
+const (
+	defaultWordsPerMinute = 175
+	minWordsPerMinute     = 80
+	maxWordsPerMinute     = 450
+	defaultPitch          = 50
+	maxPitch              = 99
+	defaultAmplitude      = 100
+	maxAmplitude          = 200
 )
 
 type LocalTTS struct {
-	voice string // 本地 TTS 的语音配置
+	voice   string // 本地 TTS 的语音配置
+	command string // eSpeak 可执行文件
 }
 
 // NewLocalTTS 创建并返回一个新的 LocalTTS 实例
 func NewLocalTTS() *LocalTTS {
 	return &LocalTTS{
-		voice: "en", // 本地 eSpeak 使用的默认语言
+		voice:   "en", // 本地 eSpeak 使用的默认语言
+		command: "espeak",
 	}
 }
 
 // Synthesize 使用本地 TTS 生成语音（例如 eSpeak）
-func (l *LocalTTS) Synthesize(text string) ([]byte, error) {
+func (l *LocalTTS) Synthesize(text string, opts models.SynthesisOptions) ([]byte, error) {
+	return l.SynthesizeContext(context.Background(), text, opts)
+}
+
+// SynthesizeContext 实现 tts.ContextService 接口，ctx 取消时结束 eSpeak 进程
+func (l *LocalTTS) SynthesizeContext(ctx context.Context, text string, opts models.SynthesisOptions) ([]byte, error) {
+	args, err := l.buildArgs(opts)
+	if err != nil {
+		return nil, err
+	}
+	// eSpeak 对 SSML 的支持不完整，统一转换为纯文本朗读
+	if opts.IsSSML() {
+		if text, err = ssml.ToPlainText(text); err != nil {
+			return nil, err
+		}
+	}
+	args = append(args, "--stdout", text)
+
 	// 使用 eSpeak 工具将文本转换为音频
-	cmd := exec.Command("espeak", "-v", l.voice, "--stdout", text)
+	cmd := exec.CommandContext(ctx, l.command, args...)
 	audioData, err := cmd.Output()
 	if err != nil {
 		return nil, err
@@ -28,3 +64,54 @@ func (l *LocalTTS) Synthesize(text string) ([]byte, error) {
 
 	return io.ReadAll(bytes.NewReader(audioData))
 }
+
+// buildArgs 将合成参数换算为 eSpeak 的命令行参数，eSpeak 只能输出 WAV
+func (l *LocalTTS) buildArgs(opts models.SynthesisOptions) ([]string, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Format != "" && opts.Format != models.AudioFormatWAV {
+		return nil, models.UnsupportedOption("eSpeak 只能输出 wav 格式")
+	}
+	if opts.HasStyle() {
+		return nil, models.UnsupportedOption("eSpeak 不支持 style、role 参数")
+	}
+
+	// eSpeak 的语音名同时决定语种，voice 优先于 language
+	voice := l.voice
+	if opts.Language != "" {
+		voice = opts.Language
+	}
+	if opts.Voice != "" {
+		voice = opts.Voice
+	}
+	args := []string{"-v", voice}
+
+	if opts.Speed != 0 {
+		wpm := int(math.Round(opts.Speed * defaultWordsPerMinute))
+		if wpm < minWordsPerMinute || wpm > maxWordsPerMinute {
+			return nil, models.UnsupportedOption("eSpeak 的语速范围为每分钟 %d 到 %d 词", minWordsPerMinute, maxWordsPerMinute)
+		}
+		args = append(args, "-s", strconv.Itoa(wpm))
+	}
+	if opts.Pitch != 0 {
+		pitch := int(math.Round(opts.Pitch * defaultPitch))
+		if pitch > maxPitch {
+			return nil, models.UnsupportedOption("eSpeak 的 pitch 不能超过 %.2f", float64(maxPitch)/defaultPitch)
+		}
+		args = append(args, "-p", strconv.Itoa(pitch))
+	}
+	if opts.Volume != 0 {
+		amplitude := int(math.Round(opts.Volume * defaultAmplitude))
+		if amplitude > maxAmplitude {
+			return nil, models.UnsupportedOption("eSpeak 的 volume 不能超过 %d", maxAmplitude/defaultAmplitude)
+		}
+		args = append(args, "-a", strconv.Itoa(amplitude))
+	}
+	return args, nil
+}
+
+// SynthesisDefaults 返回请求未指定时使用的配置，用于计算缓存键
+func (l *LocalTTS) SynthesisDefaults() map[string]string {
+	return map[string]string{"voice": l.voice}
+}
@@ -0,0 +1,93 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+// fakeESpeak 写入一个假的 espeak 脚本：把参数逐行记录到文件，并输出固定的音频
+func fakeESpeak(t *testing.T) (*LocalTTS, string) {
+	dir := t.TempDir()
+	command := filepath.Join(dir, "espeak")
+	argsFile := filepath.Join(dir, "args")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + argsFile + "\nprintf 'RIFF'\n"
+	assert.NoError(t, os.WriteFile(command, []byte(script), 0o755))
+	return &LocalTTS{voice: "en", command: command}, argsFile
+}
+
+func readArgs(t *testing.T, argsFile string) []string {
+	data, err := os.ReadFile(argsFile)
+	assert.NoError(t, err)
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestBuildArgs(t *testing.T) {
+	cases := []struct {
+		name    string
+		opts    models.SynthesisOptions
+		want    []string
+		wantErr bool
+	}{
+		{"默认音色", models.SynthesisOptions{}, []string{"-v", "en"}, false},
+		{"language 作为音色", models.SynthesisOptions{Language: "zh"}, []string{"-v", "zh"}, false},
+		{"voice 优先于 language", models.SynthesisOptions{Language: "zh", Voice: "en-us"}, []string{"-v", "en-us"}, false},
+		{"语速、音高和音量", models.SynthesisOptions{Speed: 1.2, Pitch: 1.5, Volume: 0.5},
+			[]string{"-v", "en", "-s", "210", "-p", "75", "-a", "50"}, false},
+		{"语速超出范围", models.SynthesisOptions{Speed: 0.3}, nil, true},
+		{"音高超出范围", models.SynthesisOptions{Pitch: 2}, nil, true},
+		{"不支持 mp3", models.SynthesisOptions{Format: models.AudioFormatMP3}, nil, true},
+		{"不支持 style", models.SynthesisOptions{Style: "cheerful"}, nil, true},
+	}
+	l := NewLocalTTS()
+	for _, tc := range cases {
+		args, err := l.buildArgs(tc.opts)
+		if tc.wantErr {
+			assert.ErrorIs(t, err, models.ErrUnsupportedOption, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.want, args, tc.name)
+	}
+}
+
+func TestSynthesizePlainText(t *testing.T) {
+	l, argsFile := fakeESpeak(t)
+	data, err := l.Synthesize("1 < 2 <b>", models.SynthesisOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("RIFF"), data)
+	// 纯文本原样交给 eSpeak，不按 SSML 解析
+	assert.Equal(t, []string{"-v", "en", "--stdout", "1 < 2 <b>"}, readArgs(t, argsFile))
+}
+
+func TestSynthesizeSSML(t *testing.T) {
+	l, argsFile := fakeESpeak(t)
+	doc := `<speak version="1.0" xml:lang="en-US">
+		<voice name="en-US-JennyNeural"><prosody rate="fast">Hello<break time="500ms"/>world.</prosody></voice>
+		<s>Call <say-as interpret-as="characters">SSML</say-as> from <sub alias="World Wide Web">WWW</sub>.</s>
+		<emphasis level="strong">Now</emphasis>
+	</speak>`
+	_, err := l.Synthesize(doc, models.SynthesisOptions{TextType: models.TextTypeSSML})
+	assert.NoError(t, err)
+	// eSpeak 不支持的标记全部去掉，sub 替换为 alias，break 替换为空白
+	assert.Equal(t, []string{"-v", "en", "--stdout", "Hello world. Call SSML from World Wide Web. Now"}, readArgs(t, argsFile))
+}
+
+func TestSynthesizeInvalidSSML(t *testing.T) {
+	l, argsFile := fakeESpeak(t)
+	for _, doc := range []string{
+		`<speak>未闭合`,
+		`<speak><unknown>你好</unknown></speak>`,
+		`<speak><break time="fast"/></speak>`,
+	} {
+		_, err := l.Synthesize(doc, models.SynthesisOptions{TextType: models.TextTypeSSML})
+		assert.ErrorIs(t, err, models.ErrUnsupportedOption, doc)
+	}
+	// 校验失败时不会执行 eSpeak
+	_, err := os.Stat(argsFile)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,55 @@
+package local
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+// ListVoices 解析 espeak --voices 的输出，音色 ID 为可直接传给 -v 的语种代码
+//
+//	Pty Language       Age/Gender VoiceName          File                 Other Languages
+//	 5  af              --/M      Afrikaans          gmw/af
+func (l *LocalTTS) ListVoices(ctx context.Context) ([]models.Voice, error) {
+	out, err := exec.CommandContext(ctx, l.command, "--voices").Output()
+	if err != nil {
+		return nil, fmt.Errorf("执行 espeak --voices 失败: %v", err)
+	}
+	return parseVoices(out), nil
+}
+
+func parseVoices(out []byte) []models.Voice {
+	var voices []models.Voice
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// 跳过表头和格式不完整的行
+		if len(fields) < 4 || fields[0] == "Pty" {
+			continue
+		}
+		voice := models.Voice{
+			ID:       fields[1],
+			Name:     fields[3],
+			Provider: "local",
+			Language: fields[1],
+		}
+		// espeak 输出 M/F，espeak-ng 输出 --/M 形式的年龄/性别
+		gender := fields[2]
+		if i := strings.LastIndex(gender, "/"); i >= 0 {
+			gender = gender[i+1:]
+		}
+		switch gender {
+		case "M":
+			voice.Gender = models.GenderMale
+		case "F":
+			voice.Gender = models.GenderFemale
+		}
+		voices = append(voices, voice)
+	}
+	return voices
+}
@@ -0,0 +1,178 @@
+// internal/tts/longtext.go
+
+package tts
+
+import (
+	"context"
+	"sync"
+
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/tts/textsplit"
+	"github.com/telepace/voiceflow/pkg/config"
+	"github.com/telepace/voiceflow/pkg/logger"
+)
+
+// providerTextLimits 各提供商单次请求可接受的文本长度（UTF-8 字节）
+var providerTextLimits = map[string]int{
+	"volcengine": 1024,
+	"google":     5000,
+	// Azure 的限制针对整个 SSML 请求体，这里为音色和韵律标签预留空间
+	"azure": 3000,
+}
+
+// defaultLongTextConcurrency 默认同时合成的分段数量
+const defaultLongTextConcurrency = 4
+
+// longTextService 在句子边界处切分超长文本，并发合成后拼接为一段连续的音频
+type longTextService struct {
+	inner       Service
+	maxBytes    int
+	concurrency int
+}
+
+// withLongText 按配置为有文本长度限制的提供商包装长文本切分
+func withLongText(provider string, svc Service, cfg *config.Config) Service {
+	c := cfg.TTS.LongText
+	if !c.Enabled {
+		return svc
+	}
+	maxBytes := c.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = providerTextLimits[provider]
+	}
+	if maxBytes <= 0 {
+		return svc
+	}
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultLongTextConcurrency
+	}
+	return &longTextService{inner: svc, maxBytes: maxBytes, concurrency: concurrency}
+}
+
+// segments 返回切分后的文本，SSML 无法安全切分，原样交给提供商
+func (s *longTextService) segments(text string, opts models.SynthesisOptions) []string {
+	if opts.IsSSML() || len(text) <= s.maxBytes {
+		return nil
+	}
+	segments := textsplit.Split(text, s.maxBytes)
+	if len(segments) <= 1 {
+		return nil
+	}
+	logger.Debugf("文本长度 %d 字节超过单次合成限制 %d，切分为 %d 段", len(text), s.maxBytes, len(segments))
+	return segments
+}
+
+func (s *longTextService) Synthesize(text string, opts models.SynthesisOptions) ([]byte, error) {
+	return s.SynthesizeContext(context.Background(), text, opts)
+}
+
+// SynthesizeContext 实现 ContextService 接口，ctx 取消时不再合成剩余的段落
+func (s *longTextService) SynthesizeContext(ctx context.Context, text string, opts models.SynthesisOptions) ([]byte, error) {
+	segments := s.segments(text, opts)
+	if segments == nil {
+		return SynthesizeContext(ctx, s.inner, text, opts)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parts := make([][]byte, len(segments))
+	for i, result := range s.synthesizeSegments(ctx, segments, opts) {
+		r := <-result
+		if r.err != nil {
+			return nil, r.err
+		}
+		parts[i] = r.audio
+	}
+	return audio.Concat(parts)
+}
+
+// SynthesizeStream 分段并发合成，按顺序输出每段音频，首段完成即可开始播放
+func (s *longTextService) SynthesizeStream(ctx context.Context, text string, opts models.SynthesisOptions) (<-chan models.AudioChunk, error) {
+	segments := s.segments(text, opts)
+	if segments == nil {
+		return SynthesizeStream(ctx, s.inner, text, opts)
+	}
+
+	chunks := make(chan models.AudioChunk, len(segments))
+	go func() {
+		defer close(chunks)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var joiner audio.Joiner
+		for _, result := range s.synthesizeSegments(ctx, segments, opts) {
+			var r segmentResult
+			select {
+			case r = <-result:
+			case <-ctx.Done():
+				return
+			}
+			data := r.audio
+			err := r.err
+			if err == nil {
+				data, err = joiner.Next(data)
+			}
+			if err != nil {
+				chunks <- models.AudioChunk{Err: err}
+				return
+			}
+			chunks <- models.AudioChunk{Data: data}
+		}
+	}()
+	return chunks, nil
+}
+
+// StartIncremental 实现 IncrementalService 接口，增量合成由提供商自行分句，直接交给被包装的提供商
+func (s *longTextService) StartIncremental(ctx context.Context, opts models.SynthesisOptions) (models.SynthesisStream, error) {
+	return StartIncremental(ctx, s.inner, opts)
+}
+
+type segmentResult struct {
+	audio []byte
+	err   error
+}
+
+// synthesizeSegments 以有限的并发合成各段文本，返回与分段一一对应的结果通道
+// ctx 取消后不再启动新的分段
+func (s *longTextService) synthesizeSegments(ctx context.Context, segments []string, opts models.SynthesisOptions) []chan segmentResult {
+	results := make([]chan segmentResult, len(segments))
+	for i := range results {
+		results[i] = make(chan segmentResult, 1)
+	}
+
+	go func() {
+		sem := make(chan struct{}, s.concurrency)
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		for i, segment := range segments {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				for _, result := range results[i:] {
+					result <- segmentResult{err: ctx.Err()}
+				}
+				return
+			}
+			wg.Add(1)
+			go func(i int, segment string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				data, err := SynthesizeContext(ctx, s.inner, segment, opts)
+				results[i] <- segmentResult{audio: data, err: err}
+			}(i, segment)
+		}
+	}()
+	return results
+}
+
+func (s *longTextService) ListVoices(ctx context.Context) ([]models.Voice, error) {
+	return ListVoices(ctx, s.inner)
+}
+
+// SynthesisDefaults 返回被包装服务的默认合成参数
+func (s *longTextService) SynthesisDefaults() map[string]string {
+	return SynthesisDefaults(s.inner)
+}
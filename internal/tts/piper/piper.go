@@ -0,0 +1,346 @@
+// Package piper 对接 Piper 神经网络语音合成，支持命令行和 HTTP 服务两种调用方式
+package piper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/tts/ssml"
+	"github.com/telepace/voiceflow/pkg/config"
+	"github.com/telepace/voiceflow/pkg/logger"
+)
+
+const (
+	modeCommand = "command"
+	modeHTTP    = "http"
+
+	// defaultSampleRate Piper 中等质量模型的采样率
+	defaultSampleRate = 22050
+)
+
+// modelConfig 对应模型旁的 .onnx.json 配置文件
+type modelConfig struct {
+	Audio struct {
+		SampleRate int `json:"sample_rate"`
+	} `json:"audio"`
+	Language struct {
+		Code string `json:"code"`
+	} `json:"language"`
+	NumSpeakers  int            `json:"num_speakers"`
+	SpeakerIDMap map[string]int `json:"speaker_id_map"`
+}
+
+type PiperTTS struct {
+	mode            string
+	command         string
+	modelPath       string
+	configPath      string
+	httpURL         string
+	speakerID       int
+	lengthScale     float64
+	noiseScale      float64
+	noiseW          float64
+	sentenceSilence float64
+	sampleRate      int // 输出的采样率，0 表示使用模型的原始采样率
+	timeout         time.Duration
+	model           modelConfig
+}
+
+// NewPiperTTS 创建并返回一个新的 PiperTTS 实例
+func NewPiperTTS() *PiperTTS {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		logger.Fatalf("配置初始化失败: %v", err)
+	}
+
+	c := cfg.Piper
+	p := &PiperTTS{
+		mode:            c.Mode,
+		command:         c.Command,
+		modelPath:       c.ModelPath,
+		configPath:      c.ConfigPath,
+		httpURL:         c.HTTPURL,
+		speakerID:       c.SpeakerID,
+		lengthScale:     c.LengthScale,
+		noiseScale:      c.NoiseScale,
+		noiseW:          c.NoiseW,
+		sentenceSilence: c.SentenceSilence,
+		sampleRate:      c.SampleRate,
+		timeout:         time.Duration(c.Timeout) * time.Second,
+	}
+	if p.mode == "" {
+		p.mode = modeCommand
+	}
+	if p.command == "" {
+		p.command = "piper"
+	}
+	if p.lengthScale <= 0 {
+		p.lengthScale = 1
+	}
+	if p.timeout <= 0 {
+		p.timeout = 60 * time.Second
+	}
+	if p.configPath == "" && p.modelPath != "" {
+		p.configPath = p.modelPath + ".json"
+	}
+
+	switch p.mode {
+	case modeCommand:
+		if p.modelPath == "" {
+			logger.Fatalf("Piper 使用 command 模式时必须配置 piper.model_path")
+		}
+	case modeHTTP:
+		if p.httpURL == "" {
+			logger.Fatalf("Piper 使用 http 模式时必须配置 piper.http_url")
+		}
+	default:
+		logger.Fatalf("未知的 Piper 模式: %s，可选值为 command、http", p.mode)
+	}
+
+	// 模型配置提供采样率和多说话人信息，HTTP 模式下可以不存在
+	if p.configPath != "" {
+		if err := p.loadModelConfig(); err != nil {
+			if p.mode == modeCommand {
+				logger.Fatalf("读取 Piper 模型配置失败: %v", err)
+			}
+			logger.Warnf("读取 Piper 模型配置失败: %v", err)
+		}
+	}
+	return p
+}
+
+func (p *PiperTTS) loadModelConfig() error {
+	data, err := os.ReadFile(p.configPath)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &p.model); err != nil {
+		return fmt.Errorf("解析 %s 失败: %v", p.configPath, err)
+	}
+	return nil
+}
+
+// Synthesize 合成语音，无论 Piper 输出什么格式都返回标准的 WAV
+func (p *PiperTTS) Synthesize(text string, opts models.SynthesisOptions) ([]byte, error) {
+	return p.SynthesizeContext(context.Background(), text, opts)
+}
+
+// SynthesizeContext 实现 tts.ContextService 接口，ctx 取消时结束 piper 进程或中止请求
+func (p *PiperTTS) SynthesizeContext(ctx context.Context, text string, opts models.SynthesisOptions) ([]byte, error) {
+	params, err := p.buildParams(opts)
+	if err != nil {
+		return nil, err
+	}
+	// Piper 不解析 SSML，转换为纯文本朗读
+	if opts.IsSSML() {
+		if text, err = ssml.ToPlainText(text); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	var (
+		format audio.Format
+		pcm    []byte
+	)
+	if p.mode == modeHTTP {
+		format, pcm, err = p.synthesizeHTTP(ctx, text, params)
+	} else {
+		format, pcm, err = p.synthesizeCommand(ctx, text, params)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Volume != 0 && opts.Volume != 1 {
+		pcm = audio.ScalePCM16(pcm, opts.Volume)
+	}
+	if p.sampleRate > 0 && p.sampleRate != format.SampleRate {
+		if pcm, err = audio.Resample16(format, pcm, p.sampleRate); err != nil {
+			return nil, err
+		}
+		format.SampleRate = p.sampleRate
+	}
+
+	if opts.Format == models.AudioFormatPCM {
+		return pcm, nil
+	}
+	return audio.EncodeWAV(format, pcm), nil
+}
+
+// synthesisParams 为单次合成的 Piper 参数
+type synthesisParams struct {
+	speakerID   int
+	lengthScale float64
+}
+
+// buildParams 将合成参数映射为 Piper 的参数
+// voice 为多说话人模型中的说话人名称或编号，speed 换算为 length_scale
+func (p *PiperTTS) buildParams(opts models.SynthesisOptions) (synthesisParams, error) {
+	params := synthesisParams{speakerID: p.speakerID, lengthScale: p.lengthScale}
+	if err := opts.Validate(); err != nil {
+		return params, err
+	}
+	if opts.Format != "" && opts.Format != models.AudioFormatWAV && opts.Format != models.AudioFormatPCM {
+		return params, models.UnsupportedOption("Piper 只能输出 wav 或 pcm 格式")
+	}
+	if opts.Pitch != 0 && opts.Pitch != 1 {
+		return params, models.UnsupportedOption("Piper 不支持调整 pitch")
+	}
+	if opts.HasStyle() {
+		return params, models.UnsupportedOption("Piper 不支持 style、role 参数")
+	}
+	if opts.Language != "" && p.model.Language.Code != "" && !sameLanguage(opts.Language, p.model.Language.Code) {
+		return params, models.UnsupportedOption("Piper 模型的语种为 %s，不支持 %s", p.model.Language.Code, opts.Language)
+	}
+
+	if opts.Voice != "" {
+		if id, ok := p.model.SpeakerIDMap[opts.Voice]; ok {
+			params.speakerID = id
+		} else if id, err := strconv.Atoi(opts.Voice); err == nil && (p.model.NumSpeakers == 0 || id < p.model.NumSpeakers) {
+			params.speakerID = id
+		} else {
+			return params, models.UnsupportedOption("Piper 模型中没有说话人 %s", opts.Voice)
+		}
+	}
+	if opts.Speed != 0 {
+		if opts.Speed < 0.25 || opts.Speed > 4 {
+			return params, models.UnsupportedOption("Piper 的 speed 取值范围为 0.25 到 4")
+		}
+		// length_scale 越大语速越慢
+		params.lengthScale = p.lengthScale / opts.Speed
+	}
+	return params, nil
+}
+
+// synthesizeCommand 调用 piper 命令行，文本通过标准输入传入，以 --output_raw 输出 16 位单声道 PCM
+func (p *PiperTTS) synthesizeCommand(ctx context.Context, text string, params synthesisParams) (audio.Format, []byte, error) {
+	args := []string{
+		"--model", p.modelPath,
+		"--output_raw",
+		"--length_scale", strconv.FormatFloat(params.lengthScale, 'f', -1, 64),
+	}
+	if p.configPath != "" {
+		args = append(args, "--config", p.configPath)
+	}
+	if p.model.NumSpeakers > 1 {
+		args = append(args, "--speaker", strconv.Itoa(params.speakerID))
+	}
+	if p.noiseScale > 0 {
+		args = append(args, "--noise_scale", strconv.FormatFloat(p.noiseScale, 'f', -1, 64))
+	}
+	if p.noiseW > 0 {
+		args = append(args, "--noise_w", strconv.FormatFloat(p.noiseW, 'f', -1, 64))
+	}
+	if p.sentenceSilence > 0 {
+		args = append(args, "--sentence_silence", strconv.FormatFloat(p.sentenceSilence, 'f', -1, 64))
+	}
+
+	cmd := exec.CommandContext(ctx, p.command, args...)
+	// Piper 按行读取文本，换行会被当作多次合成，这里合并为一行
+	cmd.Stdin = strings.NewReader(strings.Join(strings.Fields(text), " ") + "\n")
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return audio.Format{}, nil, fmt.Errorf("Piper 命令执行错误: %v, stderr: %s", err, stderr.String())
+	}
+	if out.Len() == 0 {
+		return audio.Format{}, nil, fmt.Errorf("Piper 未输出音频, stderr: %s", stderr.String())
+	}
+	return p.rawFormat(), out.Bytes(), nil
+}
+
+// synthesizeHTTP 调用 Piper HTTP 服务，返回 WAV 时按文件头解析，否则视为模型采样率的裸 PCM
+func (p *PiperTTS) synthesizeHTTP(ctx context.Context, text string, params synthesisParams) (audio.Format, []byte, error) {
+	body := map[string]interface{}{
+		"text":         text,
+		"length_scale": params.lengthScale,
+	}
+	if p.model.NumSpeakers > 1 || params.speakerID != 0 {
+		body["speaker_id"] = params.speakerID
+	}
+	if p.noiseScale > 0 {
+		body["noise_scale"] = p.noiseScale
+	}
+	if p.noiseW > 0 {
+		body["noise_w"] = p.noiseW
+	}
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return audio.Format{}, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.httpURL, bytes.NewReader(requestBody))
+	if err != nil {
+		return audio.Format{}, nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return audio.Format{}, nil, fmt.Errorf("请求 Piper 服务失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return audio.Format{}, nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return audio.Format{}, nil, fmt.Errorf("Piper 服务返回错误，状态码: %d，响应: %s", resp.StatusCode, string(data))
+	}
+
+	if audio.IsWAV(data) {
+		format, pcm, err := audio.ParseWAV(data)
+		if err != nil {
+			return audio.Format{}, nil, fmt.Errorf("解析 Piper 返回的 WAV 失败: %v", err)
+		}
+		return format, pcm, nil
+	}
+	return p.rawFormat(), data, nil
+}
+
+// rawFormat 返回 Piper 裸 PCM 输出的格式
+func (p *PiperTTS) rawFormat() audio.Format {
+	sampleRate := p.model.Audio.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = defaultSampleRate
+	}
+	return audio.Format{SampleRate: sampleRate, Channels: 1, BitsPerSample: 16}
+}
+
+// sameLanguage 比较语种代码，忽略大小写以及 en_US 与 en-US、en 与 en-US 的差异
+func sameLanguage(a, b string) bool {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.ReplaceAll(s, "_", "-"))
+	}
+	a, b = normalize(a), normalize(b)
+	return a == b || strings.HasPrefix(b, a+"-") || strings.HasPrefix(a, b+"-")
+}
+
+// SynthesisDefaults 返回请求未指定时使用的配置，用于计算缓存键
+func (p *PiperTTS) SynthesisDefaults() map[string]string {
+	return map[string]string{
+		"model":            p.modelPath,
+		"speaker_id":       fmt.Sprint(p.speakerID),
+		"length_scale":     fmt.Sprint(p.lengthScale),
+		"noise_scale":      fmt.Sprint(p.noiseScale),
+		"noise_w":          fmt.Sprint(p.noiseW),
+		"sentence_silence": fmt.Sprint(p.sentenceSilence),
+		"sample_rate":      fmt.Sprint(p.sampleRate),
+	}
+}
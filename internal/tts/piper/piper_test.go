@@ -0,0 +1,214 @@
+package piper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/audio"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+func newTestPiper(mode string) *PiperTTS {
+	p := &PiperTTS{
+		mode:        mode,
+		command:     "piper",
+		modelPath:   "/models/zh_CN-huayan-medium.onnx",
+		lengthScale: 1,
+		timeout:     5 * time.Second,
+	}
+	p.model.Audio.SampleRate = 22050
+	p.model.Language.Code = "zh_CN"
+	return p
+}
+
+func withSpeakers(p *PiperTTS) *PiperTTS {
+	p.model.NumSpeakers = 2
+	p.model.SpeakerIDMap = map[string]int{"female": 0, "male": 1}
+	return p
+}
+
+func TestBuildParams(t *testing.T) {
+	cases := []struct {
+		name    string
+		opts    models.SynthesisOptions
+		speaker int
+		length  float64
+		wantErr bool
+	}{
+		{"默认参数", models.SynthesisOptions{}, 0, 1, false},
+		{"说话人名称", models.SynthesisOptions{Voice: "male"}, 1, 1, false},
+		{"说话人编号", models.SynthesisOptions{Voice: "1"}, 1, 1, false},
+		{"说话人编号越界", models.SynthesisOptions{Voice: "2"}, 0, 0, true},
+		{"未知说话人", models.SynthesisOptions{Voice: "child"}, 0, 0, true},
+		{"语速换算为 length_scale", models.SynthesisOptions{Speed: 2}, 0, 0.5, false},
+		{"语速超出范围", models.SynthesisOptions{Speed: 5}, 0, 0, true},
+		{"语种前缀匹配", models.SynthesisOptions{Language: "zh"}, 0, 1, false},
+		{"语种连字符", models.SynthesisOptions{Language: "zh-cn"}, 0, 1, false},
+		{"语种不匹配", models.SynthesisOptions{Language: "en-US"}, 0, 0, true},
+		{"不支持 pitch", models.SynthesisOptions{Pitch: 1.2}, 0, 0, true},
+		{"不支持 style", models.SynthesisOptions{Style: "cheerful"}, 0, 0, true},
+		{"不支持 mp3", models.SynthesisOptions{Format: models.AudioFormatMP3}, 0, 0, true},
+	}
+	p := withSpeakers(newTestPiper(modeCommand))
+	for _, tc := range cases {
+		params, err := p.buildParams(tc.opts)
+		if tc.wantErr {
+			assert.ErrorIs(t, err, models.ErrUnsupportedOption, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.speaker, params.speakerID, tc.name)
+		assert.InDelta(t, tc.length, params.lengthScale, 1e-9, tc.name)
+	}
+}
+
+// fakePiper 写入一个假的 piper 脚本：把参数和标准输入记录到文件，并输出一段裸 PCM
+func fakePiper(t *testing.T, output string) (command, argsFile, stdinFile string) {
+	dir := t.TempDir()
+	command = filepath.Join(dir, "piper")
+	argsFile = filepath.Join(dir, "args")
+	stdinFile = filepath.Join(dir, "stdin")
+	script := "#!/bin/sh\n" +
+		"printf '%s\\n' \"$@\" > " + argsFile + "\n" +
+		"cat > " + stdinFile + "\n" +
+		output + "\n"
+	assert.NoError(t, os.WriteFile(command, []byte(script), 0o755))
+	return command, argsFile, stdinFile
+}
+
+func TestSynthesizeCommand(t *testing.T) {
+	command, argsFile, stdinFile := fakePiper(t, "printf 'abcd'")
+	p := withSpeakers(newTestPiper(modeCommand))
+	p.command = command
+	p.configPath = "/models/zh_CN-huayan-medium.onnx.json"
+	p.noiseScale = 0.667
+
+	data, err := p.Synthesize("你好\n世界", models.SynthesisOptions{Voice: "male", Speed: 2})
+	assert.NoError(t, err)
+
+	format, pcm, err := audio.ParseWAV(data)
+	assert.NoError(t, err)
+	assert.Equal(t, 22050, format.SampleRate)
+	assert.Equal(t, []byte("abcd"), pcm)
+
+	args, _ := os.ReadFile(argsFile)
+	assert.Equal(t, []string{
+		"--model", "/models/zh_CN-huayan-medium.onnx",
+		"--output_raw",
+		"--length_scale", "0.5",
+		"--config", "/models/zh_CN-huayan-medium.onnx.json",
+		"--speaker", "1",
+		"--noise_scale", "0.667",
+	}, strings.Fields(string(args)))
+
+	// 换行被合并，避免 Piper 按行多次合成
+	stdin, _ := os.ReadFile(stdinFile)
+	assert.Equal(t, "你好 世界\n", string(stdin))
+}
+
+func TestSynthesizeCommandPCMAndSSML(t *testing.T) {
+	command, _, stdinFile := fakePiper(t, "printf 'abcd'")
+	p := newTestPiper(modeCommand)
+	p.command = command
+
+	data, err := p.Synthesize(`<speak version="1.0" xml:lang="zh-CN">你好</speak>`,
+		models.SynthesisOptions{Format: models.AudioFormatPCM, TextType: models.TextTypeSSML})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("abcd"), data)
+
+	stdin, _ := os.ReadFile(stdinFile)
+	assert.Equal(t, "你好\n", string(stdin))
+}
+
+func TestSynthesizeCommandError(t *testing.T) {
+	command, _, _ := fakePiper(t, "echo 模型加载失败 >&2; exit 1")
+	p := newTestPiper(modeCommand)
+	p.command = command
+
+	_, err := p.Synthesize("你好", models.SynthesisOptions{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "模型加载失败")
+
+	command, _, _ = fakePiper(t, "true")
+	p.command = command
+	_, err = p.Synthesize("你好", models.SynthesisOptions{})
+	assert.Error(t, err)
+}
+
+func TestSynthesizeHTTP(t *testing.T) {
+	wav := audio.EncodeWAV(audio.Format{SampleRate: 16000, Channels: 1, BitsPerSample: 16}, []byte{1, 0, 2, 0})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "你好", body["text"])
+		assert.Equal(t, 0.5, body["length_scale"])
+		assert.Equal(t, float64(1), body["speaker_id"])
+		w.Write(wav)
+	}))
+	defer server.Close()
+
+	p := withSpeakers(newTestPiper(modeHTTP))
+	p.httpURL = server.URL
+	data, err := p.Synthesize("你好", models.SynthesisOptions{Voice: "male", Speed: 2, Format: models.AudioFormatPCM})
+	assert.NoError(t, err)
+	// 返回 WAV 时按文件头解析
+	assert.Equal(t, []byte{1, 0, 2, 0}, data)
+}
+
+func TestSynthesizeHTTPRawPCM(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		// 单说话人模型不传 speaker_id
+		assert.NotContains(t, body, "speaker_id")
+		w.Write(make([]byte, 8))
+	}))
+	defer server.Close()
+
+	p := newTestPiper(modeHTTP)
+	p.httpURL = server.URL
+	p.sampleRate = 11025
+	data, err := p.Synthesize("你好", models.SynthesisOptions{})
+	assert.NoError(t, err)
+
+	// 裸 PCM 视为模型采样率，再重采样到配置的采样率
+	format, pcm, err := audio.ParseWAV(data)
+	assert.NoError(t, err)
+	assert.Equal(t, 11025, format.SampleRate)
+	assert.Len(t, pcm, 4)
+}
+
+func TestSynthesizeHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "speaker not found", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	p := newTestPiper(modeHTTP)
+	p.httpURL = server.URL
+	_, err := p.Synthesize("你好", models.SynthesisOptions{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "400")
+}
+
+func TestListVoices(t *testing.T) {
+	voices, err := newTestPiper(modeCommand).ListVoices(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Voice{{
+		ID: "0", Name: "zh_CN-huayan-medium", Provider: "piper", Language: "zh-CN", SampleRate: 22050,
+	}}, voices)
+
+	voices, err = withSpeakers(newTestPiper(modeCommand)).ListVoices(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, voices, 2)
+	assert.Equal(t, "female", voices[0].ID)
+	assert.Equal(t, "zh_CN-huayan-medium/male", voices[1].Name)
+}
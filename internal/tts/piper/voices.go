@@ -0,0 +1,43 @@
+package piper
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+// ListVoices 返回模型中的说话人，单说话人模型返回以模型文件命名的一个音色
+func (p *PiperTTS) ListVoices(ctx context.Context) ([]models.Voice, error) {
+	language := strings.ReplaceAll(p.model.Language.Code, "_", "-")
+	modelName := strings.TrimSuffix(filepath.Base(p.modelPath), ".onnx")
+	if modelName == "." || modelName == "" {
+		modelName = "piper"
+	}
+
+	if len(p.model.SpeakerIDMap) == 0 {
+		return []models.Voice{{
+			ID:         strconv.Itoa(p.speakerID),
+			Name:       modelName,
+			Provider:   "piper",
+			Language:   language,
+			SampleRate: p.rawFormat().SampleRate,
+		}}, nil
+	}
+
+	voices := make([]models.Voice, 0, len(p.model.SpeakerIDMap))
+	for name := range p.model.SpeakerIDMap {
+		voices = append(voices, models.Voice{
+			ID:         name,
+			Name:       modelName + "/" + name,
+			Provider:   "piper",
+			Language:   language,
+			SampleRate: p.rawFormat().SampleRate,
+		})
+	}
+	sort.Slice(voices, func(i, j int) bool { return voices[i].ID < voices[j].ID })
+	return voices, nil
+}
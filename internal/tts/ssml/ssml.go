@@ -0,0 +1,199 @@
+// Package ssml 校验客户端提交的 SSML，并为不支持 SSML 的提供商转换为纯文本
+package ssml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+// allowedElements 为各提供商普遍支持的元素，express-as 为 Azure 的 mstts 扩展
+var allowedElements = map[string]bool{
+	"speak":      true,
+	"voice":      true,
+	"lang":       true,
+	"p":          true,
+	"s":          true,
+	"break":      true,
+	"emphasis":   true,
+	"say-as":     true,
+	"phoneme":    true,
+	"prosody":    true,
+	"sub":        true,
+	"mark":       true,
+	"audio":      true,
+	"express-as": true,
+}
+
+// requiredAttrs 为各元素必须携带的属性
+var requiredAttrs = map[string]string{
+	"say-as":  "interpret-as",
+	"phoneme": "ph",
+	"sub":     "alias",
+}
+
+var (
+	breakTimePattern = regexp.MustCompile(`^\d+(\.\d+)?(ms|s)$`)
+	breakStrengths   = map[string]bool{"none": true, "x-weak": true, "weak": true, "medium": true, "strong": true, "x-strong": true}
+	emphasisLevels   = map[string]bool{"strong": true, "moderate": true, "none": true, "reduced": true}
+)
+
+// Document 是校验通过的 SSML 文档
+type Document struct {
+	// Raw 为原始文档
+	Raw string
+	// Body 为 speak 元素内部的原始内容，便于重新包装
+	Body string
+	// Lang 为 speak 元素上的 xml:lang
+	Lang string
+	// HasVoice 表示文档中是否已经通过 voice 元素指定了音色
+	HasVoice bool
+
+	plain string
+}
+
+// PlainText 返回去掉标记后的朗读文本，sub 元素替换为 alias，break 替换为空白
+func (d *Document) PlainText() string {
+	return d.plain
+}
+
+// Parse 校验 SSML 文档：必须是格式正确的 XML，根元素为 speak，且只包含受支持的元素和属性取值
+func Parse(doc string) (*Document, error) {
+	decoder := xml.NewDecoder(strings.NewReader(doc))
+	result := &Document{Raw: doc}
+
+	var (
+		plain     []string
+		depth     int
+		subDepth  int // 位于 sub 元素内部时，其文本由 alias 代替
+		bodyStart int64
+		bodyEnd   int64
+		closed    bool
+	)
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, models.UnsupportedOption("SSML 格式错误: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if closed {
+				return nil, models.UnsupportedOption("SSML 只能有一个根元素")
+			}
+			name := t.Name.Local
+			if depth == 0 {
+				if name != "speak" {
+					return nil, models.UnsupportedOption("SSML 的根元素必须是 speak，实际为 %s", name)
+				}
+				result.Lang = attr(t, "lang")
+				bodyStart = decoder.InputOffset()
+			} else if name == "speak" {
+				return nil, models.UnsupportedOption("speak 元素不能嵌套")
+			}
+			if err := checkElement(t); err != nil {
+				return nil, err
+			}
+			switch name {
+			case "voice":
+				result.HasVoice = true
+			case "break":
+				plain = append(plain, " ")
+			case "sub":
+				if subDepth == 0 {
+					plain = append(plain, attr(t, "alias"))
+				}
+				subDepth++
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+			switch t.Name.Local {
+			case "sub":
+				subDepth--
+			case "p", "s":
+				plain = append(plain, " ")
+			}
+			if depth == 0 {
+				bodyEnd = offset
+				closed = true
+			}
+		case xml.CharData:
+			if depth == 0 {
+				if len(bytes.TrimSpace(t)) > 0 {
+					return nil, models.UnsupportedOption("speak 元素之外不能有文本")
+				}
+				continue
+			}
+			if subDepth == 0 {
+				plain = append(plain, string(t))
+			}
+		}
+	}
+	if !closed {
+		return nil, models.UnsupportedOption("SSML 缺少 speak 根元素")
+	}
+
+	result.Body = doc[bodyStart:bodyEnd]
+	result.plain = strings.Join(strings.Fields(strings.Join(plain, "")), " ")
+	if result.plain == "" {
+		return nil, models.UnsupportedOption("SSML 中没有可朗读的文本")
+	}
+	return result, nil
+}
+
+// ToPlainText 校验 SSML 并返回去掉标记后的文本
+func ToPlainText(doc string) (string, error) {
+	parsed, err := Parse(doc)
+	if err != nil {
+		return "", err
+	}
+	return parsed.PlainText(), nil
+}
+
+func checkElement(e xml.StartElement) error {
+	name := e.Name.Local
+	if !allowedElements[name] {
+		return models.UnsupportedOption("不支持的 SSML 元素: %s", name)
+	}
+	if required, ok := requiredAttrs[name]; ok && attr(e, required) == "" {
+		return models.UnsupportedOption("SSML 元素 %s 缺少 %s 属性", name, required)
+	}
+
+	switch name {
+	case "break":
+		if v := attr(e, "time"); v != "" && !breakTimePattern.MatchString(v) {
+			return models.UnsupportedOption("break 的 time 属性格式错误: %s，应为 500ms 或 1s 等形式", v)
+		}
+		if v := attr(e, "strength"); v != "" && !breakStrengths[v] {
+			return models.UnsupportedOption("break 的 strength 属性取值错误: %s", v)
+		}
+	case "emphasis":
+		if v := attr(e, "level"); v != "" && !emphasisLevels[v] {
+			return models.UnsupportedOption("emphasis 的 level 属性取值错误: %s", v)
+		}
+	case "prosody":
+		if attr(e, "rate") == "" && attr(e, "pitch") == "" && attr(e, "volume") == "" &&
+			attr(e, "contour") == "" && attr(e, "range") == "" {
+			return models.UnsupportedOption("prosody 元素至少需要 rate、pitch、volume、contour、range 中的一个属性")
+		}
+	}
+	return nil
+}
+
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+package ssml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+func TestParse(t *testing.T) {
+	doc := `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="zh-CN">` +
+		`<p>欢迎使用<break time="300ms"/><emphasis level="strong">语音</emphasis>服务。</p>` +
+		`<s>编号 <say-as interpret-as="digits">2024</say-as>，<sub alias="世界卫生组织">WHO</sub></s>` +
+		`</speak>`
+
+	parsed, err := Parse(doc)
+	assert.NoError(t, err)
+	assert.Equal(t, "zh-CN", parsed.Lang)
+	assert.False(t, parsed.HasVoice)
+	assert.Equal(t, "欢迎使用 语音服务。 编号 2024，世界卫生组织", parsed.PlainText())
+	assert.Contains(t, parsed.Body, "<p>欢迎使用")
+	assert.NotContains(t, parsed.Body, "speak")
+}
+
+func TestParseVoice(t *testing.T) {
+	parsed, err := Parse(`<speak><voice name="en-US-JennyNeural"><prosody rate="+10%">Hello</prosody></voice></speak>`)
+	assert.NoError(t, err)
+	assert.True(t, parsed.HasVoice)
+	assert.Equal(t, "Hello", parsed.PlainText())
+}
+
+func TestParseInvalid(t *testing.T) {
+	cases := map[string]string{
+		"格式错误":       `<speak><p>未闭合</speak>`,
+		"根元素错误":      `<p>hello</p>`,
+		"未知元素":       `<speak><script>alert(1)</script></speak>`,
+		"缺少属性":       `<speak><say-as>123</say-as></speak>`,
+		"break 时间错误": `<speak>a<break time="fast"/>b</speak>`,
+		"根元素外文本":     `<speak>hello</speak>world`,
+		"没有文本":       `<speak><break time="1s"/></speak>`,
+		"纯文本":        `hello world`,
+	}
+	for name, doc := range cases {
+		_, err := Parse(doc)
+		assert.ErrorIs(t, err, models.ErrUnsupportedOption, name)
+	}
+}
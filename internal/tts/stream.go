@@ -0,0 +1,81 @@
+// internal/tts/stream.go
+
+package tts
+
+import (
+	"context"
+	"errors"
+
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+// StreamingService 由能够边合成边返回音频的提供商实现
+// 返回的通道在合成结束或 ctx 取消后关闭
+type StreamingService interface {
+	SynthesizeStream(ctx context.Context, text string, opts models.SynthesisOptions) (<-chan models.AudioChunk, error)
+}
+
+// SynthesizeStream 以流的方式合成语音
+// 提供商不支持流式合成时，整段合成后作为单个音频块返回
+func SynthesizeStream(ctx context.Context, svc Service, text string, opts models.SynthesisOptions) (<-chan models.AudioChunk, error) {
+	if streaming, ok := svc.(StreamingService); ok {
+		return streaming.SynthesizeStream(ctx, text, opts)
+	}
+
+	chunks := make(chan models.AudioChunk, 1)
+	go func() {
+		defer close(chunks)
+		data, err := svc.Synthesize(text, opts)
+		if err != nil {
+			chunks <- models.AudioChunk{Err: err}
+			return
+		}
+		chunks <- models.AudioChunk{Data: data}
+	}()
+	return chunks, nil
+}
+
+// ContextService 由能够在 ctx 取消时中止合成请求的提供商实现
+type ContextService interface {
+	SynthesizeContext(ctx context.Context, text string, opts models.SynthesisOptions) ([]byte, error)
+}
+
+// SynthesizeContext 合成语音，ctx 取消时立即返回 ctx 的错误
+// 提供商实现了 ContextService 时请求随之中止，否则合成在后台结束后结果被丢弃
+func SynthesizeContext(ctx context.Context, svc Service, text string, opts models.SynthesisOptions) ([]byte, error) {
+	if cancelable, ok := svc.(ContextService); ok {
+		return cancelable.SynthesizeContext(ctx, text, opts)
+	}
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		data, err := svc.Synthesize(text, opts)
+		done <- result{data, err}
+	}()
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// IncrementalService 由支持双向流式合成的提供商实现，文本可以在生成的同时逐段送入
+type IncrementalService interface {
+	StartIncremental(ctx context.Context, opts models.SynthesisOptions) (models.SynthesisStream, error)
+}
+
+// ErrIncrementalUnsupported 表示当前提供商不支持增量合成
+var ErrIncrementalUnsupported = errors.New("当前 TTS 提供商不支持增量合成")
+
+// StartIncremental 为支持增量合成的提供商开启合成流，否则返回 ErrIncrementalUnsupported
+func StartIncremental(ctx context.Context, svc Service, opts models.SynthesisOptions) (models.SynthesisStream, error) {
+	if incremental, ok := svc.(IncrementalService); ok {
+		return incremental.StartIncremental(ctx, opts)
+	}
+	return nil, ErrIncrementalUnsupported
+}
@@ -0,0 +1,53 @@
+package textsplit
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Segmenter 将流式到达的文本（例如 LLM 逐个输出的 token）切分为可以立即朗读的句子
+// 缓冲区的最后一句始终暂不输出，因为后续的 token 可能还会补上引号、标点或让英文句号变成小数点
+type Segmenter struct {
+	// MaxRunes 未完成的句子超过该长度时在分句标点处提前切出，降低首句的等待时间，0 表示不提前切分
+	MaxRunes int
+
+	pending string
+}
+
+// Write 追加一段文本，返回已经完整的句子
+func (s *Segmenter) Write(text string) []string {
+	s.pending += text
+	sentences := Sentences(s.pending)
+	if len(sentences) == 0 {
+		return nil
+	}
+
+	last := sentences[len(sentences)-1]
+	var ready []string
+	for _, sentence := range sentences[:len(sentences)-1] {
+		if sentence = strings.TrimSpace(sentence); sentence != "" {
+			ready = append(ready, sentence)
+		}
+	}
+
+	// 最后一句过长时在最后一个分句标点后切出前半部分
+	if s.MaxRunes > 0 && utf8.RuneCountInString(last) > s.MaxRunes {
+		if cut := lastBoundary(last, func(r rune) bool { return clauseEnds[r] }); cut > 0 && cut < len(last) {
+			if clause := strings.TrimSpace(last[:cut]); clause != "" {
+				ready = append(ready, clause)
+			}
+			last = last[cut:]
+		}
+	}
+
+	s.pending = strings.TrimLeftFunc(last, unicode.IsSpace)
+	return ready
+}
+
+// Flush 返回剩余的文本，在输入结束时调用
+func (s *Segmenter) Flush() string {
+	rest := strings.TrimSpace(s.pending)
+	s.pending = ""
+	return rest
+}
@@ -0,0 +1,125 @@
+// Package textsplit 在句子边界处切分长文本，使每段不超过 TTS 提供商的单次请求限制
+package textsplit
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// sentenceEnds 中英文句末标点，英文句号需要后面跟空白才视为句末，避免切开小数和缩写
+var sentenceEnds = map[rune]bool{
+	'。': true, '！': true, '？': true, '；': true, '…': true,
+	'!': true, '?': true, ';': true, '\n': true,
+}
+
+// clauseEnds 句子过长时退而求其次的切分位置
+var clauseEnds = map[rune]bool{
+	'，': true, '、': true, '：': true, ',': true, ':': true,
+}
+
+// closers 紧跟在句末标点之后、应归属上一句的引号和括号
+var closers = map[rune]bool{
+	'"': true, '\'': true, '”': true, '’': true, '）': true, ')': true, '」': true, '』': true, '》': true,
+}
+
+// Split 将文本切分为若干段，每段不超过 maxBytes 字节（UTF-8）
+// 优先在句末切分并尽量把相邻的短句合并到同一段，单句超长时依次尝试分句标点、空白，最后按字符硬切
+func Split(text string, maxBytes int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if maxBytes <= 0 || len(text) <= maxBytes {
+		return []string{text}
+	}
+
+	var (
+		segments []string
+		current  strings.Builder
+	)
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); s != "" {
+			segments = append(segments, s)
+		}
+		current.Reset()
+	}
+
+	for _, sentence := range Sentences(text) {
+		for _, piece := range splitLong(sentence, maxBytes) {
+			if current.Len() > 0 && current.Len()+len(piece) > maxBytes {
+				flush()
+			}
+			current.WriteString(piece)
+		}
+	}
+	flush()
+	return segments
+}
+
+// Sentences 按句末标点切分文本，每个句子保留其标点和后续空白
+func Sentences(text string) []string {
+	var sentences []string
+	runes := []rune(text)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		isEnd := sentenceEnds[r]
+		if r == '.' {
+			isEnd = i+1 == len(runes) || unicode.IsSpace(runes[i+1]) || closers[runes[i+1]]
+		}
+		if !isEnd {
+			continue
+		}
+		// 连续的标点、收尾引号和空白都归入当前句
+		for i+1 < len(runes) && (sentenceEnds[runes[i+1]] || runes[i+1] == '.' || closers[runes[i+1]] || unicode.IsSpace(runes[i+1])) {
+			i++
+		}
+		sentences = append(sentences, string(runes[start:i+1]))
+		start = i + 1
+	}
+	if start < len(runes) {
+		sentences = append(sentences, string(runes[start:]))
+	}
+	return sentences
+}
+
+// splitLong 将超过 maxBytes 的句子继续切分
+func splitLong(sentence string, maxBytes int) []string {
+	if len(sentence) <= maxBytes {
+		return []string{sentence}
+	}
+
+	var pieces []string
+	for len(sentence) > maxBytes {
+		cut := lastBoundary(sentence[:maxBytes], func(r rune) bool { return clauseEnds[r] })
+		if cut <= 0 {
+			cut = lastBoundary(sentence[:maxBytes], unicode.IsSpace)
+		}
+		if cut <= 0 {
+			// 没有任何可用的切分点，在字符边界处硬切
+			cut = maxBytes
+			for cut > 0 && !utf8.RuneStart(sentence[cut]) {
+				cut--
+			}
+		}
+		pieces = append(pieces, sentence[:cut])
+		sentence = sentence[cut:]
+	}
+	if sentence != "" {
+		pieces = append(pieces, sentence)
+	}
+	return pieces
+}
+
+// lastBoundary 返回 s 中最后一个满足 match 的字符之后的字节位置，不存在时返回 -1
+func lastBoundary(s string, match func(rune) bool) int {
+	for i := len(s); i > 0; {
+		r, size := utf8.DecodeLastRuneInString(s[:i])
+		if match(r) {
+			return i
+		}
+		i -= size
+	}
+	return -1
+}
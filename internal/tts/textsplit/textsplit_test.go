@@ -0,0 +1,62 @@
+package textsplit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSentences(t *testing.T) {
+	assert.Equal(t,
+		[]string{"你好。", "今天天气怎么样？", "Pi is 3.14. ", "He said \"hi!\" ", "好的"},
+		Sentences("你好。今天天气怎么样？Pi is 3.14. He said \"hi!\" 好的"))
+}
+
+func TestSplit(t *testing.T) {
+	text := "第一句话。第二句话！Third sentence here. 第四句。"
+
+	// 不超过限制时不切分
+	assert.Equal(t, []string{text}, Split(text, 1024))
+
+	segments := Split(text, 32)
+	assert.Equal(t, []string{"第一句话。第二句话！", "Third sentence here.", "第四句。"}, segments)
+	for _, seg := range segments {
+		assert.LessOrEqual(t, len(seg), 32)
+	}
+}
+
+func TestSplitLongSentence(t *testing.T) {
+	// 单句超长时在逗号处切分
+	segments := Split("一二三四五，六七八九十，甲乙丙丁戊", 20)
+	assert.Equal(t, []string{"一二三四五，", "六七八九十，", "甲乙丙丁戊"}, segments)
+
+	// 没有标点和空白时在字符边界硬切，不会切坏 UTF-8
+	long := strings.Repeat("长", 10)
+	segments = Split(long, 7)
+	assert.Equal(t, strings.Join(segments, ""), long)
+	for _, seg := range segments {
+		assert.Equal(t, "长长", seg)
+	}
+}
+
+func TestSegmenter(t *testing.T) {
+	var s Segmenter
+	var sentences []string
+	for _, token := range []string{"你好", "！我是", "助手。", "Pi is 3", ".14. It", " is \"round.\"", " Bye"} {
+		sentences = append(sentences, s.Write(token)...)
+	}
+	assert.Equal(t, []string{"你好！", "我是助手。", "Pi is 3.14.", "It is \"round.\""}, sentences)
+	assert.Equal(t, "Bye", s.Flush())
+	assert.Equal(t, "", s.Flush())
+}
+
+func TestSegmenterMaxRunes(t *testing.T) {
+	s := Segmenter{MaxRunes: 8}
+	assert.Empty(t, s.Write("今天天气"))
+	assert.Equal(t, []string{"今天天气很好，"}, s.Write("很好，我们去"))
+	// 句末标点之后可能还有引号，最后一句留到下一个 token 或 Flush 时输出
+	assert.Empty(t, s.Write("公园吧。"))
+	assert.Equal(t, []string{"我们去公园吧。"}, s.Write("好"))
+	assert.Equal(t, "好", s.Flush())
+}
@@ -3,31 +3,59 @@
 package tts
 
 import (
+	"github.com/telepace/voiceflow/internal/models"
 	"github.com/telepace/voiceflow/internal/tts/azure"
 	"github.com/telepace/voiceflow/internal/tts/google"
 	"github.com/telepace/voiceflow/internal/tts/local"
+	"github.com/telepace/voiceflow/internal/tts/piper"
 	"github.com/telepace/voiceflow/internal/tts/volcengine"
+	"github.com/telepace/voiceflow/pkg/config"
 	"github.com/telepace/voiceflow/pkg/logger"
 )
 
 // Service 定义了 TTS 服务的通用接口
 type Service interface {
-	Synthesize(text string) ([]byte, error) // 将文本合成为音频数据
+	// Synthesize 将文本合成为音频数据，opts.TextType 为 ssml 时 text 为 SSML 文档
+	// 提供商无法满足的参数返回 models.ErrUnsupportedOption
+	Synthesize(text string, opts models.SynthesisOptions) ([]byte, error)
+}
+
+// DefaultsReporter 由能够给出默认合成参数的提供商实现
+// 默认的音色、编码等来自配置，缓存键需要包含这些参数，修改配置后才不会命中旧的音频
+type DefaultsReporter interface {
+	SynthesisDefaults() map[string]string
+}
+
+// SynthesisDefaults 返回服务的默认合成参数，提供商未实现 DefaultsReporter 时返回 nil
+func SynthesisDefaults(svc Service) map[string]string {
+	if reporter, ok := svc.(DefaultsReporter); ok {
+		return reporter.SynthesisDefaults()
+	}
+	return nil
 }
 
 // NewService 根据配置返回相应的 TTS 服务实现
 func NewService(provider string) Service {
 	logger.Debugf("Using TTS provider: %s", provider)
+	cfg, err := config.GetConfig()
+	if err != nil {
+		logger.Fatalf("配置初始化失败: %v", err)
+	}
+
+	var svc Service
 	switch provider {
 	case "azure":
-		return azure.NewAzureTTS() // 调用 Azure TTS 实现
+		svc = azure.NewAzureTTS() // 调用 Azure TTS 实现
 	case "google":
-		return google.NewGoogleTTS() // 调用 Google TTS 实现
+		svc = google.NewGoogleTTS() // 调用 Google TTS 实现
 	case "volcengine":
-		return volcengine.NewVolcengineTTS()
+		svc = volcengine.NewVolcengineTTS()
 	case "local":
-		return local.NewLocalTTS() // 调用本地 TTS 实现
+		svc = local.NewLocalTTS() // 调用本地 TTS 实现
+	case "piper":
+		svc = piper.NewPiperTTS()
 	default:
-		return local.NewLocalTTS() // 默认使用本地 TTS
+		svc = local.NewLocalTTS() // 默认使用本地 TTS
 	}
+	return withLongText(provider, svc, cfg)
 }
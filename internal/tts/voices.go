@@ -0,0 +1,50 @@
+// internal/tts/voices.go
+
+package tts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/tts/azure"
+	"github.com/telepace/voiceflow/internal/tts/google"
+	"github.com/telepace/voiceflow/internal/tts/local"
+	"github.com/telepace/voiceflow/internal/tts/piper"
+	"github.com/telepace/voiceflow/internal/tts/volcengine"
+)
+
+// ErrVoiceListUnsupported 表示提供商无法列出可用音色
+var ErrVoiceListUnsupported = errors.New("该 TTS 提供商不支持列出音色")
+
+// VoiceLister 由能够列出可用音色的提供商实现
+type VoiceLister interface {
+	ListVoices(ctx context.Context) ([]models.Voice, error)
+}
+
+// ListVoices 列出服务的可用音色
+func ListVoices(ctx context.Context, svc Service) ([]models.Voice, error) {
+	if lister, ok := svc.(VoiceLister); ok {
+		return lister.ListVoices(ctx)
+	}
+	return nil, ErrVoiceListUnsupported
+}
+
+// NewVoiceLister 创建用于查询音色的提供商实例，音色目录可以同时汇总多个提供商
+func NewVoiceLister(provider string) (VoiceLister, error) {
+	switch provider {
+	case "azure":
+		return azure.NewAzureTTS(), nil
+	case "google":
+		return google.NewGoogleTTS(), nil
+	case "volcengine":
+		return volcengine.NewVolcengineTTS(), nil
+	case "local":
+		return local.NewLocalTTS(), nil
+	case "piper":
+		return piper.NewPiperTTS(), nil
+	default:
+		return nil, fmt.Errorf("未知的 TTS 提供商: %s", provider)
+	}
+}
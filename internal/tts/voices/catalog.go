@@ -0,0 +1,155 @@
+// Package voices 汇总各 TTS 提供商的音色列表，并定期刷新
+package voices
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/tts"
+	"github.com/telepace/voiceflow/pkg/logger"
+)
+
+const (
+	// defaultRefreshInterval 默认的音色列表刷新间隔
+	defaultRefreshInterval = time.Hour
+	// fetchTimeout 单个提供商查询音色的超时时间
+	fetchTimeout = 30 * time.Second
+)
+
+// Filter 为音色查询条件，空字段表示不过滤
+type Filter struct {
+	Provider string
+	// Language 按前缀匹配且忽略大小写，例如 zh 匹配 zh-CN 和 zh-TW
+	Language string
+	Gender   string
+}
+
+func (f Filter) match(v models.Voice) bool {
+	if f.Provider != "" && !strings.EqualFold(f.Provider, v.Provider) {
+		return false
+	}
+	if f.Gender != "" && !strings.EqualFold(f.Gender, v.Gender) {
+		return false
+	}
+	if f.Language == "" {
+		return true
+	}
+	for _, lang := range append([]string{v.Language}, v.Languages...) {
+		if hasLanguagePrefix(lang, f.Language) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasLanguagePrefix(lang, prefix string) bool {
+	lang = strings.ToLower(strings.ReplaceAll(lang, "_", "-"))
+	prefix = strings.ToLower(strings.ReplaceAll(prefix, "_", "-"))
+	return lang == prefix || strings.HasPrefix(lang, prefix+"-")
+}
+
+// Catalog 缓存各提供商的音色列表
+type Catalog struct {
+	listers  map[string]tts.VoiceLister
+	interval time.Duration
+
+	mu        sync.RWMutex
+	voices    map[string][]models.Voice
+	updatedAt time.Time
+}
+
+// NewCatalog 为给定的提供商创建音色目录，无法创建的提供商会被跳过
+func NewCatalog(providers []string, interval time.Duration) *Catalog {
+	if interval <= 0 {
+		interval = defaultRefreshInterval
+	}
+	c := &Catalog{
+		listers:  make(map[string]tts.VoiceLister),
+		interval: interval,
+		voices:   make(map[string][]models.Voice),
+	}
+	for _, provider := range providers {
+		lister, err := tts.NewVoiceLister(provider)
+		if err != nil {
+			logger.Warnf("音色目录跳过提供商 %s: %v", provider, err)
+			continue
+		}
+		c.listers[provider] = lister
+	}
+	return c
+}
+
+// Start 立即在后台加载音色列表，之后按间隔刷新，直到 ctx 取消
+func (c *Catalog) Start(ctx context.Context) {
+	go func() {
+		c.Refresh(ctx)
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.Refresh(ctx)
+			}
+		}
+	}()
+}
+
+// Refresh 重新查询所有提供商，查询失败的提供商保留上一次的结果
+func (c *Catalog) Refresh(ctx context.Context) {
+	var wg sync.WaitGroup
+	for provider, lister := range c.listers {
+		wg.Add(1)
+		go func(provider string, lister tts.VoiceLister) {
+			defer wg.Done()
+			fetchCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
+			defer cancel()
+
+			voices, err := lister.ListVoices(fetchCtx)
+			if err != nil {
+				logger.Warnf("刷新 %s 音色列表失败: %v", provider, err)
+				return
+			}
+			c.mu.Lock()
+			c.voices[provider] = voices
+			c.mu.Unlock()
+			logger.Debugf("已刷新 %s 音色列表，共 %d 个音色", provider, len(voices))
+		}(provider, lister)
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	c.updatedAt = time.Now()
+	c.mu.Unlock()
+}
+
+// Voices 返回符合条件的音色，按提供商、语种和 ID 排序，以及列表的更新时间
+func (c *Catalog) Voices(filter Filter) ([]models.Voice, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := []models.Voice{}
+	for _, voices := range c.voices {
+		for _, v := range voices {
+			if filter.match(v) {
+				result = append(result, v)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Language != b.Language {
+			return a.Language < b.Language
+		}
+		return a.ID < b.ID
+	})
+	return result, c.updatedAt
+}
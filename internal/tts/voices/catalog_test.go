@@ -0,0 +1,125 @@
+package voices
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/tts"
+)
+
+type fakeLister struct {
+	voices []models.Voice
+	err    error
+}
+
+func (f *fakeLister) ListVoices(ctx context.Context) ([]models.Voice, error) {
+	return f.voices, f.err
+}
+
+func newTestCatalog(listers map[string]tts.VoiceLister) *Catalog {
+	return &Catalog{
+		listers:  listers,
+		interval: time.Hour,
+		voices:   make(map[string][]models.Voice),
+	}
+}
+
+func testVoices() (*fakeLister, *fakeLister) {
+	azure := &fakeLister{voices: []models.Voice{
+		{ID: "zh-CN-XiaoxiaoNeural", Provider: "azure", Language: "zh-CN", Gender: models.GenderFemale},
+		{ID: "en-US-JennyNeural", Provider: "azure", Language: "en-US", Gender: models.GenderFemale,
+			Languages: []string{"zh-CN"}},
+		{ID: "en-US-GuyNeural", Provider: "azure", Language: "en-US", Gender: models.GenderMale},
+	}}
+	google := &fakeLister{voices: []models.Voice{
+		{ID: "cmn-CN-Wavenet-A", Provider: "google", Language: "cmn_CN", Gender: models.GenderFemale},
+	}}
+	return azure, google
+}
+
+func ids(voices []models.Voice) []string {
+	result := []string{}
+	for _, v := range voices {
+		result = append(result, v.ID)
+	}
+	return result
+}
+
+func TestVoicesFilter(t *testing.T) {
+	azure, google := testVoices()
+	c := newTestCatalog(map[string]tts.VoiceLister{"azure": azure, "google": google})
+	c.Refresh(context.Background())
+
+	all, updatedAt := c.Voices(Filter{})
+	assert.False(t, updatedAt.IsZero())
+	// 按提供商、语种和 ID 排序
+	assert.Equal(t, []string{"en-US-GuyNeural", "en-US-JennyNeural", "zh-CN-XiaoxiaoNeural", "cmn-CN-Wavenet-A"}, ids(all))
+
+	cases := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"提供商忽略大小写", Filter{Provider: "Google"}, []string{"cmn-CN-Wavenet-A"}},
+		{"语种前缀匹配附加语种", Filter{Language: "zh"}, []string{"en-US-JennyNeural", "zh-CN-XiaoxiaoNeural"}},
+		{"下划线与连字符等价", Filter{Language: "cmn-cn"}, []string{"cmn-CN-Wavenet-A"}},
+		{"前缀必须在分隔符处", Filter{Language: "e"}, []string{}},
+		{"性别", Filter{Provider: "azure", Gender: "MALE"}, []string{"en-US-GuyNeural"}},
+		{"组合条件", Filter{Language: "en", Gender: models.GenderFemale}, []string{"en-US-JennyNeural"}},
+	}
+	for _, tc := range cases {
+		voices, _ := c.Voices(tc.filter)
+		assert.Equal(t, tc.want, ids(voices), tc.name)
+	}
+}
+
+func TestRefreshKeepsPreviousOnError(t *testing.T) {
+	azure, google := testVoices()
+	c := newTestCatalog(map[string]tts.VoiceLister{"azure": azure, "google": google})
+	c.Refresh(context.Background())
+
+	// 查询失败的提供商保留上一次的结果，其他提供商正常更新
+	azure.err = errors.New("401 unauthorized")
+	google.voices = append(google.voices, models.Voice{ID: "cmn-CN-Wavenet-B", Provider: "google", Language: "cmn-CN"})
+	c.Refresh(context.Background())
+
+	voices, _ := c.Voices(Filter{Provider: "azure"})
+	assert.Len(t, voices, 3)
+	voices, _ = c.Voices(Filter{Provider: "google"})
+	assert.Len(t, voices, 2)
+}
+
+func TestRefreshProviderError(t *testing.T) {
+	c := newTestCatalog(map[string]tts.VoiceLister{"local": &fakeLister{err: errors.New("espeak not found")}})
+	c.Refresh(context.Background())
+
+	voices, updatedAt := c.Voices(Filter{})
+	assert.Empty(t, voices)
+	assert.NotNil(t, voices)
+	assert.False(t, updatedAt.IsZero())
+}
+
+func TestStartRefreshesInBackground(t *testing.T) {
+	azure, _ := testVoices()
+	c := newTestCatalog(map[string]tts.VoiceLister{"azure": azure})
+	c.interval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.Start(ctx)
+
+	assert.Eventually(t, func() bool {
+		voices, _ := c.Voices(Filter{})
+		return len(voices) == 3
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestNewCatalogSkipsUnknownProvider(t *testing.T) {
+	c := NewCatalog([]string{"unknown"}, 0)
+	assert.Empty(t, c.listers)
+	assert.Equal(t, defaultRefreshInterval, c.interval)
+}
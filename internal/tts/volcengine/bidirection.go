@@ -0,0 +1,345 @@
+package volcengine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/volcengine/protocol"
+)
+
+const (
+	// namespace 双向流式合成的命名空间
+	namespace = "BidirectionalTTS"
+	// handshakeTimeout 建立连接和会话的最长等待时间
+	handshakeTimeout = 10 * time.Second
+	// statusOK 会话结束时表示成功的状态码
+	statusOK = 20000000
+)
+
+// StartIncremental 使用 v3 双向流式接口开启增量合成，文本可以在 LLM 生成的同时逐段写入
+// 一条连接只承载一个会话，会话结束后连接随之关闭
+func (v *VolcengineTTS) StartIncremental(ctx context.Context, opts models.SynthesisOptions) (models.SynthesisStream, error) {
+	reqParams, err := v.incrementalParams(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	header.Set("X-Api-App-Key", v.appID)
+	header.Set("X-Api-Access-Key", v.token)
+	header.Set("X-Api-Resource-Id", v.resourceID)
+	header.Set("X-Api-Connect-Id", uuid.New().String())
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, v.bidirectionURL, header)
+	if err != nil {
+		return nil, fmt.Errorf("WebSocket连接失败: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &incrementalSession{
+		conn:      conn,
+		sessionID: uuid.New().String(),
+		uid:       fmt.Sprintf("user_%d", time.Now().UnixNano()),
+		reqParams: reqParams,
+		audio:     make(chan models.AudioChunk, streamBufferSize),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	if err := s.handshake(); err != nil {
+		cancel()
+		conn.Close()
+		return nil, err
+	}
+
+	// ctx 取消时关闭连接，使阻塞中的读取立即返回
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-s.done:
+		}
+	}()
+	go s.receive(ctx)
+	return s, nil
+}
+
+// incrementalParams 将合成参数映射为 v3 接口的 req_params
+// v3 接口的语速和音量为 [-50, 100] 的整数，0 表示正常，100 表示 2 倍
+func (v *VolcengineTTS) incrementalParams(opts models.SynthesisOptions) (map[string]interface{}, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.IsSSML() {
+		return nil, models.UnsupportedOption("火山引擎增量合成不支持 SSML")
+	}
+	if opts.Language != "" {
+		return nil, models.UnsupportedOption("火山引擎 TTS 的语种由音色决定，请通过 voice 指定音色")
+	}
+	if opts.Pitch != 0 && opts.Pitch != 1 {
+		return nil, models.UnsupportedOption("火山引擎增量合成不支持调整 pitch")
+	}
+
+	speaker := v.voiceType
+	if opts.Voice != "" {
+		speaker = opts.Voice
+	}
+	format := v.encoding
+	if opts.Format != "" {
+		format = opts.Format
+	}
+	switch format {
+	case models.AudioFormatMP3, models.AudioFormatPCM, models.AudioFormatOggOpus:
+	default:
+		return nil, models.UnsupportedOption("火山引擎增量合成只能输出 mp3、pcm 或 ogg_opus 格式")
+	}
+
+	audioParams := map[string]interface{}{"format": format}
+	if v.sampleRate > 0 {
+		audioParams["sample_rate"] = v.sampleRate
+	}
+	// 请求未指定时使用配置中的默认倍率
+	rates := []struct {
+		key, option string
+		value, base float64
+	}{
+		{"speech_rate", "speed", opts.Speed, v.speedRatio},
+		{"loudness_rate", "volume", opts.Volume, v.volume},
+	}
+	for _, r := range rates {
+		ratio := r.value
+		if ratio == 0 {
+			ratio = r.base
+		}
+		if ratio == 0 || ratio == 1 {
+			continue
+		}
+		if ratio < 0.5 || ratio > 2 {
+			return nil, models.UnsupportedOption("火山引擎增量合成的 %s 取值范围为 0.5 到 2", r.option)
+		}
+		audioParams[r.key] = int(math.Round((ratio - 1) * 100))
+	}
+
+	emotion, emotionScale, err := v.emotionParams(speaker, opts)
+	if err != nil {
+		return nil, err
+	}
+	if emotion != "" {
+		audioParams["emotion"] = emotion
+		if emotionScale != 0 {
+			audioParams["emotion_scale"] = emotionScale
+		}
+	}
+
+	return map[string]interface{}{
+		"speaker":      speaker,
+		"audio_params": audioParams,
+	}, nil
+}
+
+// incrementalSession 实现 models.SynthesisStream
+type incrementalSession struct {
+	conn      *websocket.Conn
+	sessionID string
+	uid       string
+	reqParams map[string]interface{}
+	audio     chan models.AudioChunk
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	mu       sync.Mutex // 串行化写入，gorilla/websocket 不支持并发写
+	finished bool
+}
+
+// handshake 依次建立连接和会话
+func (s *incrementalSession) handshake() error {
+	s.conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	defer s.conn.SetReadDeadline(time.Time{})
+
+	if err := s.send(protocol.EventStartConnection, map[string]interface{}{}); err != nil {
+		return fmt.Errorf("建立连接失败: %v", err)
+	}
+	if err := s.waitFor(protocol.EventConnectionStarted); err != nil {
+		return fmt.Errorf("建立连接失败: %v", err)
+	}
+	if err := s.send(protocol.EventStartSession, s.request(protocol.EventStartSession, "")); err != nil {
+		return fmt.Errorf("建立会话失败: %v", err)
+	}
+	if err := s.waitFor(protocol.EventSessionStarted); err != nil {
+		return fmt.Errorf("建立会话失败: %v", err)
+	}
+	return nil
+}
+
+// waitFor 读取服务端消息直到收到指定事件
+func (s *incrementalSession) waitFor(event protocol.Event) error {
+	for {
+		msg, err := s.read()
+		if err != nil {
+			return err
+		}
+		switch msg.Event {
+		case event:
+			return nil
+		case protocol.EventConnectionFailed, protocol.EventSessionFailed:
+			return fmt.Errorf("服务端拒绝请求: %s", msg.Payload)
+		}
+	}
+}
+
+func (s *incrementalSession) read() (*protocol.Message, error) {
+	_, data, err := s.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	msg, err := protocol.Unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+	if err := msg.Err(); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// request 构建会话级事件的请求体，text 非空时作为本次追加的文本
+func (s *incrementalSession) request(event protocol.Event, text string) map[string]interface{} {
+	reqParams := make(map[string]interface{}, len(s.reqParams)+1)
+	for k, v := range s.reqParams {
+		reqParams[k] = v
+	}
+	if text != "" {
+		reqParams["text"] = text
+	}
+	return map[string]interface{}{
+		"user":       map[string]interface{}{"uid": s.uid},
+		"event":      event,
+		"namespace":  namespace,
+		"req_params": reqParams,
+	}
+}
+
+func (s *incrementalSession) send(event protocol.Event, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("JSON序列化失败: %v", err)
+	}
+	return writeMessage(s.conn, &protocol.Message{
+		Type:          protocol.MsgFullClientRequest,
+		Flags:         protocol.FlagWithEvent,
+		Serialization: protocol.SerializationJSON,
+		Event:         event,
+		SessionID:     s.sessionID,
+		Payload:       data,
+	})
+}
+
+// Write 追加一段文本，服务端会自行断句并在句子完整后开始合成
+func (s *incrementalSession) Write(text string) error {
+	if text == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		return fmt.Errorf("合成流已结束")
+	}
+	if err := s.send(protocol.EventTaskRequest, s.request(protocol.EventTaskRequest, text)); err != nil {
+		return fmt.Errorf("发送文本失败: %v", err)
+	}
+	return nil
+}
+
+func (s *incrementalSession) Audio() <-chan models.AudioChunk {
+	return s.audio
+}
+
+// Finish 通知服务端文本已结束，剩余文本合成完毕后服务端结束会话
+func (s *incrementalSession) Finish() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		return nil
+	}
+	s.finished = true
+	if err := s.send(protocol.EventFinishSession, map[string]interface{}{}); err != nil {
+		return fmt.Errorf("结束会话失败: %v", err)
+	}
+	return nil
+}
+
+// Abort 关闭连接并等待接收协程退出
+func (s *incrementalSession) Abort() {
+	s.mu.Lock()
+	s.finished = true
+	s.mu.Unlock()
+	s.cancel()
+	<-s.done
+}
+
+// receive 读取服务端返回的音频，直到会话结束、失败或 ctx 取消
+func (s *incrementalSession) receive(ctx context.Context) {
+	defer close(s.done)
+	defer close(s.audio)
+	defer s.cancel()
+	defer s.conn.Close()
+
+	fail := func(err error) {
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case s.audio <- models.AudioChunk{Err: err}:
+		case <-ctx.Done():
+		}
+	}
+
+	for {
+		msg, err := s.read()
+		if err != nil {
+			fail(fmt.Errorf("读取响应失败: %v", err))
+			return
+		}
+
+		switch msg.Event {
+		case protocol.EventTTSResponse:
+			if len(msg.Payload) == 0 {
+				continue
+			}
+			select {
+			case s.audio <- models.AudioChunk{Data: msg.Payload}:
+			case <-ctx.Done():
+				return
+			}
+		case protocol.EventTTSSentenceEnd:
+			select {
+			case s.audio <- models.AudioChunk{SentenceEnd: true}:
+			case <-ctx.Done():
+				return
+			}
+		case protocol.EventSessionFinished:
+			var status struct {
+				StatusCode int    `json:"status_code"`
+				Message    string `json:"message"`
+			}
+			if len(msg.Payload) > 0 && json.Unmarshal(msg.Payload, &status) == nil &&
+				status.StatusCode != 0 && status.StatusCode != statusOK {
+				fail(fmt.Errorf("服务端错误(code=%d): %s", status.StatusCode, status.Message))
+			}
+			s.mu.Lock()
+			s.send(protocol.EventFinishConnection, map[string]interface{}{})
+			s.mu.Unlock()
+			return
+		case protocol.EventSessionFailed, protocol.EventSessionCanceled:
+			fail(fmt.Errorf("合成会话异常结束: %s", msg.Payload))
+			return
+		}
+	}
+}
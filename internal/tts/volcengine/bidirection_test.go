@@ -0,0 +1,209 @@
+package volcengine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/volcengine/protocol"
+)
+
+func TestIncrementalParams(t *testing.T) {
+	cases := []struct {
+		name    string
+		speed   float64 // 配置中的默认语速
+		opts    models.SynthesisOptions
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{"默认参数", 1, models.SynthesisOptions{},
+			map[string]interface{}{"format": models.AudioFormatMP3}, false},
+		{"语速和音量换算为整数", 1, models.SynthesisOptions{Speed: 2, Volume: 0.5},
+			map[string]interface{}{"format": models.AudioFormatMP3, "speech_rate": 100, "loudness_rate": -50}, false},
+		{"使用配置中的默认语速", 1.2, models.SynthesisOptions{},
+			map[string]interface{}{"format": models.AudioFormatMP3, "speech_rate": 20}, false},
+		{"情感", 1, models.SynthesisOptions{Style: "happy", StyleDegree: 3, Format: models.AudioFormatPCM},
+			map[string]interface{}{"format": models.AudioFormatPCM, "emotion": "happy", "emotion_scale": 3.0}, false},
+		{"语速超出范围", 1, models.SynthesisOptions{Speed: 3}, nil, true},
+		{"不支持 wav", 1, models.SynthesisOptions{Format: models.AudioFormatWAV}, nil, true},
+		{"不支持 SSML", 1, models.SynthesisOptions{TextType: models.TextTypeSSML}, nil, true},
+		{"不支持 pitch", 1, models.SynthesisOptions{Pitch: 1.2}, nil, true},
+		{"不支持 language", 1, models.SynthesisOptions{Language: "en-US"}, nil, true},
+	}
+	for _, tc := range cases {
+		v := newTestTTS()
+		v.speedRatio = tc.speed
+		params, err := v.incrementalParams(tc.opts)
+		if tc.wantErr {
+			assert.ErrorIs(t, err, models.ErrUnsupportedOption, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, "BV700_streaming", params["speaker"], tc.name)
+		assert.Equal(t, tc.want, params["audio_params"], tc.name)
+	}
+}
+
+// eventMessage 构建服务端返回的事件消息
+func eventMessage(event protocol.Event, sessionID string, payload string) *protocol.Message {
+	msgType := protocol.MsgFullServerResponse
+	if event == protocol.EventTTSResponse {
+		msgType = protocol.MsgAudioOnlyResponse
+	}
+	return &protocol.Message{
+		Type:          msgType,
+		Flags:         protocol.FlagWithEvent,
+		Serialization: protocol.SerializationJSON,
+		Event:         event,
+		SessionID:     sessionID,
+		ConnectID:     "connect-1",
+		Payload:       []byte(payload),
+	}
+}
+
+// serverHandshake 模拟服务端建立连接和会话，返回会话 ID 和 StartSession 的请求体
+func serverHandshake(t *testing.T, conn *websocket.Conn) (string, map[string]interface{}) {
+	msg := readMessage(t, conn)
+	assert.Equal(t, protocol.EventStartConnection, msg.Event)
+	sendMessage(t, conn, eventMessage(protocol.EventConnectionStarted, "", "{}"))
+
+	msg = readMessage(t, conn)
+	assert.Equal(t, protocol.EventStartSession, msg.Event)
+	var request map[string]interface{}
+	assert.NoError(t, json.Unmarshal(msg.Payload, &request))
+	sendMessage(t, conn, eventMessage(protocol.EventSessionStarted, msg.SessionID, "{}"))
+	return msg.SessionID, request
+}
+
+func TestIncrementalSession(t *testing.T) {
+	finished := make(chan struct{})
+	v := newTestTTS()
+	v.bidirectionURL = newTestServer(t, func(conn *websocket.Conn, header http.Header) {
+		defer close(finished)
+		assert.Equal(t, "app", header.Get("X-Api-App-Key"))
+		assert.Equal(t, "token", header.Get("X-Api-Access-Key"))
+		assert.Equal(t, "volc.service_type.10029", header.Get("X-Api-Resource-Id"))
+
+		sessionID, request := serverHandshake(t, conn)
+		assert.Equal(t, namespace, request["namespace"])
+		assert.Equal(t, "BV700_streaming", request["req_params"].(map[string]interface{})["speaker"])
+
+		// 每段文本返回同样内容的音频，收到 FinishSession 后结束会话
+		for {
+			msg := readMessage(t, conn)
+			assert.Equal(t, sessionID, msg.SessionID)
+			switch msg.Event {
+			case protocol.EventTaskRequest:
+				var task struct {
+					ReqParams struct {
+						Text string `json:"text"`
+					} `json:"req_params"`
+				}
+				assert.NoError(t, json.Unmarshal(msg.Payload, &task))
+				sendMessage(t, conn, eventMessage(protocol.EventTTSSentenceStart, sessionID, "{}"))
+				sendMessage(t, conn, eventMessage(protocol.EventTTSResponse, sessionID, task.ReqParams.Text))
+				sendMessage(t, conn, eventMessage(protocol.EventTTSSentenceEnd, sessionID, "{}"))
+			case protocol.EventFinishSession:
+				sendMessage(t, conn, eventMessage(protocol.EventSessionFinished, sessionID, `{"status_code":20000000}`))
+				assert.Equal(t, protocol.EventFinishConnection, readMessage(t, conn).Event)
+				return
+			default:
+				t.Errorf("未预期的事件: %d", msg.Event)
+				return
+			}
+		}
+	})
+
+	stream, err := v.StartIncremental(context.Background(), models.SynthesisOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Write("你好，"))
+	assert.NoError(t, stream.Write(""))
+	assert.NoError(t, stream.Write("世界。"))
+	assert.NoError(t, stream.Finish())
+	assert.NoError(t, stream.Finish())
+	assert.Error(t, stream.Write("多余的文本"))
+
+	var (
+		received  string
+		sentences int
+	)
+	for chunk := range stream.Audio() {
+		assert.NoError(t, chunk.Err)
+		received += string(chunk.Data)
+		// 每句合成完毕时返回一个不带音频的标记
+		if chunk.SentenceEnd {
+			assert.Empty(t, chunk.Data)
+			sentences++
+		}
+	}
+	assert.Equal(t, "你好，世界。", received)
+	assert.Equal(t, 2, sentences)
+	<-finished
+}
+
+func TestIncrementalSessionFailedStatus(t *testing.T) {
+	v := newTestTTS()
+	v.bidirectionURL = newTestServer(t, func(conn *websocket.Conn, header http.Header) {
+		sessionID, _ := serverHandshake(t, conn)
+		readMessage(t, conn)
+		sendMessage(t, conn, eventMessage(protocol.EventSessionFinished, sessionID, `{"status_code":45000001,"message":"quota exceeded"}`))
+		readMessage(t, conn)
+	})
+
+	stream, err := v.StartIncremental(context.Background(), models.SynthesisOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Finish())
+
+	chunk, ok := <-stream.Audio()
+	assert.True(t, ok)
+	assert.Error(t, chunk.Err)
+	assert.Contains(t, chunk.Err.Error(), "quota exceeded")
+}
+
+func TestIncrementalHandshakeRejected(t *testing.T) {
+	v := newTestTTS()
+	v.bidirectionURL = newTestServer(t, func(conn *websocket.Conn, header http.Header) {
+		readMessage(t, conn)
+		sendMessage(t, conn, eventMessage(protocol.EventConnectionStarted, "", "{}"))
+		msg := readMessage(t, conn)
+		sendMessage(t, conn, eventMessage(protocol.EventSessionFailed, msg.SessionID, `{"message":"speaker not found"}`))
+	})
+
+	_, err := v.StartIncremental(context.Background(), models.SynthesisOptions{Voice: "unknown"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "speaker not found")
+}
+
+func TestIncrementalAbort(t *testing.T) {
+	closed := make(chan struct{})
+	v := newTestTTS()
+	v.bidirectionURL = newTestServer(t, func(conn *websocket.Conn, header http.Header) {
+		defer close(closed)
+		serverHandshake(t, conn)
+		// 服务端不再响应，Abort 关闭连接后读取返回错误
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+
+	stream, err := v.StartIncremental(context.Background(), models.SynthesisOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Write("你好"))
+	stream.Abort()
+
+	// 被放弃的合成不返回错误，音频通道直接关闭
+	_, ok := <-stream.Audio()
+	assert.False(t, ok)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Abort 后连接未关闭")
+	}
+}
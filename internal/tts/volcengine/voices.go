@@ -0,0 +1,51 @@
+package volcengine
+
+import (
+	"context"
+
+	"github.com/telepace/voiceflow/internal/models"
+)
+
+// builtinVoices 火山引擎没有公开的音色查询接口，这里维护常用音色的列表
+// 完整列表见控制台的音色管理页面，配置中的 voice_type 不在列表中时也会被加入
+var builtinVoices = []models.Voice{
+	{ID: "BV001_streaming", Name: "通用女声", Language: "zh-CN", Gender: models.GenderFemale},
+	{ID: "BV002_streaming", Name: "通用男声", Language: "zh-CN", Gender: models.GenderMale},
+	{ID: "BV700_streaming", Name: "灿灿", Language: "zh-CN", Languages: []string{"en-US"}, Gender: models.GenderFemale,
+		Styles: []string{"pleased", "sorry", "annoyed", "customer_service", "professional", "serious", "happy", "sad", "angry", "scare", "hate", "surprise", "tear", "novel_dialog", "narrator", "narrator_immersive", "comfort", "lovey-dovey", "energetic", "conniving", "tsundere", "charming", "storytelling", "radio", "yoga", "advertising", "assistant", "chat"}},
+	{ID: "BV705_streaming", Name: "炀炀", Language: "zh-CN", Gender: models.GenderMale,
+		Styles: []string{"chat", "pleased", "sorry", "annoyed", "comfort", "customer_service", "professional", "serious", "happy", "sad", "angry", "surprise", "tear"}},
+	{ID: "BV406_streaming", Name: "梓梓", Language: "zh-CN", Gender: models.GenderFemale},
+	{ID: "BV407_streaming", Name: "燃燃", Language: "zh-CN", Gender: models.GenderMale},
+	{ID: "BV034_streaming", Name: "知性姐姐", Language: "zh-CN", Gender: models.GenderFemale},
+	{ID: "BV503_streaming", Name: "Ariana", Language: "en-US", Gender: models.GenderFemale},
+	{ID: "BV504_streaming", Name: "Jackson", Language: "en-US", Gender: models.GenderMale},
+	{ID: "BV522_streaming", Name: "日语女声", Language: "ja-JP", Gender: models.GenderFemale},
+}
+
+// findVoice 在内置列表中查找音色
+func findVoice(id string) (models.Voice, bool) {
+	for _, voice := range builtinVoices {
+		if voice.ID == id {
+			return voice, true
+		}
+	}
+	return models.Voice{}, false
+}
+
+// ListVoices 返回内置的常用音色列表
+func (v *VolcengineTTS) ListVoices(ctx context.Context) ([]models.Voice, error) {
+	voices := make([]models.Voice, 0, len(builtinVoices)+1)
+	configured := v.voiceType == ""
+	for _, voice := range builtinVoices {
+		voice.Provider = "volcengine"
+		voices = append(voices, voice)
+		if voice.ID == v.voiceType {
+			configured = true
+		}
+	}
+	if !configured {
+		voices = append(voices, models.Voice{ID: v.voiceType, Name: v.voiceType, Provider: "volcengine"})
+	}
+	return voices, nil
+}
@@ -2,20 +2,25 @@ package volcengine
 
 import (
 	"bytes"
-	"compress/gzip"
-	"encoding/binary"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/tts/ssml"
+	"github.com/telepace/voiceflow/internal/volcengine/protocol"
 	"github.com/telepace/voiceflow/pkg/config"
 	"github.com/telepace/voiceflow/pkg/logger"
 )
 
+// streamBufferSize 流式合成时缓冲的音频包数量
+const streamBufferSize = 32
+
 type VolcengineTTS struct {
 	wsURL      string
 	appID      string
@@ -26,6 +31,15 @@ type VolcengineTTS struct {
 	speedRatio float64
 	volume     float64
 	pitch      float64
+
+	// 多情感音色的默认情感，请求中的 style、style_degree 优先
+	emotion      string
+	emotionScale float64
+
+	// 双向流式合成使用 v3 接口
+	bidirectionURL string
+	resourceID     string
+	sampleRate     int
 }
 
 func NewVolcengineTTS() *VolcengineTTS {
@@ -45,14 +59,70 @@ func NewVolcengineTTS() *VolcengineTTS {
 		speedRatio: ttsCfg.SpeedRatio,
 		volume:     ttsCfg.VolumeRatio,
 		pitch:      ttsCfg.PitchRatio,
+
+		emotion:      ttsCfg.Emotion,
+		emotionScale: ttsCfg.EmotionScale,
+
+		bidirectionURL: ttsCfg.BidirectionURL,
+		resourceID:     ttsCfg.ResourceID,
+		sampleRate:     ttsCfg.SampleRate,
 	}
 }
 
-func (v *VolcengineTTS) Synthesize(text string) ([]byte, error) {
+// Synthesize 合成完整的音频后一次性返回
+func (v *VolcengineTTS) Synthesize(text string, opts models.SynthesisOptions) ([]byte, error) {
+	return v.SynthesizeContext(context.Background(), text, opts)
+}
+
+// SynthesizeContext 实现 tts.ContextService 接口，ctx 取消时关闭连接
+func (v *VolcengineTTS) SynthesizeContext(ctx context.Context, text string, opts models.SynthesisOptions) ([]byte, error) {
+	params, err := v.buildParams(text, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var audioBuffer bytes.Buffer
+	err = v.synthesize(ctx, params, func(audio []byte) error {
+		audioBuffer.Write(audio)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return audioBuffer.Bytes(), nil
+}
+
+// SynthesizeStream 在收到每一包音频时立即通过通道返回，便于客户端边收边播
+func (v *VolcengineTTS) SynthesizeStream(ctx context.Context, text string, opts models.SynthesisOptions) (<-chan models.AudioChunk, error) {
+	params, err := v.buildParams(text, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan models.AudioChunk, streamBufferSize)
+	go func() {
+		defer close(chunks)
+		err := v.synthesize(ctx, params, func(audio []byte) error {
+			select {
+			case chunks <- models.AudioChunk{Data: audio}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			chunks <- models.AudioChunk{Err: err}
+		}
+	}()
+	return chunks, nil
+}
+
+// synthesize 发送合成请求，并将服务端返回的每一包音频交给 onAudio 处理
+func (v *VolcengineTTS) synthesize(ctx context.Context, params map[string]map[string]interface{}, onAudio func([]byte) error) error {
 	// 构建 WebSocket URL
 	u, err := url.Parse(v.wsURL)
 	if err != nil {
-		return nil, fmt.Errorf("invalid WebSocket URL: %v", err)
+		return fmt.Errorf("invalid WebSocket URL: %v", err)
 	}
 
 	// 设置请求头
@@ -61,180 +131,237 @@ func (v *VolcengineTTS) Synthesize(text string) ([]byte, error) {
 	}
 
 	// 建立 WebSocket 连接
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), header)
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), header)
 	if err != nil {
-		return nil, fmt.Errorf("WebSocket连接失败: %v", err)
+		return fmt.Errorf("WebSocket连接失败: %v", err)
 	}
 	defer conn.Close()
 
-	// 修改请求参数
-	params := map[string]map[string]interface{}{
-		"app": {
-			"appid":   v.appID,
-			"token":   v.token,
-			"cluster": v.cluster,
-		},
-		"user": {
-			"uid": fmt.Sprintf("user_%d", time.Now().UnixNano()),
-		},
-		"audio": {
-			"voice_type":   v.voiceType,
-			"encoding":     v.encoding,
-			"speed_ratio":  v.speedRatio,
-			"volume_ratio": v.volume,
-			"pitch_ratio":  v.pitch,
-		},
-		"request": {
-			"reqid":     generateReqID(),
-			"text":      text,
-			"text_type": "plain",
-			"operation": "submit",
-		},
-	}
+	// ctx 取消时关闭连接，使阻塞中的读取立即返回
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
 
 	// 序列化并压缩请求数据
 	jsonData, err := json.Marshal(params)
 	if err != nil {
-		return nil, fmt.Errorf("JSON序列化失败: %v", err)
+		return fmt.Errorf("JSON序列化失败: %v", err)
 	}
 
-	compressedData := gzipCompress(jsonData)
-
-	// 构建二进制消息头
-	message := buildMessage(compressedData)
-
 	// 发送请求
-	if err := conn.WriteMessage(websocket.BinaryMessage, message); err != nil {
-		return nil, fmt.Errorf("发送请求失败: %v", err)
+	if err := writeMessage(conn, &protocol.Message{
+		Type:          protocol.MsgFullClientRequest,
+		Serialization: protocol.SerializationJSON,
+		Compression:   protocol.CompressionGzip,
+		Payload:       jsonData,
+	}); err != nil {
+		return fmt.Errorf("发送请求失败: %v", err)
 	}
 
 	// 修改响应处理
-	var audioBuffer bytes.Buffer
 	for {
 		_, message, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
-				break
+				return nil
 			}
-			return nil, fmt.Errorf("读取响应失败: %v", err)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("读取响应失败: %v", err)
 		}
 
 		// 解析响应
-		resp, err := parseResponse(message)
+		msg, err := protocol.Unmarshal(message)
 		if err != nil {
-			return nil, fmt.Errorf("解析响应失败: %v", err)
+			return fmt.Errorf("解析响应失败: %v", err)
 		}
-
-		// 检查错误
-		if resp.Code != 0 {
-			return nil, fmt.Errorf("服务端错误(code=%d): %s", resp.Code, resp.Message)
+		if err := msg.Err(); err != nil {
+			return err
+		}
+		if msg.Type != protocol.MsgAudioOnlyResponse {
+			continue
 		}
 
-		// 如果有音频数据,追加到 buffer
-		if len(resp.Audio) > 0 {
-			audioBuffer.Write(resp.Audio)
+		// 如果有音频数据,交给调用方处理
+		if len(msg.Payload) > 0 {
+			if err := onAudio(msg.Payload); err != nil {
+				return err
+			}
 		}
 
 		// 如果是最后一包数据,退出循环
-		if resp.IsLast {
-			break
+		if msg.IsLast() {
+			return nil
 		}
 	}
-
-	return audioBuffer.Bytes(), nil
 }
 
-// 工具函数
-func generateReqID() string {
-	return fmt.Sprintf("req_%d", time.Now().UnixNano())
-}
+// buildParams 构建合成请求参数，SSML 校验通过后以 text_type=ssml 提交
+func (v *VolcengineTTS) buildParams(text string, opts models.SynthesisOptions) (map[string]map[string]interface{}, error) {
+	audioParams, err := v.audioParams(opts)
+	if err != nil {
+		return nil, err
+	}
+	textType := models.TextTypePlain
+	if opts.IsSSML() {
+		if _, err := ssml.Parse(text); err != nil {
+			return nil, err
+		}
+		textType = models.TextTypeSSML
+	}
 
-func gzipCompress(input []byte) []byte {
-	var b bytes.Buffer
-	w := gzip.NewWriter(&b)
-	w.Write(input)
-	w.Close()
-	return b.Bytes()
+	return map[string]map[string]interface{}{
+		"app": {
+			"appid":   v.appID,
+			"token":   v.token,
+			"cluster": v.cluster,
+		},
+		"user": {
+			"uid": fmt.Sprintf("user_%d", time.Now().UnixNano()),
+		},
+		"audio": audioParams,
+		"request": {
+			"reqid":     generateReqID(),
+			"text":      text,
+			"text_type": textType,
+			"operation": "submit",
+		},
+	}, nil
 }
 
-func buildMessage(payload []byte) []byte {
-	header := []byte{0x11, 0x10, 0x11, 0x00} // 默认消息头
-	payloadSize := make([]byte, 4)
-	binary.BigEndian.PutUint32(payloadSize, uint32(len(payload)))
-
-	message := make([]byte, 0, len(header)+len(payloadSize)+len(payload))
-	message = append(message, header...)
-	message = append(message, payloadSize...)
-	message = append(message, payload...)
+// audioParams 将合成参数映射为请求中的 audio 字段，未指定的参数使用配置中的默认值
+func (v *VolcengineTTS) audioParams(opts models.SynthesisOptions) (map[string]interface{}, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	// 火山引擎的语种由音色决定，不能单独指定
+	if opts.Language != "" {
+		return nil, models.UnsupportedOption("火山引擎 TTS 的语种由音色决定，请通过 voice 指定音色")
+	}
+	voiceType := v.voiceType
+	if opts.Voice != "" {
+		voiceType = opts.Voice
+	}
+	emotion, emotionScale, err := v.emotionParams(voiceType, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	return message
-}
+	params := map[string]interface{}{
+		"voice_type":   voiceType,
+		"encoding":     v.encoding,
+		"speed_ratio":  v.speedRatio,
+		"volume_ratio": v.volume,
+		"pitch_ratio":  v.pitch,
+	}
+	if emotion != "" {
+		params["enable_emotion"] = true
+		params["emotion"] = emotion
+		if emotionScale != 0 {
+			params["emotion_scale"] = emotionScale
+		}
+	}
+	if opts.Format != "" {
+		params["encoding"] = opts.Format
+	}
 
-// 添加响应结构
-type Response struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Audio   []byte
-	IsLast  bool
+	ratios := []struct {
+		key      string
+		value    float64
+		min, max float64
+	}{
+		{"speed_ratio", opts.Speed, 0.2, 3},
+		{"volume_ratio", opts.Volume, 0.1, 3},
+		{"pitch_ratio", opts.Pitch, 0.1, 3},
+	}
+	for _, r := range ratios {
+		if r.value == 0 {
+			continue
+		}
+		if r.value < r.min || r.value > r.max {
+			return nil, models.UnsupportedOption("火山引擎 TTS 的 %s 取值范围为 %g 到 %g", r.key, r.min, r.max)
+		}
+		params[r.key] = r.value
+	}
+	return params, nil
 }
 
-func parseResponse(res []byte) (*Response, error) {
-	if len(res) < 4 {
-		return nil, fmt.Errorf("响应数据长度不足")
+// emotionParams 将 style、style_degree 映射为多情感音色的 emotion 和 emotion_scale
+// 内置列表中的音色会校验是否支持该情感，其他音色交给服务端校验；
+// 配置中的默认情感只用于支持该情感的音色
+func (v *VolcengineTTS) emotionParams(voiceType string, opts models.SynthesisOptions) (string, float64, error) {
+	if opts.Role != "" {
+		return "", 0, models.UnsupportedOption("火山引擎 TTS 不支持 role 参数")
 	}
-
-	// 解析二进制协议头
-	// protoVersion := res[0] >> 4
-	headSize := res[0] & 0x0f
-	messageType := res[1] >> 4
-	messageTypeSpecificFlags := res[1] & 0x0f
-	// serializationMethod := res[2] >> 4
-	messageCompression := res[2] & 0x0f
-	payload := res[headSize*4:]
-
-	resp := &Response{}
-
-	// audio-only server response
-	if messageType == 0xb {
-		if messageTypeSpecificFlags == 0 {
-			return resp, nil
+	emotion, scale := v.emotion, v.emotionScale
+	if opts.Style != "" {
+		emotion = opts.Style
+	}
+	if opts.StyleDegree != 0 {
+		scale = opts.StyleDegree
+	}
+	if emotion == "" {
+		if opts.StyleDegree != 0 {
+			return "", 0, models.UnsupportedOption("指定 style_degree 时必须同时指定 style")
 		}
+		return "", 0, nil
+	}
+	if scale != 0 && (scale < 1 || scale > 5) {
+		return "", 0, models.UnsupportedOption("火山引擎 TTS 的 style_degree 取值范围为 1 到 5")
+	}
 
-		sequenceNumber := int32(binary.BigEndian.Uint32(payload[0:4]))
-		// payloadSize := int32(binary.BigEndian.Uint32(payload[4:8]))
-		resp.Audio = append(resp.Audio, payload[8:]...)
-
-		if sequenceNumber < 0 {
-			resp.IsLast = true
+	if voice, ok := findVoice(voiceType); ok && !contains(voice.Styles, emotion) {
+		if opts.Style == "" {
+			return "", 0, nil
+		}
+		if len(voice.Styles) == 0 {
+			return "", 0, models.UnsupportedOption("音色 %s 不支持多情感", voiceType)
 		}
-		return resp, nil
+		return "", 0, models.UnsupportedOption("音色 %s 不支持情感 %s，可选值为 %s", voiceType, emotion, strings.Join(voice.Styles, "、"))
 	}
+	return emotion, scale, nil
+}
 
-	// error response
-	if messageType == 0xf {
-		code := int32(binary.BigEndian.Uint32(payload[0:4]))
-		errMsg := payload[8:]
-		if messageCompression == 1 {
-			var err error
-			errMsg, err = gzipDecompress(errMsg)
-			if err != nil {
-				return nil, fmt.Errorf("解压错误消息失败: %v", err)
-			}
+// 工具函数
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
 		}
-		resp.Code = int(code)
-		resp.Message = string(errMsg)
-		return resp, fmt.Errorf("服务端错误(code=%d): %s", code, errMsg)
 	}
+	return false
+}
 
-	return nil, fmt.Errorf("未知的消息类型: %d", messageType)
+func generateReqID() string {
+	return fmt.Sprintf("req_%d", time.Now().UnixNano())
 }
 
-func gzipDecompress(input []byte) ([]byte, error) {
-	reader, err := gzip.NewReader(bytes.NewReader(input))
+func writeMessage(conn *websocket.Conn, msg *protocol.Message) error {
+	data, err := msg.Marshal()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer reader.Close()
+	return conn.WriteMessage(websocket.BinaryMessage, data)
+}
 
-	return io.ReadAll(reader)
+// SynthesisDefaults 返回请求未指定时使用的配置，用于计算缓存键
+func (v *VolcengineTTS) SynthesisDefaults() map[string]string {
+	return map[string]string{
+		"cluster":       v.cluster,
+		"voice_type":    v.voiceType,
+		"encoding":      v.encoding,
+		"speed_ratio":   fmt.Sprint(v.speedRatio),
+		"volume_ratio":  fmt.Sprint(v.volume),
+		"pitch_ratio":   fmt.Sprint(v.pitch),
+		"emotion":       v.emotion,
+		"emotion_scale": fmt.Sprint(v.emotionScale),
+		"sample_rate":   fmt.Sprint(v.sampleRate),
+	}
 }
@@ -0,0 +1,173 @@
+package volcengine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/telepace/voiceflow/internal/models"
+	"github.com/telepace/voiceflow/internal/volcengine/protocol"
+)
+
+func newTestTTS() *VolcengineTTS {
+	return &VolcengineTTS{
+		appID:      "app",
+		token:      "token",
+		cluster:    "volcano_tts",
+		voiceType:  "BV700_streaming",
+		encoding:   models.AudioFormatMP3,
+		speedRatio: 1,
+		volume:     1,
+		pitch:      1,
+		resourceID: "volc.service_type.10029",
+	}
+}
+
+// newTestServer 启动一个 WebSocket 服务端，handle 中按协议收发消息
+func newTestServer(t *testing.T, handle func(conn *websocket.Conn, header http.Header)) string {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		handle(conn, r.Header)
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) *protocol.Message {
+	_, data, err := conn.ReadMessage()
+	if !assert.NoError(t, err) {
+		return &protocol.Message{}
+	}
+	msg, err := protocol.Unmarshal(data)
+	assert.NoError(t, err)
+	return msg
+}
+
+func sendMessage(t *testing.T, conn *websocket.Conn, msg *protocol.Message) {
+	assert.NoError(t, writeMessage(conn, msg))
+}
+
+func TestEmotionParams(t *testing.T) {
+	cases := []struct {
+		name      string
+		emotion   string // 配置中的默认情感
+		voice     string
+		opts      models.SynthesisOptions
+		want      string
+		wantScale float64
+		wantErr   bool
+	}{
+		{"未指定情感", "", "BV700_streaming", models.SynthesisOptions{}, "", 0, false},
+		{"请求指定情感", "", "BV700_streaming", models.SynthesisOptions{Style: "happy", StyleDegree: 2}, "happy", 2, false},
+		{"使用默认情感", "sad", "BV705_streaming", models.SynthesisOptions{}, "sad", 0, false},
+		{"请求优先于默认情感", "sad", "BV700_streaming", models.SynthesisOptions{Style: "angry"}, "angry", 0, false},
+		{"音色不支持默认情感时忽略", "yoga", "BV705_streaming", models.SynthesisOptions{}, "", 0, false},
+		{"音色不支持请求的情感", "", "BV705_streaming", models.SynthesisOptions{Style: "yoga"}, "", 0, true},
+		{"音色不支持多情感", "", "BV001_streaming", models.SynthesisOptions{Style: "happy"}, "", 0, true},
+		{"未知音色交给服务端校验", "", "custom_voice", models.SynthesisOptions{Style: "whisper"}, "whisper", 0, false},
+		{"只指定强度", "", "BV700_streaming", models.SynthesisOptions{StyleDegree: 2}, "", 0, true},
+		{"强度超出范围", "", "BV700_streaming", models.SynthesisOptions{Style: "happy", StyleDegree: 6}, "", 0, true},
+		{"不支持 role", "", "BV700_streaming", models.SynthesisOptions{Role: "Girl"}, "", 0, true},
+	}
+	for _, tc := range cases {
+		v := newTestTTS()
+		v.emotion = tc.emotion
+		emotion, scale, err := v.emotionParams(tc.voice, tc.opts)
+		if tc.wantErr {
+			assert.ErrorIs(t, err, models.ErrUnsupportedOption, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.want, emotion, tc.name)
+		assert.Equal(t, tc.wantScale, scale, tc.name)
+	}
+}
+
+func TestAudioParams(t *testing.T) {
+	v := newTestTTS()
+	params, err := v.audioParams(models.SynthesisOptions{Voice: "BV705_streaming", Speed: 1.5, Style: "chat", Format: models.AudioFormatPCM})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"voice_type":     "BV705_streaming",
+		"encoding":       models.AudioFormatPCM,
+		"speed_ratio":    1.5,
+		"volume_ratio":   1.0,
+		"pitch_ratio":    1.0,
+		"enable_emotion": true,
+		"emotion":        "chat",
+	}, params)
+
+	_, err = v.audioParams(models.SynthesisOptions{Language: "en-US"})
+	assert.ErrorIs(t, err, models.ErrUnsupportedOption)
+	_, err = v.audioParams(models.SynthesisOptions{Speed: 4})
+	assert.ErrorIs(t, err, models.ErrUnsupportedOption)
+}
+
+func TestSynthesize(t *testing.T) {
+	v := newTestTTS()
+	v.wsURL = newTestServer(t, func(conn *websocket.Conn, header http.Header) {
+		assert.Equal(t, "Bearer;token", header.Get("Authorization"))
+
+		req := readMessage(t, conn)
+		assert.Equal(t, protocol.MsgFullClientRequest, req.Type)
+		var params map[string]map[string]interface{}
+		assert.NoError(t, json.Unmarshal(req.Payload, &params))
+		assert.Equal(t, "volcano_tts", params["app"]["cluster"])
+		assert.Equal(t, "你好", params["request"]["text"])
+		assert.Equal(t, "BV700_streaming", params["audio"]["voice_type"])
+
+		// 先返回一条非音频消息，再返回两包音频，负序号表示最后一包
+		sendMessage(t, conn, &protocol.Message{Type: protocol.MsgFrontEndResult, Serialization: protocol.SerializationJSON, Payload: []byte(`{}`)})
+		sendMessage(t, conn, &protocol.Message{Type: protocol.MsgAudioOnlyResponse, Flags: protocol.FlagPositiveSequence, Sequence: 1, Payload: []byte("ab")})
+		sendMessage(t, conn, &protocol.Message{Type: protocol.MsgAudioOnlyResponse, Flags: protocol.FlagNegativeSequence, Sequence: -2, Payload: []byte("cd")})
+	})
+
+	data, err := v.Synthesize("你好", models.SynthesisOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("abcd"), data)
+}
+
+func TestSynthesizeServerError(t *testing.T) {
+	v := newTestTTS()
+	v.wsURL = newTestServer(t, func(conn *websocket.Conn, header http.Header) {
+		readMessage(t, conn)
+		sendMessage(t, conn, &protocol.Message{Type: protocol.MsgError, ErrorCode: 3001, Payload: []byte("invalid voice_type")})
+	})
+
+	_, err := v.Synthesize("你好", models.SynthesisOptions{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "3001")
+}
+
+func TestSynthesizeStream(t *testing.T) {
+	v := newTestTTS()
+	v.wsURL = newTestServer(t, func(conn *websocket.Conn, header http.Header) {
+		readMessage(t, conn)
+		for i, payload := range []string{"a", "b", "c"} {
+			msg := &protocol.Message{Type: protocol.MsgAudioOnlyResponse, Flags: protocol.FlagPositiveSequence, Sequence: int32(i + 1), Payload: []byte(payload)}
+			if i == 2 {
+				msg.Flags, msg.Sequence = protocol.FlagNegativeSequence, -3
+			}
+			sendMessage(t, conn, msg)
+		}
+	})
+
+	chunks, err := v.SynthesizeStream(context.Background(), "你好", models.SynthesisOptions{})
+	assert.NoError(t, err)
+	var received []string
+	for chunk := range chunks {
+		assert.NoError(t, chunk.Err)
+		received = append(received, string(chunk.Data))
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, received)
+}
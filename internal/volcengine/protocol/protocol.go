@@ -0,0 +1,292 @@
+// Package protocol 实现火山引擎语音服务（ASR、TTS）共用的 WebSocket 二进制协议
+//
+// 每条消息由 4 字节头部和可选字段组成：
+//
+//	byte 0: 协议版本(4 bit) | 头部长度/4(4 bit)
+//	byte 1: 消息类型(4 bit) | 消息类型标志(4 bit)
+//	byte 2: 序列化方式(4 bit) | 压缩方式(4 bit)
+//	byte 3: 保留
+//
+// 头部之后依次为事件号和会话 ID（带事件标志时）、序列号（带序列号标志时）或错误码（错误消息），
+// 最后是 4 字节的负载长度和负载
+package protocol
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const version byte = 0x1
+
+// MessageType 消息类型
+type MessageType byte
+
+const (
+	MsgFullClientRequest  MessageType = 0x1
+	MsgAudioOnlyRequest   MessageType = 0x2
+	MsgFullServerResponse MessageType = 0x9
+	MsgAudioOnlyResponse  MessageType = 0xB
+	MsgFrontEndResult     MessageType = 0xC
+	MsgError              MessageType = 0xF
+)
+
+// 消息类型标志
+const (
+	FlagNoSequence       byte = 0x0
+	FlagPositiveSequence byte = 0x1
+	FlagLastNoSequence   byte = 0x2
+	FlagNegativeSequence byte = 0x3
+	// FlagWithEvent 双向流式协议的消息带有事件号
+	FlagWithEvent byte = 0x4
+)
+
+// 序列化方式
+const (
+	SerializationNone byte = 0x0
+	SerializationJSON byte = 0x1
+)
+
+// 压缩方式
+const (
+	CompressionNone byte = 0x0
+	CompressionGzip byte = 0x1
+)
+
+// Event 双向流式协议中的事件号
+type Event int32
+
+const (
+	EventStartConnection    Event = 1
+	EventFinishConnection   Event = 2
+	EventConnectionStarted  Event = 50
+	EventConnectionFailed   Event = 51
+	EventConnectionFinished Event = 52
+	EventStartSession       Event = 100
+	EventCancelSession      Event = 101
+	EventFinishSession      Event = 102
+	EventSessionStarted     Event = 150
+	EventSessionCanceled    Event = 151
+	EventSessionFinished    Event = 152
+	EventSessionFailed      Event = 153
+	EventTaskRequest        Event = 200
+	EventTTSSentenceStart   Event = 350
+	EventTTSSentenceEnd     Event = 351
+	EventTTSResponse        Event = 352
+)
+
+// connectionEvent 连接级事件不带会话 ID
+func (e Event) connectionEvent() bool {
+	switch e {
+	case EventStartConnection, EventFinishConnection,
+		EventConnectionStarted, EventConnectionFailed, EventConnectionFinished:
+		return true
+	}
+	return false
+}
+
+// hasConnectID 服务端的连接级事件带有连接 ID
+func (e Event) hasConnectID() bool {
+	return e == EventConnectionStarted || e == EventConnectionFailed || e == EventConnectionFinished
+}
+
+// Message 为一条协议消息，Payload 始终为解压后的数据
+type Message struct {
+	Type          MessageType
+	Flags         byte
+	Serialization byte
+	Compression   byte
+
+	Event     Event
+	SessionID string
+	ConnectID string
+	Sequence  int32
+	ErrorCode uint32
+	Payload   []byte
+}
+
+func (m *Message) hasSequence() bool {
+	seq := m.Flags & 0x3
+	return m.Type != MsgError && (seq == FlagPositiveSequence || seq == FlagNegativeSequence)
+}
+
+// HasEvent 判断消息是否带有事件号
+func (m *Message) HasEvent() bool {
+	return m.Flags&FlagWithEvent != 0
+}
+
+// IsLast 判断是否为服务端的最后一包数据
+func (m *Message) IsLast() bool {
+	seq := m.Flags & 0x3
+	return seq == FlagLastNoSequence || seq == FlagNegativeSequence || m.Sequence < 0
+}
+
+// Err 将错误消息转换为 error，其他消息返回 nil
+func (m *Message) Err() error {
+	if m.Type != MsgError {
+		return nil
+	}
+	return fmt.Errorf("服务端错误(code=%d): %s", m.ErrorCode, m.Payload)
+}
+
+// DecodeJSON 将 JSON 负载解析到 v
+func (m *Message) DecodeJSON(v interface{}) error {
+	if err := json.Unmarshal(m.Payload, v); err != nil {
+		return fmt.Errorf("解析消息负载失败: %v, 负载内容: %s", err, m.Payload)
+	}
+	return nil
+}
+
+// Marshal 按协议编码消息，Compression 为 gzip 时负载会被压缩
+func (m *Message) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write([]byte{
+		version<<4 | 1,
+		byte(m.Type)<<4 | m.Flags&0x0f,
+		m.Serialization<<4 | m.Compression&0x0f,
+		0,
+	})
+
+	if m.HasEvent() {
+		writeInt32(&buf, int32(m.Event))
+		if !m.Event.connectionEvent() {
+			writeString(&buf, m.SessionID)
+		}
+		if m.Event.hasConnectID() {
+			writeString(&buf, m.ConnectID)
+		}
+	}
+	if m.hasSequence() {
+		writeInt32(&buf, m.Sequence)
+	}
+	if m.Type == MsgError {
+		writeInt32(&buf, int32(m.ErrorCode))
+	}
+
+	payload := m.Payload
+	if m.Compression == CompressionGzip {
+		var err error
+		if payload, err = gzipCompress(payload); err != nil {
+			return nil, fmt.Errorf("压缩消息负载失败: %v", err)
+		}
+	}
+	writeInt32(&buf, int32(len(payload)))
+	buf.Write(payload)
+	return buf.Bytes(), nil
+}
+
+// Unmarshal 解析一条协议消息并解压负载
+func Unmarshal(data []byte) (*Message, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("消息长度不足: %d 字节", len(data))
+	}
+	headerSize := int(data[0]&0x0f) * 4
+	if headerSize < 4 || len(data) < headerSize {
+		return nil, fmt.Errorf("消息头部长度无效: %d", headerSize)
+	}
+	m := &Message{
+		Type:          MessageType(data[1] >> 4),
+		Flags:         data[1] & 0x0f,
+		Serialization: data[2] >> 4,
+		Compression:   data[2] & 0x0f,
+	}
+	r := &reader{data: data[headerSize:]}
+
+	if m.HasEvent() {
+		m.Event = Event(r.int32())
+		if !m.Event.connectionEvent() {
+			m.SessionID = r.string()
+		}
+		if m.Event.hasConnectID() {
+			m.ConnectID = r.string()
+		}
+	}
+	if m.hasSequence() {
+		m.Sequence = r.int32()
+	}
+	if m.Type == MsgError {
+		m.ErrorCode = uint32(r.int32())
+	}
+	// 部分确认消息只有头部，没有负载
+	if r.err == nil && len(r.data) > 0 {
+		m.Payload = r.bytes(int(r.int32()))
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if m.Compression == CompressionGzip && len(m.Payload) > 0 {
+		payload, err := gzipDecompress(m.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("解压消息负载失败: %v", err)
+		}
+		m.Payload = payload
+	}
+	return m, nil
+}
+
+func writeInt32(buf *bytes.Buffer, v int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	buf.Write(b[:])
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeInt32(buf, int32(len(s)))
+	buf.WriteString(s)
+}
+
+// reader 按顺序读取消息字段，遇到第一个错误后的读取都返回零值
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = fmt.Errorf("消息长度不足: 需要 %d 字节，剩余 %d 字节", n, len(r.data))
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) int32() int32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (r *reader) string() string {
+	return string(r.bytes(int(r.int32())))
+}
+
+func gzipCompress(input []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write(input); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func gzipDecompress(input []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,110 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalMatchesV1Header(t *testing.T) {
+	m := &Message{
+		Type:          MsgFullClientRequest,
+		Serialization: SerializationJSON,
+		Compression:   CompressionGzip,
+		Payload:       []byte(`{"text":"你好"}`),
+	}
+	data, err := m.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x11, 0x10, 0x11, 0x00}, data[:4])
+
+	decoded, err := Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, m.Payload, decoded.Payload)
+	assert.False(t, decoded.HasEvent())
+}
+
+func TestRoundTripEventMessage(t *testing.T) {
+	m := &Message{
+		Type:          MsgFullClientRequest,
+		Flags:         FlagWithEvent,
+		Serialization: SerializationJSON,
+		Event:         EventTaskRequest,
+		SessionID:     "session-1",
+		Payload:       []byte(`{"req_params":{"text":"hello"}}`),
+	}
+	data, err := m.Marshal()
+	assert.NoError(t, err)
+
+	decoded, err := Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, EventTaskRequest, decoded.Event)
+	assert.Equal(t, "session-1", decoded.SessionID)
+	assert.Equal(t, m.Payload, decoded.Payload)
+}
+
+func TestConnectionEventsCarryConnectID(t *testing.T) {
+	m := &Message{
+		Type:      MsgFullServerResponse,
+		Flags:     FlagWithEvent,
+		Event:     EventConnectionStarted,
+		ConnectID: "conn-1",
+		Payload:   []byte("{}"),
+	}
+	data, err := m.Marshal()
+	assert.NoError(t, err)
+
+	decoded, err := Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "", decoded.SessionID)
+	assert.Equal(t, "conn-1", decoded.ConnectID)
+}
+
+func TestUnmarshalAudioResponse(t *testing.T) {
+	m := &Message{
+		Type:     MsgAudioOnlyResponse,
+		Flags:    FlagNegativeSequence,
+		Sequence: -3,
+		Payload:  []byte{1, 2, 3, 4},
+	}
+	data, err := m.Marshal()
+	assert.NoError(t, err)
+
+	decoded, err := Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(-3), decoded.Sequence)
+	assert.True(t, decoded.IsLast())
+	assert.Equal(t, []byte{1, 2, 3, 4}, decoded.Payload)
+
+	// 只有头部的确认消息
+	ack, err := Unmarshal([]byte{0x11, 0xb0, 0x00, 0x00})
+	assert.NoError(t, err)
+	assert.Empty(t, ack.Payload)
+	assert.False(t, ack.IsLast())
+}
+
+func TestUnmarshalError(t *testing.T) {
+	m := &Message{
+		Type:        MsgError,
+		Compression: CompressionGzip,
+		ErrorCode:   45000001,
+		Payload:     []byte("invalid speaker"),
+	}
+	data, err := m.Marshal()
+	assert.NoError(t, err)
+
+	decoded, err := Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(45000001), decoded.ErrorCode)
+	assert.EqualError(t, decoded.Err(), "服务端错误(code=45000001): invalid speaker")
+}
+
+func TestUnmarshalTruncated(t *testing.T) {
+	data := []byte{0x11, 0x94, 0x10, 0x00}
+	data = binary.BigEndian.AppendUint32(data, uint32(EventSessionStarted))
+	data = binary.BigEndian.AppendUint32(data, 10)
+	data = append(data, "abc"...)
+
+	_, err := Unmarshal(data)
+	assert.Error(t, err)
+}
@@ -64,6 +64,177 @@ type WhisperConfig struct {
 	BatchSize   int     `mapstructure:"batch_size"` // 音频分段大小(秒)
 }
 
+// OpenAICompatibleConfig 用于对接任意实现了 /v1/audio/transcriptions 的 STT 服务
+type OpenAICompatibleConfig struct {
+	BaseURL        string            `mapstructure:"base_url"` // 例如 https://api.openai.com/v1
+	APIKey         string            `mapstructure:"api_key"`
+	Model          string            `mapstructure:"model"`
+	Language       string            `mapstructure:"language"`
+	Task           string            `mapstructure:"task"` // transcribe 或 translate
+	Temperature    float64           `mapstructure:"temperature"`
+	ResponseFormat string            `mapstructure:"response_format"`
+	ExtraFields    map[string]string `mapstructure:"extra_fields"` // 额外的表单字段，如 vad_model、prompt
+	Timeout        int               `mapstructure:"timeout"`      // 请求超时(秒)
+}
+
+// LocalSTTConfig 本地/离线语音识别配置
+type LocalSTTConfig struct {
+	Mode       string   `mapstructure:"mode"`        // command、http 或 websocket
+	Command    string   `mapstructure:"command"`     // command 模式下执行的命令
+	Args       []string `mapstructure:"args"`        // 命令参数，支持 {input}、{model}、{language} 占位符
+	ModelPath  string   `mapstructure:"model_path"`  // 本地模型路径
+	Language   string   `mapstructure:"language"`    // 识别语言
+	HTTPURL    string   `mapstructure:"http_url"`    // http 模式下的识别接口，如 whisper.cpp server 的 /inference
+	WsURL      string   `mapstructure:"ws_url"`      // websocket 模式下的识别服务，如 Vosk server
+	SampleRate int      `mapstructure:"sample_rate"` // 裸 PCM 音频的采样率
+	Timeout    int      `mapstructure:"timeout"`     // 单次识别超时(秒)
+}
+
+// LocalLLMConfig 离线大模型配置，对接 Ollama 或 llama.cpp server
+type LocalLLMConfig struct {
+	Backend     string `mapstructure:"backend"`      // ollama 或 llamacpp
+	Endpoint    string `mapstructure:"endpoint"`     // 服务地址，为空时使用后端的默认端口
+	Model       string `mapstructure:"model"`        // 模型名称
+	ContextSize int    `mapstructure:"context_size"` // 上下文长度，仅 ollama 可按请求设置
+	// 采样参数，为 0 时使用服务端的默认值
+	Temperature   float64 `mapstructure:"temperature"`
+	TopP          float64 `mapstructure:"top_p"`
+	TopK          int     `mapstructure:"top_k"`
+	RepeatPenalty float64 `mapstructure:"repeat_penalty"`
+	MaxTokens     int     `mapstructure:"max_tokens"` // 单次回复的最大 token 数
+	KeepAlive     string  `mapstructure:"keep_alive"` // ollama 保留模型的时长，如 10m
+	Timeout       int     `mapstructure:"timeout"`    // 非流式请求的超时(秒)
+}
+
+// GoogleSTTConfig Google 语音识别参数
+type GoogleSTTConfig struct {
+	LanguageCode               string   `mapstructure:"language_code"`
+	AlternativeLanguageCodes   []string `mapstructure:"alternative_language_codes"` // 备选语言，最多 3 个
+	SampleRate                 int      `mapstructure:"sample_rate"`                // 裸 PCM 音频的采样率
+	EnableAutomaticPunctuation bool     `mapstructure:"enable_automatic_punctuation"`
+	Phrases                    []string `mapstructure:"phrases"` // 语音上下文提示词
+	Boost                      float64  `mapstructure:"boost"`   // 提示词权重
+	Model                      string   `mapstructure:"model"`
+	LongRunning                bool     `mapstructure:"long_running"` // 超过 1 分钟或时长未知的音频使用 longrunningrecognize
+}
+
+// GoogleTTSConfig Google 语音合成参数
+type GoogleTTSConfig struct {
+	Voice            string   `mapstructure:"voice"`
+	LanguageCode     string   `mapstructure:"language_code"`
+	AudioEncoding    string   `mapstructure:"audio_encoding"`     // LINEAR16、MP3 或 OGG_OPUS
+	SampleRateHertz  int      `mapstructure:"sample_rate_hertz"`  // 0 表示使用音色的默认采样率
+	EffectsProfileID []string `mapstructure:"effects_profile_id"` // 音频设备配置，例如 telephony-class-application
+}
+
+// PiperConfig Piper 本地神经网络语音合成参数
+type PiperConfig struct {
+	Mode            string  `mapstructure:"mode"`             // command 或 http
+	Command         string  `mapstructure:"command"`          // piper 可执行文件路径
+	ModelPath       string  `mapstructure:"model_path"`       // .onnx 模型文件
+	ConfigPath      string  `mapstructure:"config_path"`      // 模型配置文件，默认为 model_path + ".json"
+	HTTPURL         string  `mapstructure:"http_url"`         // Piper HTTP 服务地址
+	SpeakerID       int     `mapstructure:"speaker_id"`       // 多说话人模型的默认说话人
+	LengthScale     float64 `mapstructure:"length_scale"`     // 语速，越大越慢
+	NoiseScale      float64 `mapstructure:"noise_scale"`      // 音频噪声，0 表示使用模型默认值
+	NoiseW          float64 `mapstructure:"noise_w"`          // 音素时长噪声，0 表示使用模型默认值
+	SentenceSilence float64 `mapstructure:"sentence_silence"` // 句间静音（秒）
+	SampleRate      int     `mapstructure:"sample_rate"`      // 输出采样率，0 表示使用模型的原始采样率
+	Timeout         int     `mapstructure:"timeout"`          // 单次合成超时（秒）
+}
+
+// ConversationConfig 控制多轮对话的存储和发送给 LLM 的历史消息
+type ConversationConfig struct {
+	Store            string `mapstructure:"store"`              // memory 或 file
+	Path             string `mapstructure:"path"`               // store 为 file 时的存储目录
+	MaxHistoryTokens int    `mapstructure:"max_history_tokens"` // 历史消息的 token 预算，0 表示不限制
+	Summarize        bool   `mapstructure:"summarize"`          // 超出预算的较早对话是否总结为摘要
+	DefaultPersona   string `mapstructure:"default_persona"`    // 客户端未指定角色时使用的角色，为空表示不使用系统提示词
+	IdleMinutes      int    `mapstructure:"idle_minutes"`       // 会话空闲超过该时长后从内存中移除，0 表示不移除
+
+	BargeIn BargeInConfig `mapstructure:"barge_in"`
+}
+
+// BargeInConfig 控制用户在助手回复期间开口时打断回复
+type BargeInConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Mode 为 audio_start 时客户端开始新的录音即打断；
+	// 为 vad 时由服务端检测录音中的人声后打断，适用于在助手说话时也持续上传麦克风音频的客户端
+	Mode        string `mapstructure:"mode"`
+	Threshold   int    `mapstructure:"threshold"`     // vad 模式下判定为人声的平均幅度（16 位 PCM）
+	MinSpeechMs int    `mapstructure:"min_speech_ms"` // vad 模式下需要连续检测到人声的时长
+}
+
+// TranslationConfig 控制语音翻译模式
+type TranslationConfig struct {
+	Provider string            `mapstructure:"provider"` // 翻译服务，目前支持 llm
+	Model    string            `mapstructure:"model"`    // provider 为 llm 时使用的模型，为空时使用 LLM 提供商的默认模型
+	Targets  []string          `mapstructure:"targets"`  // 客户端未指定时的目标语言
+	Voices   map[string]string `mapstructure:"voices"`   // 目标语言对应的音色，键不区分大小写
+}
+
+// PersonaConfig 描述一个助手角色
+type PersonaConfig struct {
+	SystemPrompt string  `mapstructure:"system_prompt"` // text/template 模板，可使用 {{.user_name}}、{{.date}} 等变量
+	Model        string  `mapstructure:"model"`         // 为空时使用 LLM 提供商的默认模型
+	Temperature  float64 `mapstructure:"temperature"`
+	Voice        string  `mapstructure:"voice"`    // 回复语音的默认音色
+	Language     string  `mapstructure:"language"` // 回复语音的默认语言
+}
+
+// TTSCacheConfig 控制合成音频的内容寻址缓存
+type TTSCacheConfig struct {
+	Enabled       bool `mapstructure:"enabled"`
+	MemoryEntries int  `mapstructure:"memory_entries"` // 内存 LRU 的容量，负数表示不使用内存层
+	Storage       bool `mapstructure:"storage"`        // 是否在存储中按内容哈希查找已合成的音频
+}
+
+// TTSLongTextConfig 控制超长文本的切分合成
+type TTSLongTextConfig struct {
+	Enabled     bool `mapstructure:"enabled"`
+	MaxBytes    int  `mapstructure:"max_bytes"`   // 每段的最大字节数，0 表示按提供商限制自动选择
+	Concurrency int  `mapstructure:"concurrency"` // 并发合成的分段数量
+}
+
+// TTSVoicesConfig 控制 /v1/voices 音色目录
+type TTSVoicesConfig struct {
+	Providers      []string `mapstructure:"providers"`       // 汇总的提供商，为空时只使用当前的 TTS 提供商
+	RefreshMinutes int      `mapstructure:"refresh_minutes"` // 音色列表的刷新间隔
+}
+
+// STTPostProcessConfig 控制使用 LLM 修正识别结果
+type STTPostProcessConfig struct {
+	Enabled  bool     `mapstructure:"enabled"`  // 会话未在 audio_start 中指定 post_process 时是否启用
+	Prompt   string   `mapstructure:"prompt"`   // 修正指令，为空时使用内置的提示词
+	Glossary []string `mapstructure:"glossary"` // 领域术语表
+	Model    string   `mapstructure:"model"`    // 为空时使用 LLM 提供商的默认模型
+	Timeout  int      `mapstructure:"timeout"`  // 单次修正的超时(秒)
+}
+
+// ChunkingConfig 控制批量 STT 接口的长音频分段
+type ChunkingConfig struct {
+	Enabled        bool    `mapstructure:"enabled"`
+	TargetSeconds  float64 `mapstructure:"target_seconds"`  // 期望的分段时长，0 表示按提供商上限自动计算
+	OverlapSeconds float64 `mapstructure:"overlap_seconds"` // 相邻分段的重叠时长
+	Concurrency    int     `mapstructure:"concurrency"`     // 并发识别的分段数量
+}
+
+// LLMToolsConfig 控制对话中可供模型调用的工具
+type LLMToolsConfig struct {
+	MaxRounds int                 `mapstructure:"max_rounds"` // 单轮对话中最多连续调用工具的次数
+	Webhooks  []WebhookToolConfig `mapstructure:"webhooks"`
+}
+
+// WebhookToolConfig 声明一个以 HTTP 接口实现的工具，调用参数以 JSON 请求体 POST 到 URL
+type WebhookToolConfig struct {
+	Name        string            `mapstructure:"name"`
+	Description string            `mapstructure:"description"`
+	Parameters  string            `mapstructure:"parameters"` // 参数的 JSON Schema，使用字符串以保留字段名的大小写
+	URL         string            `mapstructure:"url"`
+	Headers     map[string]string `mapstructure:"headers"`
+	Timeout     int               `mapstructure:"timeout"` // 请求超时（秒）
+}
+
 type Config struct {
 	Server struct {
 		Port      int
@@ -73,27 +244,54 @@ type Config struct {
 		Port int
 	}
 	STT struct {
-		Provider string
+		Provider    string
+		Chunking    ChunkingConfig       `mapstructure:"chunking"`
+		PostProcess STTPostProcessConfig `mapstructure:"post_process"`
 	}
 	TTS struct {
 		Provider string
+		Cache    TTSCacheConfig    `mapstructure:"cache"`
+		LongText TTSLongTextConfig `mapstructure:"long_text"`
+		Voices   TTSVoicesConfig   `mapstructure:"voices"`
 	}
 	LLM struct {
 		Provider string
+		Tools    LLMToolsConfig `mapstructure:"tools"`
 	}
-	AssemblyAI AssemblyAIConfig `mapstructure:"assemblyai"` // 新增
-	OpenAI     struct {
-		APIKey  string `mapstructure:"api_key"`
-		BaseURL string `mapstructure:"base_url"`
+	Conversation ConversationConfig       `mapstructure:"conversation"`
+	Personas     map[string]PersonaConfig `mapstructure:"personas"`
+	Translation  TranslationConfig        `mapstructure:"translation"`
+	AssemblyAI   AssemblyAIConfig         `mapstructure:"assemblyai"` // 新增
+	OpenAI       struct {
+		APIKey      string  `mapstructure:"api_key"`
+		BaseURL     string  `mapstructure:"base_url"`
+		Model       string  `mapstructure:"model"`
+		Temperature float64 `mapstructure:"temperature"`
+		MaxTokens   int     `mapstructure:"max_tokens"` // 0 表示不限制
+		Timeout     int     `mapstructure:"timeout"`    // 非流式请求的超时（秒）
 	}
 	Google struct {
-		TTSKey string `mapstructure:"tts_key"`
-		STTKey string `mapstructure:"stt_key"`
+		TTSKey string          `mapstructure:"tts_key"`
+		STTKey string          `mapstructure:"stt_key"`
+		STT    GoogleSTTConfig `mapstructure:"stt"`
+		TTS    GoogleTTSConfig `mapstructure:"tts"`
 	}
 	Azure struct {
 		TTSKey string `mapstructure:"tts_key"`
 		STTKey string `mapstructure:"stt_key"`
 		Region string
+		STT    struct {
+			Language  string `mapstructure:"language"`
+			Profanity string `mapstructure:"profanity"` // masked、removed 或 raw
+		} `mapstructure:"stt"`
+		TTS struct {
+			Voice        string  `mapstructure:"voice"`
+			Language     string  `mapstructure:"language"`
+			OutputFormat string  `mapstructure:"output_format"` // X-Microsoft-OutputFormat 的取值
+			Style        string  `mapstructure:"style"`         // 神经网络音色的默认说话风格
+			StyleDegree  float64 `mapstructure:"style_degree"`
+			Role         string  `mapstructure:"role"`
+		} `mapstructure:"tts"`
 	}
 	AWS        AWSConfig `yaml:"aws"`
 	Volcengine struct {
@@ -120,6 +318,13 @@ type Config struct {
 			SpeedRatio  float64 `mapstructure:"speed_ratio"`
 			VolumeRatio float64 `mapstructure:"volume_ratio"`
 			PitchRatio  float64 `mapstructure:"pitch_ratio"`
+			// Emotion、EmotionScale 为多情感音色的默认情感和情感强度(1-5)
+			Emotion      string  `mapstructure:"emotion"`
+			EmotionScale float64 `mapstructure:"emotion_scale"`
+			// BidirectionURL、ResourceID 用于双向流式合成，文本可以边生成边发送
+			BidirectionURL string `mapstructure:"bidirection_url"`
+			ResourceID     string `mapstructure:"resource_id"`
+			SampleRate     int    `mapstructure:"sample_rate"`
 		} `mapstructure:"tts"`
 	} `mapstructure:"volcengine"`
 	MinIO struct {
@@ -142,7 +347,11 @@ type Config struct {
 		Compress     bool   `mapstructure:"compress"`
 		ReportCaller bool   `mapstructure:"report_caller"`
 	}
-	Whisper WhisperConfig `mapstructure:"whisper"`
+	Whisper          WhisperConfig          `mapstructure:"whisper"`
+	OpenAICompatible OpenAICompatibleConfig `mapstructure:"openai_compatible"`
+	LocalSTT         LocalSTTConfig         `mapstructure:"local_stt"`
+	LocalLLM         LocalLLMConfig         `mapstructure:"local_llm"`
+	Piper            PiperConfig            `mapstructure:"piper"`
 }
 
 var (